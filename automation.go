@@ -35,7 +35,13 @@ var (
 
 // OverseerScriptBuilder helps construct complex Automation API scripts safely.
 type OverseerScriptBuilder struct {
-	script strings.Builder
+	script scriptWriter
+	// Control-flow state: block nesting depth, whether the block is a loop,
+	// where the last If block ended, and any misuse recorded for Err.
+	depth int
+	loop  bool
+	ifEnd int
+	errs  []error
 }
 
 // NewOverseerScriptBuilder returns a builder that constructs a PhantomJsCloud
@@ -45,6 +51,66 @@ func NewOverseerScriptBuilder() *OverseerScriptBuilder {
 	return &OverseerScriptBuilder{}
 }
 
+// scriptWriter indents every line the builder writes to the depth of the
+// enclosing control-flow block. Caller-supplied code goes through
+// writeVerbatim, so only its first line is indented and multi-line strings
+// inside it keep their exact contents.
+type scriptWriter struct {
+	strings.Builder
+	indent  string
+	midLine bool
+}
+
+func (w *scriptWriter) WriteString(s string) (int, error) {
+	n := len(s)
+	for s != "" {
+		line, rest, found := strings.Cut(s, "\n")
+		if line != "" && !w.midLine {
+			w.Builder.WriteString(w.indent)
+		}
+		w.Builder.WriteString(line)
+		w.midLine = line != "" || w.midLine
+		if found {
+			w.Builder.WriteString("\n")
+			w.midLine = false
+		}
+		s = rest
+	}
+	return n, nil
+}
+
+func (w *scriptWriter) Write(p []byte) (int, error) {
+	_, _ = w.WriteString(string(p))
+	return len(p), nil
+}
+
+func (w *scriptWriter) writeVerbatim(s string) {
+	if s == "" {
+		return
+	}
+	if !w.midLine {
+		w.Builder.WriteString(w.indent)
+	}
+	w.Builder.WriteString(s)
+	w.midLine = !strings.HasSuffix(s, "\n")
+}
+
+// trimNewline removes a trailing newline so the last line can be continued.
+func (w *scriptWriter) trimNewline() {
+	s := w.String()
+	if !strings.HasSuffix(s, "\n") {
+		return
+	}
+	w.Builder.Reset()
+	w.Builder.WriteString(strings.TrimSuffix(s, "\n"))
+	w.midLine = true
+}
+
+// writeCode writes caller-supplied JavaScript without re-indenting it.
+func (b *OverseerScriptBuilder) writeCode(code string) {
+	b.script.writeVerbatim(code)
+}
+
 func (b *OverseerScriptBuilder) writeJSString(s string) {
 	raw, _ := json.Marshal(s)
 	b.script.Write(raw)
@@ -61,7 +127,7 @@ func (b *OverseerScriptBuilder) AddScriptTag(url string) *OverseerScriptBuilder
 // Evaluate appends an evaluation block. Make sure functionBody is a valid JS function or string.
 func (b *OverseerScriptBuilder) Evaluate(functionBody string) *OverseerScriptBuilder {
 	b.script.WriteString("await page.evaluate(")
-	b.script.WriteString(functionBody)
+	b.writeCode(functionBody)
 	b.script.WriteString(");\n")
 	return b
 }
@@ -141,7 +207,7 @@ func (b *OverseerScriptBuilder) Type(selector, text string, delayMs int) *Overse
 
 // Raw appends a raw Javascript code block directly.
 func (b *OverseerScriptBuilder) Raw(code string) *OverseerScriptBuilder {
-	b.script.WriteString(code)
+	b.writeCode(code)
 	b.script.WriteString("\n")
 	return b
 }
@@ -323,7 +389,7 @@ func (b *OverseerScriptBuilder) SetExtraHTTPHeaders(headers map[string]string) *
 // WaitForFunction pauses execution until the provided Javascript function returns truthy.
 func (b *OverseerScriptBuilder) WaitForFunction(jsFunc string) *OverseerScriptBuilder {
 	b.script.WriteString("await page.waitForFunction(")
-	b.script.WriteString(jsFunc)
+	b.writeCode(jsFunc)
 	b.script.WriteString(");\n")
 	return b
 }
@@ -390,7 +456,7 @@ func (b *OverseerScriptBuilder) WaitForXPath(xpath string) *OverseerScriptBuilde
 //	node scripts/gen_stealth.js
 func (b *OverseerScriptBuilder) ApplyStealth() *OverseerScriptBuilder {
 	b.script.WriteString("await page.evaluateOnNewDocument(")
-	b.script.WriteString(stealth.JS)
+	b.writeCode(stealth.JS)
 	b.script.WriteString(");\n")
 	return b
 }
@@ -0,0 +1,406 @@
+package phantomjscloud
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxWhileIterations bounds every While loop, so a condition that never turns
+// false cannot keep a billed render running until it times out.
+const maxWhileIterations = 1000
+
+// Condition is a JavaScript boolean expression for If, ElseIf and While.
+// Build one with the Cond* functions; selectors, text and values are always
+// escaped as string or JSON literals. The zero Condition is false.
+type Condition struct {
+	write func(b *OverseerScriptBuilder)
+}
+
+func (c Condition) writeTo(b *OverseerScriptBuilder) {
+	if c.write == nil {
+		b.script.WriteString("false")
+		return
+	}
+	c.write(b)
+}
+
+// CondExists is true when an element matches selector.
+func CondExists(selector string) Condition {
+	return Condition{func(b *OverseerScriptBuilder) {
+		b.script.WriteString("(await page.$(")
+		b.writeJSString(selector)
+		b.script.WriteString(")) !== null")
+	}}
+}
+
+// CondVisible is true when an element matches selector and is not hidden by
+// display, visibility or opacity.
+func CondVisible(selector string) Condition {
+	return Condition{func(b *OverseerScriptBuilder) {
+		b.script.WriteString("await page.evaluate((s) => { const el = document.querySelector(s); if (!el) return false; " +
+			"const style = window.getComputedStyle(el); return style.display !== 'none' && style.visibility !== 'hidden' && style.opacity !== '0'; }, ")
+		b.writeJSString(selector)
+		b.script.WriteString(")")
+	}}
+}
+
+// CondTextContains is true when the first element matching selector contains text.
+func CondTextContains(selector, text string) Condition {
+	return Condition{func(b *OverseerScriptBuilder) {
+		b.script.WriteString("await page.evaluate((s, t) => { const el = document.querySelector(s); return !!el && el.textContent.includes(t); }, ")
+		b.writeJSString(selector)
+		b.script.WriteString(", ")
+		b.writeJSString(text)
+		b.script.WriteString(")")
+	}}
+}
+
+// CondURLContains is true when the current page URL contains fragment.
+func CondURLContains(fragment string) Condition {
+	return Condition{func(b *OverseerScriptBuilder) {
+		b.script.WriteString("page.url().includes(")
+		b.writeJSString(fragment)
+		b.script.WriteString(")")
+	}}
+}
+
+// CondVar is true when the script variable name is truthy.
+func CondVar(name string) Condition {
+	return Condition{func(b *OverseerScriptBuilder) {
+		b.script.WriteString("Boolean(")
+		b.writeVarName(name)
+		b.script.WriteString(")")
+	}}
+}
+
+// CondVarEquals is true when the script variable name strictly equals value.
+func CondVarEquals(name string, value interface{}) Condition {
+	return Condition{func(b *OverseerScriptBuilder) {
+		b.writeVarName(name)
+		b.script.WriteString(" === ")
+		b.writeJSValue(value)
+	}}
+}
+
+// CondVarLess is true when the script variable name is less than n.
+func CondVarLess(name string, n float64) Condition {
+	return Condition{func(b *OverseerScriptBuilder) {
+		b.writeVarName(name)
+		b.script.WriteString(" < ")
+		b.script.WriteString(jsNumber(n))
+	}}
+}
+
+// jsNumber formats n as a JavaScript number literal, including the
+// non-finite values Go would print as +Inf and NaN.
+func jsNumber(n float64) string {
+	switch {
+	case math.IsInf(n, 1):
+		return "Infinity"
+	case math.IsInf(n, -1):
+		return "-Infinity"
+	case math.IsNaN(n):
+		return "NaN"
+	}
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+// CondJS wraps a raw overseer-side JavaScript expression. Like Raw, expr is
+// code: never build it from untrusted input.
+func CondJS(expr string) Condition {
+	return Condition{func(b *OverseerScriptBuilder) {
+		b.script.WriteString("(")
+		b.writeCode(expr)
+		b.script.WriteString(")")
+	}}
+}
+
+// CondNot negates c.
+func CondNot(c Condition) Condition {
+	return Condition{func(b *OverseerScriptBuilder) {
+		b.script.WriteString("!(")
+		c.writeTo(b)
+		b.script.WriteString(")")
+	}}
+}
+
+// CondAnd is true when every condition is true. It is true for no conditions.
+func CondAnd(conds ...Condition) Condition {
+	return joinConditions(" && ", "true", conds)
+}
+
+// CondOr is true when any condition is true. It is false for no conditions.
+func CondOr(conds ...Condition) Condition {
+	return joinConditions(" || ", "false", conds)
+}
+
+func joinConditions(op, empty string, conds []Condition) Condition {
+	return Condition{func(b *OverseerScriptBuilder) {
+		if len(conds) == 0 {
+			b.script.WriteString(empty)
+			return
+		}
+		b.script.WriteString("(")
+		for i, c := range conds {
+			if i > 0 {
+				b.script.WriteString(op)
+			}
+			b.script.WriteString("(")
+			c.writeTo(b)
+			b.script.WriteString(")")
+		}
+		b.script.WriteString(")")
+	}}
+}
+
+// If runs then when cond is true. Follow it with ElseIf or Else to add
+// alternatives. The block is written indented, and variables declared in it
+// with Let are scoped to it.
+//
+//	b.IfExists("#cookie-banner button.accept", func(b *phantomjscloud.OverseerScriptBuilder) {
+//	    b.Click("#cookie-banner button.accept")
+//	}).Else(func(b *phantomjscloud.OverseerScriptBuilder) {
+//	    b.WaitForDelay(500)
+//	})
+func (b *OverseerScriptBuilder) If(cond Condition, then func(b *OverseerScriptBuilder)) *OverseerScriptBuilder {
+	b.script.WriteString("if (")
+	cond.writeTo(b)
+	b.script.WriteString(") {\n")
+	b.block(b.loop, then)
+	b.script.WriteString("}\n")
+	b.ifEnd = b.script.Len()
+	return b
+}
+
+// IfExists runs then when an element matches selector.
+func (b *OverseerScriptBuilder) IfExists(selector string, then func(b *OverseerScriptBuilder)) *OverseerScriptBuilder {
+	return b.If(CondExists(selector), then)
+}
+
+// ElseIf adds an alternative to the If or ElseIf directly before it.
+func (b *OverseerScriptBuilder) ElseIf(cond Condition, then func(b *OverseerScriptBuilder)) *OverseerScriptBuilder {
+	if !b.reopenIf("ElseIf") {
+		return b
+	}
+	b.script.WriteString(" else if (")
+	cond.writeTo(b)
+	b.script.WriteString(") {\n")
+	b.block(b.loop, then)
+	b.script.WriteString("}\n")
+	b.ifEnd = b.script.Len()
+	return b
+}
+
+// Else adds the fallback branch to the If or ElseIf directly before it.
+func (b *OverseerScriptBuilder) Else(otherwise func(b *OverseerScriptBuilder)) *OverseerScriptBuilder {
+	if !b.reopenIf("Else") {
+		return b
+	}
+	b.script.WriteString(" else {\n")
+	b.block(b.loop, otherwise)
+	b.script.WriteString("}\n")
+	return b
+}
+
+// reopenIf strips the closing newline of the If block that was written last,
+// so an else clause can follow its brace.
+func (b *OverseerScriptBuilder) reopenIf(method string) bool {
+	if b.ifEnd == 0 || b.ifEnd != b.script.Len() {
+		b.fail(fmt.Errorf("phantomjscloud: %s without a preceding If", method))
+		return false
+	}
+	b.script.trimNewline()
+	b.ifEnd = 0
+	return true
+}
+
+// While runs body for as long as cond is true, at most 1000 times.
+//
+//	b.While(phantomjscloud.CondExists("a.next"), func(b *phantomjscloud.OverseerScriptBuilder) {
+//	    b.ClickAndWaitForNavigation("a.next")
+//	})
+func (b *OverseerScriptBuilder) While(cond Condition, body func(b *OverseerScriptBuilder)) *OverseerScriptBuilder {
+	counter := fmt.Sprintf("__pjsc_w%d", b.depth)
+	fmt.Fprintf(&b.script, "for (let %s = 0; %s < %d && (", counter, counter, maxWhileIterations)
+	cond.writeTo(b)
+	fmt.Fprintf(&b.script, "); %s++) {\n", counter)
+	b.block(true, body)
+	b.script.WriteString("}\n")
+	return b
+}
+
+// Repeat runs body n times.
+func (b *OverseerScriptBuilder) Repeat(n int, body func(b *OverseerScriptBuilder)) *OverseerScriptBuilder {
+	counter := fmt.Sprintf("__pjsc_i%d", b.depth)
+	fmt.Fprintf(&b.script, "for (let %s = 0; %s < %d; %s++) {\n", counter, counter, max(n, 0), counter)
+	b.block(true, body)
+	b.script.WriteString("}\n")
+	return b
+}
+
+// Break leaves the innermost While or Repeat loop.
+func (b *OverseerScriptBuilder) Break() *OverseerScriptBuilder {
+	if !b.loop {
+		b.fail(errors.New("phantomjscloud: Break outside a loop"))
+		return b
+	}
+	b.script.WriteString("break;\n")
+	return b
+}
+
+// TryCatch runs try and, if any step in it throws, runs catch instead of
+// failing the script. The error is available to Raw code in catch as err.
+// A nil catch ignores the error.
+//
+//	b.TryCatch(func(b *phantomjscloud.OverseerScriptBuilder) {
+//	    b.WaitForSelector(".optional-widget").Click(".optional-widget")
+//	}, nil)
+func (b *OverseerScriptBuilder) TryCatch(try, catch func(b *OverseerScriptBuilder)) *OverseerScriptBuilder {
+	b.script.WriteString("try {\n")
+	b.block(b.loop, try)
+	b.script.WriteString("} catch (err) {\n")
+	b.block(b.loop, catch)
+	b.script.WriteString("}\n")
+	return b
+}
+
+// Let declares a variable scoped to the current block, initialised to value
+// encoded as JSON. Conditions refer to it by name through CondVar,
+// CondVarEquals and CondVarLess.
+func (b *OverseerScriptBuilder) Let(name string, value interface{}) *OverseerScriptBuilder {
+	if !b.checkVarName(name) {
+		return b
+	}
+	b.script.WriteString("let " + name)
+	b.script.WriteString(" = ")
+	b.writeJSValue(value)
+	b.script.WriteString(";\n")
+	return b
+}
+
+// LetEval declares a block-scoped variable holding the result of running
+// pageFunction in the page. Like Evaluate, pageFunction is code.
+func (b *OverseerScriptBuilder) LetEval(name, pageFunction string) *OverseerScriptBuilder {
+	if !b.checkVarName(name) {
+		return b
+	}
+	b.script.WriteString("let " + name)
+	b.script.WriteString(" = await page.evaluate(")
+	b.writeCode(pageFunction)
+	b.script.WriteString(");\n")
+	return b
+}
+
+// Set assigns value, encoded as JSON, to a variable declared with Let.
+func (b *OverseerScriptBuilder) Set(name string, value interface{}) *OverseerScriptBuilder {
+	if !b.checkVarName(name) {
+		return b
+	}
+	b.script.WriteString(name)
+	b.script.WriteString(" = ")
+	b.writeJSValue(value)
+	b.script.WriteString(";\n")
+	return b
+}
+
+// Increment adds one to a numeric variable declared with Let.
+func (b *OverseerScriptBuilder) Increment(name string) *OverseerScriptBuilder {
+	if !b.checkVarName(name) {
+		return b
+	}
+	b.script.WriteString(name + "++;\n")
+	return b
+}
+
+// Err reports misuse recorded while building, such as an Else without an If
+// or an invalid variable name. The script also throws at that point, so a
+// misbuilt script fails loudly instead of doing something else.
+func (b *OverseerScriptBuilder) Err() error {
+	return errors.Join(b.errs...)
+}
+
+// block writes fn's steps one level deeper, indented by two more spaces.
+// fn gets the same builder with the nesting state swapped for the block's.
+func (b *OverseerScriptBuilder) block(loop bool, fn func(b *OverseerScriptBuilder)) {
+	if fn == nil {
+		return
+	}
+	indent, depth, inLoop, ifEnd := b.script.indent, b.depth, b.loop, b.ifEnd
+	b.script.indent += "  "
+	b.depth++
+	b.loop = loop
+	b.ifEnd = 0
+	fn(b)
+	if b.script.midLine {
+		b.script.WriteString("\n")
+	}
+	b.script.indent, b.depth, b.loop, b.ifEnd = indent, depth, inLoop, ifEnd
+}
+
+// fail records err and writes a throw, so the script cannot silently run
+// with a step missing.
+func (b *OverseerScriptBuilder) fail(err error) {
+	b.errs = append(b.errs, err)
+	b.script.WriteString("throw new Error(")
+	b.writeJSString(err.Error())
+	b.script.WriteString(");\n")
+}
+
+func (b *OverseerScriptBuilder) writeJSValue(v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		b.errs = append(b.errs, fmt.Errorf("phantomjscloud: encode script value: %w", err))
+		b.script.WriteString("undefined")
+		return
+	}
+	b.script.Write(raw)
+}
+
+var jsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// jsReserved lists the words that cannot name a variable, plus the page and
+// err bindings the script itself relies on.
+var jsReserved = map[string]bool{
+	"await": true, "break": true, "case": true, "catch": true, "class": true, "const": true,
+	"continue": true, "debugger": true, "default": true, "delete": true, "do": true, "else": true,
+	"enum": true, "export": true, "extends": true, "false": true, "finally": true, "for": true,
+	"function": true, "if": true, "implements": true, "import": true, "in": true, "instanceof": true,
+	"interface": true, "let": true, "new": true, "null": true, "package": true, "private": true,
+	"protected": true, "public": true, "return": true, "static": true, "super": true, "switch": true,
+	"this": true, "throw": true, "true": true, "try": true, "typeof": true, "undefined": true,
+	"var": true, "void": true, "while": true, "with": true, "yield": true,
+	"arguments": true, "eval": true, "page": true, "err": true,
+}
+
+func validVarName(name string) bool {
+	return jsIdentifier.MatchString(name) && !jsReserved[name] && !strings.HasPrefix(name, "__pjsc_")
+}
+
+// checkVarName reports whether name is a safe identifier. Anything else
+// could let the name become code, so the step is replaced by a throw.
+func (b *OverseerScriptBuilder) checkVarName(name string) bool {
+	if !validVarName(name) {
+		b.fail(fmt.Errorf("phantomjscloud: invalid script variable name %q", name))
+		return false
+	}
+	return true
+}
+
+// writeVarName writes name inside a condition. An invalid name becomes an
+// expression that throws.
+func (b *OverseerScriptBuilder) writeVarName(name string) {
+	if !validVarName(name) {
+		err := fmt.Errorf("phantomjscloud: invalid script variable name %q", name)
+		b.errs = append(b.errs, err)
+		b.script.WriteString("(() => { throw new Error(")
+		b.writeJSString(err.Error())
+		b.script.WriteString("); })()")
+		return
+	}
+	b.script.WriteString(name)
+}
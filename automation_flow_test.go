@@ -0,0 +1,147 @@
+package phantomjscloud_test
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+)
+
+type sb = phantomjscloud.OverseerScriptBuilder
+
+func TestOverseerScriptBuilder_ControlFlow(t *testing.T) {
+	b := phantomjscloud.NewOverseerScriptBuilder().
+		Let("pages", 0).
+		IfExists("#cookies .accept", func(b *sb) {
+			b.Click("#cookies .accept")
+		}).
+		ElseIf(phantomjscloud.CondURLContains("/consent"), func(b *sb) {
+			b.GoBack()
+		}).
+		Else(func(b *sb) {
+			b.WaitForDelay(100)
+		}).
+		While(phantomjscloud.CondAnd(phantomjscloud.CondExists("a.next"), phantomjscloud.CondVarLess("pages", 5)), func(b *sb) {
+			b.TryCatch(func(b *sb) {
+				b.ClickAndWaitForNavigation("a.next").Increment("pages")
+			}, func(b *sb) {
+				b.Break()
+			})
+			b.Repeat(2, func(b *sb) {
+				b.ScrollBy(0, 500)
+			})
+		})
+	if err := b.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	want := `let pages = 0;
+if ((await page.$("#cookies .accept")) !== null) {
+  await page.click("#cookies .accept");
+} else if (page.url().includes("/consent")) {
+  await page.goBack();
+} else {
+  await page.waitForDelay(100);
+}
+for (let __pjsc_w0 = 0; __pjsc_w0 < 1000 && ((((await page.$("a.next")) !== null) && (pages < 5))); __pjsc_w0++) {
+  try {
+    await Promise.all([
+      page.waitForNavigation(),
+      page.click("a.next")
+    ]);
+    pages++;
+  } catch (err) {
+    break;
+  }
+  for (let __pjsc_i1 = 0; __pjsc_i1 < 2; __pjsc_i1++) {
+    await page.evaluate((x, y) => { window.scrollBy(x, y); }, 0, 500);
+  }
+}
+`
+	if got := b.Build(); got != want {
+		t.Errorf("script:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestOverseerScriptBuilder_ControlFlowKeepsCallerCode(t *testing.T) {
+	b := phantomjscloud.NewOverseerScriptBuilder().
+		Repeat(2, func(b *sb) {
+			b.Raw("const s = `a\nb`;")
+			b.If(phantomjscloud.CondJS("s.includes(`\n`)"), func(b *sb) {
+				b.Evaluate("() => {\n  return `x\ny`;\n}").
+					LetEval("n", "() => `1\n2`.length")
+			})
+		})
+	want := "for (let __pjsc_i0 = 0; __pjsc_i0 < 2; __pjsc_i0++) {\n" +
+		"  const s = `a\nb`;\n" +
+		"  if ((s.includes(`\n`))) {\n" +
+		"    await page.evaluate(() => {\n  return `x\ny`;\n});\n" +
+		"    let n = await page.evaluate(() => `1\n2`.length);\n" +
+		"  }\n" +
+		"}\n"
+	if got := b.Build(); got != want {
+		t.Errorf("script:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCondVarLess_NonFiniteNumbers(t *testing.T) {
+	b := phantomjscloud.NewOverseerScriptBuilder().
+		If(phantomjscloud.CondAnd(
+			phantomjscloud.CondVarLess("a", math.Inf(1)),
+			phantomjscloud.CondVarLess("b", math.Inf(-1)),
+			phantomjscloud.CondVarLess("c", math.NaN()),
+			phantomjscloud.CondVarLess("d", 1e21),
+		), nil)
+	want := "if (((a < Infinity) && (b < -Infinity) && (c < NaN) && (d < 1e+21))) {\n}\n"
+	if got := b.Build(); got != want {
+		t.Errorf("script:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestOverseerScriptBuilder_ControlFlowEscaping(t *testing.T) {
+	malicious := `"); alert(1); //`
+	b := phantomjscloud.NewOverseerScriptBuilder().
+		If(phantomjscloud.CondOr(
+			phantomjscloud.CondVisible(malicious),
+			phantomjscloud.CondTextContains(malicious, malicious),
+			phantomjscloud.CondNot(phantomjscloud.CondExists(malicious)),
+		), func(b *sb) {
+			b.Let("label", malicious).Set("label", map[string]string{"k": malicious})
+		})
+	script := b.Build()
+	if regexp.MustCompile(`[^\\]"\); alert`).MatchString(script) {
+		t.Errorf("user data escaped the string literal:\n%s", script)
+	}
+	if !strings.Contains(script, `let label = "\"); alert(1); //";`) {
+		t.Errorf("Let value not JSON encoded:\n%s", script)
+	}
+}
+
+func TestOverseerScriptBuilder_ControlFlowMisuse(t *testing.T) {
+	tests := []struct {
+		name string
+		f    func(*sb)
+		want string
+	}{
+		{"ElseWithoutIf", func(b *sb) { b.Else(func(b *sb) { b.Reload() }) }, "Else without a preceding If"},
+		{"ElseAfterStep", func(b *sb) { b.IfExists("a", nil).Reload().ElseIf(phantomjscloud.CondVar("x"), nil) }, "ElseIf without a preceding If"},
+		{"BreakOutsideLoop", func(b *sb) { b.If(phantomjscloud.CondJS("true"), func(b *sb) { b.Break() }) }, "Break outside a loop"},
+		{"InjectedName", func(b *sb) { b.Let("x = 1; alert(1); let y", 0) }, "invalid script variable name"},
+		{"ReservedName", func(b *sb) { b.Set("page", nil) }, "invalid script variable name"},
+		{"ConditionName", func(b *sb) { b.If(phantomjscloud.CondVarEquals("a-b", 1), nil) }, "invalid script variable name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := phantomjscloud.NewOverseerScriptBuilder()
+			tt.f(b)
+			if err := b.Err(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Err() = %v, want %q", err, tt.want)
+			}
+			if script := b.Build(); !strings.Contains(script, "throw new Error(") || strings.Contains(script, "let x") {
+				t.Errorf("misuse should throw in the script:\n%s", script)
+			}
+		})
+	}
+}
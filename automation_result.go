@@ -0,0 +1,356 @@
+package phantomjscloud
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrAutomationSchema is matched through errors.Is by every *AutomationSchemaError.
+var ErrAutomationSchema = errors.New("phantomjscloud: automation result does not match the target type")
+
+// AutomationSchemaError lists every place where an automation result does not
+// fit the type it is decoded into. Fields are JSON paths within the page
+// response, e.g. "automationResult.items[2].price".
+type AutomationSchemaError struct {
+	Type   string
+	Errors []*FieldError
+}
+
+func (e *AutomationSchemaError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "phantomjscloud: automation result does not match " + e.Type + ": " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether target is ErrAutomationSchema.
+func (e *AutomationSchemaError) Is(target error) bool { return target == ErrAutomationSchema }
+
+// Unwrap returns the individual field errors.
+func (e *AutomationSchemaError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// DecodeAutomationResult strictly decodes the page's automationResult into a
+// T. When the script returned nothing but wrote to scriptOutput, that is
+// decoded instead. Unknown object keys, wrong JSON types and numbers that do
+// not fit their field are reported together in an *AutomationSchemaError.
+//
+//	type Listing struct {
+//	    Title string  `json:"title"`
+//	    Price float64 `json:"price"`
+//	}
+//	listing, err := phantomjscloud.DecodeAutomationResult[Listing](&res.Page)
+func DecodeAutomationResult[T any](p *PageResponse) (T, error) {
+	var out T
+	if p == nil {
+		return out, ErrNoPageResponse
+	}
+	automationRaw, scriptOutputRaw := p.rawResults()
+	source, value, raw := "automationResult", p.AutomationResult, []byte(automationRaw)
+	if value == nil && len(p.ScriptOutput) > 0 {
+		source, value, raw = "scriptOutput", interface{}(p.ScriptOutput), scriptOutputRaw
+	}
+	if value == nil {
+		return out, ErrNoAutomationResult
+	}
+
+	// Decode the bytes the API sent. A PageResponse built in code has only
+	// the float64-based value, so large integers in it are flagged instead.
+	c := &schemaChecker{}
+	if raw == nil {
+		var err error
+		if raw, err = json.Marshal(value); err != nil {
+			return out, fmt.Errorf("failed to encode automation result: %w", err)
+		}
+		c.lossy = true
+	}
+	var generic interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&generic); err != nil {
+		return out, fmt.Errorf("failed to decode automation result: %w", err)
+	}
+	c.check(source, generic, reflect.TypeOf(&out).Elem())
+	if len(c.errs) > 0 {
+		return out, &AutomationSchemaError{Type: reflect.TypeOf(&out).Elem().String(), Errors: c.errs}
+	}
+
+	dec = json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&out); err != nil {
+		return out, fmt.Errorf("failed to decode automation result: %w", err)
+	}
+	return out, nil
+}
+
+// FetchAutomationAs runs builder's script against url and strictly decodes
+// the result into a T, like DecodeAutomationResult.
+func FetchAutomationAs[T any](ctx context.Context, c *Client, url string, builder *OverseerScriptBuilder) (T, error) {
+	res, err := c.Render(ctx, &PageRequest{
+		URL:            url,
+		RenderType:     "automation",
+		OverseerScript: builder.Build(),
+		OutputAsJson:   true,
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return DecodeAutomationResult[T](&res.Page)
+}
+
+// maxExactFloatInt is 2^53. Every integer below it survives float64 exactly;
+// from there on, neighbouring integers round to the same value.
+const maxExactFloatInt = 1 << 53
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// schemaChecker walks a generic JSON value alongside the Go type it will be
+// decoded into, following encoding/json's rules.
+type schemaChecker struct {
+	errs []*FieldError
+	// lossy is set when numbers passed through float64, so integers beyond
+	// 2^53 may already have been rounded.
+	lossy bool
+}
+
+func (c *schemaChecker) add(path, msg string) {
+	c.errs = append(c.errs, &FieldError{Field: path, Message: msg})
+}
+
+func (c *schemaChecker) mismatch(path, want string, v interface{}) {
+	c.add(path, fmt.Sprintf("expected %s, got %s", want, jsonKind(v)))
+}
+
+func (c *schemaChecker) check(path string, v interface{}, t reflect.Type) {
+	if v == nil {
+		// null leaves the target untouched, as in encoding/json.
+		return
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		// The type decodes itself; its own errors surface from the final decode.
+		return
+	}
+	if t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		if _, ok := v.(string); !ok {
+			c.mismatch(path, "string", v)
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		c.check(path, v, t.Elem())
+	case reflect.Interface:
+		if t.NumMethod() > 0 {
+			c.add(path, fmt.Sprintf("cannot decode into interface %s", t))
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			c.mismatch(path, "boolean", v)
+		}
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			c.mismatch(path, "string", v)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := v.(json.Number)
+		if !ok {
+			c.mismatch(path, "number", v)
+		} else if i, err := strconv.ParseInt(string(n), 10, t.Bits()); err != nil {
+			c.add(path, fmt.Sprintf("%s does not fit %s", n, t))
+		} else if c.lossy && (i >= maxExactFloatInt || i <= -maxExactFloatInt) {
+			c.add(path, fmt.Sprintf("%s is not below 2^53 and may have lost precision", n))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := v.(json.Number)
+		if !ok {
+			c.mismatch(path, "number", v)
+		} else if u, err := strconv.ParseUint(string(n), 10, t.Bits()); err != nil {
+			c.add(path, fmt.Sprintf("%s does not fit %s", n, t))
+		} else if c.lossy && u >= maxExactFloatInt {
+			c.add(path, fmt.Sprintf("%s is not below 2^53 and may have lost precision", n))
+		}
+	case reflect.Float32, reflect.Float64:
+		n, ok := v.(json.Number)
+		if !ok {
+			c.mismatch(path, "number", v)
+		} else if _, err := strconv.ParseFloat(string(n), t.Bits()); err != nil {
+			c.add(path, fmt.Sprintf("%s does not fit %s", n, t))
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			if _, ok := v.(string); !ok {
+				c.mismatch(path, "base64 string", v)
+			}
+			return
+		}
+		items, ok := v.([]interface{})
+		if !ok {
+			c.mismatch(path, "array", v)
+			return
+		}
+		if t.Kind() == reflect.Array && len(items) > t.Len() {
+			c.add(path, fmt.Sprintf("%d items do not fit %s", len(items), t))
+		}
+		for i, item := range items {
+			c.check(fmt.Sprintf("%s[%d]", path, i), item, t.Elem())
+		}
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			c.mismatch(path, "object", v)
+			return
+		}
+		for _, k := range sortedKeys(obj) {
+			c.checkMapKey(path, k, t.Key())
+			c.check(path+"."+k, obj[k], t.Elem())
+		}
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			c.mismatch(path, "object", v)
+			return
+		}
+		fields := jsonFields(t, nil)
+		for _, k := range sortedKeys(obj) {
+			f, ok := lookupJSONField(fields, k)
+			if !ok {
+				c.add(path+"."+k, "unknown field")
+				continue
+			}
+			if f.quoted {
+				// `json:",string"` fields carry their value inside a JSON string.
+				if _, ok := obj[k].(string); !ok && obj[k] != nil {
+					c.mismatch(path+"."+k, "quoted string", obj[k])
+				}
+				continue
+			}
+			c.check(path+"."+k, obj[k], f.typ)
+		}
+	default:
+		c.add(path, fmt.Sprintf("cannot decode into %s", t))
+	}
+}
+
+func (c *schemaChecker) checkMapKey(path, key string, t reflect.Type) {
+	switch {
+	case t.Kind() == reflect.String:
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		if _, err := strconv.ParseInt(key, 10, t.Bits()); err != nil {
+			c.add(path+"."+key, fmt.Sprintf("key does not fit %s", t))
+		}
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uintptr:
+		if _, err := strconv.ParseUint(key, 10, t.Bits()); err != nil {
+			c.add(path+"."+key, fmt.Sprintf("key does not fit %s", t))
+		}
+	default:
+		c.add(path+"."+key, fmt.Sprintf("cannot decode keys into %s", t))
+	}
+}
+
+type jsonField struct {
+	name   string
+	typ    reflect.Type
+	quoted bool
+}
+
+// jsonFields lists the JSON names of t's fields, promoting untagged embedded
+// structs the way encoding/json does. Outer fields come first so they win.
+func jsonFields(t reflect.Type, out []jsonField) []jsonField {
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if sf.Anonymous && name == "" {
+			et := sf.Type
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				embedded = append(embedded, et)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		quoted := false
+		for _, o := range strings.Split(opts, ",") {
+			if o == "string" {
+				quoted = true
+			}
+		}
+		out = append(out, jsonField{name: name, typ: sf.Type, quoted: quoted})
+	}
+	for _, et := range embedded {
+		out = jsonFields(et, out)
+	}
+	return out
+}
+
+// lookupJSONField prefers an exact name match and falls back to a
+// case-insensitive one, as encoding/json does.
+func lookupJSONField(fields []jsonField, key string) (jsonField, bool) {
+	for _, f := range fields {
+		if f.name == key {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, key) {
+			return f, true
+		}
+	}
+	return jsonField{}, false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func jsonKind(v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number " + string(v)
+	case string:
+		return fmt.Sprintf("string %q", v)
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,151 @@
+package phantomjscloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type listingMeta struct {
+	Source string `json:"source"`
+}
+
+type listing struct {
+	listingMeta
+	Title   string            `json:"title"`
+	Price   float64           `json:"price"`
+	Stock   uint8             `json:"stock"`
+	Tags    []string          `json:"tags"`
+	Seen    time.Time         `json:"seen"`
+	Counts  map[string]int    `json:"counts"`
+	Extra   map[string]string `json:"extra,omitempty"`
+	Skipped string            `json:"-"`
+}
+
+func pageWith(t *testing.T, body string) *PageResponse {
+	t.Helper()
+	var p PageResponse
+	if err := json.Unmarshal([]byte(body), &p); err != nil {
+		t.Fatal(err)
+	}
+	return &p
+}
+
+func TestDecodeAutomationResult(t *testing.T) {
+	p := pageWith(t, `{"automationResult":{"source":"feed","title":"Loft","price":1250.5,"stock":3,
+		"tags":["new"],"seen":"2024-03-04T10:00:00Z","counts":{"views":12}}}`)
+	got, err := DecodeAutomationResult[listing](p)
+	if err != nil {
+		t.Fatalf("DecodeAutomationResult: %v", err)
+	}
+	if got.Source != "feed" || got.Title != "Loft" || got.Price != 1250.5 || got.Stock != 3 || got.Counts["views"] != 12 || got.Seen.Year() != 2024 {
+		t.Errorf("unexpected result %+v", got)
+	}
+
+	// scriptOutput is used when the script returned nothing.
+	p = pageWith(t, `{"scriptOutput":{"title":"From output"}}`)
+	if got, err := DecodeAutomationResult[*listing](p); err != nil || got.Title != "From output" {
+		t.Errorf("scriptOutput decode = %+v, %v", got, err)
+	}
+	if got, err := DecodeAutomationResult[map[string]any](pageWith(t, `{"automationResult":{"a":[1,"b"]}}`)); err != nil || len(got["a"].([]any)) != 2 {
+		t.Errorf("generic decode = %v, %v", got, err)
+	}
+
+	if _, err := DecodeAutomationResult[listing](pageWith(t, `{}`)); !errors.Is(err, ErrNoAutomationResult) {
+		t.Errorf("expected ErrNoAutomationResult, got %v", err)
+	}
+	if _, err := DecodeAutomationResult[listing](nil); !errors.Is(err, ErrNoPageResponse) {
+		t.Errorf("expected ErrNoPageResponse, got %v", err)
+	}
+}
+
+func TestDecodeAutomationResult_SchemaErrors(t *testing.T) {
+	p := pageWith(t, `{"automationResult":{"title":7,"price":"cheap","stock":300,"tags":["ok",false],
+		"seen":12,"counts":{"views":1.5},"Skipped":"x","extra":{"a":"b"}}}`)
+	_, err := DecodeAutomationResult[listing](p)
+	var serr *AutomationSchemaError
+	if !errors.As(err, &serr) || !errors.Is(err, ErrAutomationSchema) {
+		t.Fatalf("expected an AutomationSchemaError, got %v", err)
+	}
+	var got []string
+	for _, fe := range serr.Errors {
+		got = append(got, fe.Field+": "+fe.Message)
+	}
+	want := []string{
+		`automationResult.Skipped: unknown field`,
+		`automationResult.counts.views: 1.5 does not fit int`,
+		`automationResult.price: expected number, got string "cheap"`,
+		`automationResult.stock: 300 does not fit uint8`,
+		`automationResult.tags[1]: expected string, got boolean`,
+		`automationResult.title: expected string, got number 7`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("schema errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if serr.Type != "phantomjscloud.listing" {
+		t.Errorf("Type = %q", serr.Type)
+	}
+
+	// time.Time decodes itself, so its error comes from encoding/json.
+	if _, err := DecodeAutomationResult[listing](pageWith(t, `{"automationResult":{"seen":"yesterday"}}`)); err == nil || errors.Is(err, ErrAutomationSchema) {
+		t.Errorf("expected a time parse error, got %v", err)
+	}
+	if _, err := DecodeAutomationResult[[]int](pageWith(t, `{"automationResult":{"a":1}}`)); err == nil || !strings.Contains(err.Error(), "automationResult: expected array, got object") {
+		t.Errorf("expected a root mismatch, got %v", err)
+	}
+}
+
+func TestDecodeAutomationResult_LargeIntegers(t *testing.T) {
+	type record struct {
+		ID int64 `json:"id"`
+	}
+	p := pageWith(t, `{"automationResult":{"id":9007199254740993}}`)
+	if got, err := DecodeAutomationResult[record](p); err != nil || got.ID != 9007199254740993 {
+		t.Fatalf("decode from API bytes = %d, %v", got.ID, err)
+	}
+
+	// Copies made through JSON, as by the cache and request coalescing, keep the API bytes.
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := DecodeAutomationResult[record](pageWith(t, string(data))); err != nil || got.ID != 9007199254740993 {
+		t.Fatalf("decode after a JSON round-trip = %d, %v", got.ID, err)
+	}
+
+	// A field changed after decoding is used as it now is, in copies too.
+	p.AutomationResult = map[string]interface{}{"id": float64(7)}
+	if got, err := DecodeAutomationResult[record](p); err != nil || got.ID != 7 {
+		t.Fatalf("decode after a change = %d, %v", got.ID, err)
+	}
+	if data, err = json.Marshal(p); err != nil || !strings.Contains(string(data), `"automationResult":{"id":7}`) {
+		t.Fatalf("marshal after a change = %s, %v", data, err)
+	}
+
+	// A response built in code only has float64 values, so the digits may be gone.
+	built := &PageResponse{AutomationResult: map[string]interface{}{"id": float64(9007199254740993)}}
+	if _, err := DecodeAutomationResult[record](built); !errors.Is(err, ErrAutomationSchema) || !strings.Contains(err.Error(), "automationResult.id") {
+		t.Fatalf("expected a precision schema error, got %v", err)
+	}
+	built = &PageResponse{AutomationResult: map[string]interface{}{"id": float64(1 << 40)}}
+	if got, err := DecodeAutomationResult[record](built); err != nil || got.ID != 1<<40 {
+		t.Fatalf("exact integers should decode, got %d, %v", got.ID, err)
+	}
+}
+
+func TestFetchAutomationAs(t *testing.T) {
+	client := newRenderServer(t, "application/json", []byte(`{"pageResponses":[{"automationResult":{"title":"Example","price":10}}]}`))
+	got, err := FetchAutomationAs[listing](context.Background(), client, "https://example.com", NewOverseerScriptBuilder().WaitForSelector("h1"))
+	if err != nil || got.Title != "Example" || got.Price != 10 {
+		t.Fatalf("FetchAutomationAs = %+v, %v", got, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FetchAutomationAs[listing](ctx, client, "https://example.com", NewOverseerScriptBuilder()); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
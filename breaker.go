@@ -0,0 +1,438 @@
+package phantomjscloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amafjarkasi/go-phantomjs/ext/proxy"
+)
+
+// ErrCircuitOpen is matched through errors.Is by every *CircuitOpenError.
+var ErrCircuitOpen = errors.New("phantomjscloud: circuit breaker open")
+
+// BreakerScope names what a circuit breaker protects.
+type BreakerScope string
+
+const (
+	// BreakerScopeEndpoint guards the PhantomJsCloud API itself.
+	BreakerScopeEndpoint BreakerScope = "endpoint"
+	// BreakerScopeHost guards one target host, as normalized by proxy.ExtractHost.
+	BreakerScopeHost BreakerScope = "host"
+)
+
+// BreakerState is the state of one circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed lets every request through.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails requests fast until the open timeout has passed.
+	BreakerOpen
+	// BreakerHalfOpen lets a limited number of probe requests through.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// CircuitOpenError is returned without sending a request when a breaker is open.
+type CircuitOpenError struct {
+	Scope BreakerScope
+	// Host is the target host for BreakerScopeHost, empty for the endpoint.
+	Host string
+	// RetryAt is when the breaker will next let a probe through.
+	RetryAt time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	if e.Scope == BreakerScopeHost {
+		return fmt.Sprintf("phantomjscloud: circuit breaker open for host %s until %s", e.Host, e.RetryAt.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("phantomjscloud: circuit breaker open for endpoint until %s", e.RetryAt.Format(time.RFC3339))
+}
+
+// Is reports whether target is ErrCircuitOpen.
+func (e *CircuitOpenError) Is(target error) bool { return target == ErrCircuitOpen }
+
+// BreakerPolicy configures one breaker scope. A zero FailureThreshold disables the scope.
+type BreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failures that opens the breaker.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker stays open before letting probes through.
+	OpenTimeout time.Duration
+	// HalfOpenProbes is the number of concurrent probes allowed while half-open.
+	// Values below one allow a single probe.
+	HalfOpenProbes int
+}
+
+// BreakerConfig configures the circuit breakers installed by WithCircuitBreaker.
+//
+// The endpoint breaker counts 5xx API errors and transport errors as failures.
+// Host breakers count blocked responses and failing content status codes for
+// each page's target host. Client errors, such as an invalid request, and
+// cancelled calls don't count either way.
+type BreakerConfig struct {
+	Endpoint BreakerPolicy
+	Host     BreakerPolicy
+	// IsBlocked classifies a page as blocked for the host breaker, e.g. blockpolicy.LooksBlocked.
+	// It is called with a response holding only that page.
+	IsBlocked func(*UserResponseWithMeta) bool
+	// IsHostFailure classifies a target page's content status code. When nil,
+	// 5xx, 403 and 429 count as failures.
+	IsHostFailure func(statusCode int) bool
+	// OnStateChange is called after a breaker changes state. host is empty for the endpoint.
+	OnStateChange func(scope BreakerScope, host string, from, to BreakerState)
+}
+
+// DefaultBreakerConfig opens the endpoint breaker after five consecutive
+// failures and a host breaker after ten, each for 30 seconds.
+var DefaultBreakerConfig = BreakerConfig{
+	Endpoint: BreakerPolicy{FailureThreshold: 5, OpenTimeout: 30 * time.Second, HalfOpenProbes: 1},
+	Host:     BreakerPolicy{FailureThreshold: 10, OpenTimeout: 30 * time.Second, HalfOpenProbes: 1},
+}
+
+// WithCircuitBreaker fails calls fast while the PhantomJsCloud endpoint or a
+// target host keeps failing. Every HTTP attempt through DoContext, including
+// retries, is checked, and an open breaker returns a *CircuitOpenError before
+// the ledger, rate limiter or API are touched.
+func WithCircuitBreaker(cfg BreakerConfig) ClientOption {
+	return func(c *Client) {
+		c.breakers = &breakerSet{cfg: cfg, hosts: make(map[string]*breaker), now: time.Now}
+	}
+}
+
+// BreakerStats is a snapshot of the client's circuit breakers.
+type BreakerStats struct {
+	Endpoint BreakerState
+	// Hosts holds every host breaker that is not closed.
+	Hosts map[string]BreakerState
+}
+
+// BreakerStats returns the current breaker states. It returns the zero value
+// when WithCircuitBreaker was not used.
+func (c *Client) BreakerStats() BreakerStats {
+	b := c.breakers
+	if b == nil {
+		return BreakerStats{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	stats := BreakerStats{Hosts: make(map[string]BreakerState)}
+	if b.endpoint != nil {
+		stats.Endpoint = b.endpoint.currentLocked(now)
+	}
+	for host, br := range b.hosts {
+		if s := br.currentLocked(now); s != BreakerClosed {
+			stats.Hosts[host] = s
+		}
+	}
+	return stats
+}
+
+// breakerSet holds the endpoint breaker and the per-host breakers of a Client.
+// All breakers share mu.
+type breakerSet struct {
+	cfg      BreakerConfig
+	now      func() time.Time
+	mu       sync.Mutex
+	endpoint *breaker
+	hosts    map[string]*breaker
+	// changes queues OnStateChange calls until mu is released.
+	changes []func()
+}
+
+// unlock releases mu and then reports queued state changes.
+func (s *breakerSet) unlock() {
+	changes := s.changes
+	s.changes = nil
+	s.mu.Unlock()
+	for _, fn := range changes {
+		fn()
+	}
+}
+
+type breaker struct {
+	scope    BreakerScope
+	host     string
+	policy   BreakerPolicy
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probes   int
+}
+
+// breakerPass records which breakers admitted a call, so its outcome can be
+// reported back to them.
+type breakerPass struct {
+	set      *breakerSet
+	endpoint *breaker
+	hosts    []*breaker
+	probing  map[*breaker]bool
+}
+
+type breakerOutcome int
+
+const (
+	breakerNeutral breakerOutcome = iota
+	breakerSuccess
+	breakerFailure
+)
+
+// admit checks every breaker req passes through. It either admits the call
+// on all of them or returns a *CircuitOpenError without admitting it on any.
+func (s *breakerSet) admit(req *UserRequest) (*breakerPass, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.unlock()
+	now := s.now()
+
+	pass := &breakerPass{set: s, probing: make(map[*breaker]bool)}
+	if s.cfg.Endpoint.FailureThreshold > 0 {
+		if s.endpoint == nil {
+			s.endpoint = &breaker{scope: BreakerScopeEndpoint, policy: s.cfg.Endpoint}
+		}
+		pass.endpoint = s.endpoint
+	}
+	if s.cfg.Host.FailureThreshold > 0 {
+		for _, host := range requestHosts(req) {
+			br := s.hosts[host]
+			if br == nil {
+				br = &breaker{scope: BreakerScopeHost, host: host, policy: s.cfg.Host}
+				s.hosts[host] = br
+			}
+			pass.hosts = append(pass.hosts, br)
+		}
+	}
+
+	all := pass.hosts
+	if pass.endpoint != nil {
+		all = append([]*breaker{pass.endpoint}, all...)
+	}
+	for _, br := range all {
+		if err := s.checkLocked(br, now); err != nil {
+			return nil, err
+		}
+	}
+	for _, br := range all {
+		if br.state == BreakerHalfOpen {
+			br.probes++
+			pass.probing[br] = true
+		}
+	}
+	return pass, nil
+}
+
+// allows reports whether admit would let req through now, without taking a
+// probe slot. A half-open breaker whose probes are all in flight rejects it.
+func (s *breakerSet) allows(req *UserRequest) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+
+	var all []*breaker
+	if s.endpoint != nil && s.cfg.Endpoint.FailureThreshold > 0 {
+		all = append(all, s.endpoint)
+	}
+	if s.cfg.Host.FailureThreshold > 0 {
+		for _, host := range requestHosts(req) {
+			if br := s.hosts[host]; br != nil {
+				all = append(all, br)
+			}
+		}
+	}
+	for _, br := range all {
+		switch br.currentLocked(now) {
+		case BreakerOpen:
+			return false
+		case BreakerHalfOpen:
+			if br.state == BreakerHalfOpen && br.probes >= max(br.policy.HalfOpenProbes, 1) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkLocked moves an expired open breaker to half-open and reports whether
+// br would admit another call.
+func (s *breakerSet) checkLocked(br *breaker, now time.Time) error {
+	if br.state == BreakerOpen && !now.Before(br.openedAt.Add(br.policy.OpenTimeout)) {
+		s.transitionLocked(br, BreakerHalfOpen)
+	}
+	switch br.state {
+	case BreakerOpen:
+		return &CircuitOpenError{Scope: br.scope, Host: br.host, RetryAt: br.openedAt.Add(br.policy.OpenTimeout)}
+	case BreakerHalfOpen:
+		if br.probes >= max(br.policy.HalfOpenProbes, 1) {
+			return &CircuitOpenError{Scope: br.scope, Host: br.host, RetryAt: now.Add(br.policy.OpenTimeout)}
+		}
+	}
+	return nil
+}
+
+// done reports the outcome of an admitted call. A call that never reached
+// the API, with both res and err nil, only releases its probe slots.
+func (p *breakerPass) done(ctx context.Context, req *UserRequest, res *UserResponseWithMeta, err error) {
+	if p == nil {
+		return
+	}
+	s := p.set
+	s.mu.Lock()
+	defer s.unlock()
+	now := s.now()
+
+	sent := res != nil || err != nil
+	if p.endpoint != nil {
+		outcome := breakerNeutral
+		if sent {
+			outcome = endpointOutcome(ctx, err)
+		}
+		s.resolveLocked(p, p.endpoint, outcome, now)
+	}
+	for _, br := range p.hosts {
+		outcome := breakerNeutral
+		if res != nil {
+			outcome = s.hostOutcome(req, res, br.host)
+		}
+		s.resolveLocked(p, br, outcome, now)
+	}
+}
+
+func (s *breakerSet) resolveLocked(p *breakerPass, br *breaker, outcome breakerOutcome, now time.Time) {
+	if p.probing[br] && br.probes > 0 {
+		br.probes--
+	}
+	switch outcome {
+	case breakerSuccess:
+		br.failures = 0
+		if br.state != BreakerClosed {
+			s.transitionLocked(br, BreakerClosed)
+		}
+	case breakerFailure:
+		br.failures++
+		if br.state == BreakerHalfOpen || (br.state == BreakerClosed && br.failures >= br.policy.FailureThreshold) {
+			br.openedAt = now
+			s.transitionLocked(br, BreakerOpen)
+		}
+	}
+}
+
+func (s *breakerSet) transitionLocked(br *breaker, to BreakerState) {
+	from := br.state
+	br.state = to
+	if to != BreakerHalfOpen {
+		br.probes = 0
+	}
+	if to == BreakerClosed {
+		br.failures = 0
+	}
+	if fn := s.cfg.OnStateChange; fn != nil && from != to {
+		scope, host := br.scope, br.host
+		s.changes = append(s.changes, func() { fn(scope, host, from, to) })
+	}
+}
+
+// currentLocked returns the state br would have for a call made at now.
+func (br *breaker) currentLocked(now time.Time) BreakerState {
+	if br.state == BreakerOpen && !now.Before(br.openedAt.Add(br.policy.OpenTimeout)) {
+		return BreakerHalfOpen
+	}
+	return br.state
+}
+
+// endpointOutcome classifies a call for the endpoint breaker: transport
+// errors and 5xx responses are failures, any other API answer is a success.
+func endpointOutcome(ctx context.Context, err error) breakerOutcome {
+	if err == nil {
+		return breakerSuccess
+	}
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return breakerNeutral
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode >= 500 {
+			return breakerFailure
+		}
+		return breakerSuccess
+	}
+	return breakerFailure
+}
+
+// hostOutcome classifies the pages of res that belong to host. Any failed
+// page fails the host.
+func (s *breakerSet) hostOutcome(req *UserRequest, res *UserResponseWithMeta, host string) breakerOutcome {
+	outcome := breakerNeutral
+	for i, page := range req.Pages {
+		if proxy.ExtractHost(page.URL) != host || i >= len(res.PageResponses) {
+			continue
+		}
+		if s.pageFailed(res, i) {
+			return breakerFailure
+		}
+		outcome = breakerSuccess
+	}
+	return outcome
+}
+
+func (s *breakerSet) pageFailed(res *UserResponseWithMeta, i int) bool {
+	pr := res.PageResponses[i]
+	code := pr.StatusCode
+	if code == 0 && len(res.PageResponses) == 1 {
+		code = res.Metadata.ContentStatusCode
+	}
+	isFailure := s.cfg.IsHostFailure
+	if isFailure == nil {
+		isFailure = defaultHostFailure
+	}
+	if code != 0 && isFailure(code) {
+		return true
+	}
+	if s.cfg.IsBlocked == nil {
+		return false
+	}
+	page := &UserResponseWithMeta{UserResponse: res.UserResponse, Metadata: res.Metadata}
+	page.PageResponses = []PageResponse{pr}
+	if len(res.PageResponses) > 1 {
+		page.Metadata.ContentStatusCode = 0
+	}
+	return s.cfg.IsBlocked(page)
+}
+
+func defaultHostFailure(code int) bool {
+	return code >= 500 || code == 403 || code == 429
+}
+
+// requestHosts returns the distinct, sorted target hosts of req's pages.
+func requestHosts(req *UserRequest) []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	for _, p := range req.Pages {
+		host := proxy.ExtractHost(p.URL)
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
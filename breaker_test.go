@@ -0,0 +1,155 @@
+package phantomjscloud
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newBreakerClient(t *testing.T, handler http.HandlerFunc, cfg BreakerConfig) (*Client, *int32, *time.Time) {
+	t.Helper()
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	now := time.Now()
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithCircuitBreaker(cfg))
+	client.breakers.now = func() time.Time { return now }
+	return client, &hits, &now
+}
+
+func TestCircuitBreaker_EndpointOpensAndProbes(t *testing.T) {
+	var healthy atomic.Bool
+	client, hits, now := newBreakerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"pageResponses":[{"statusCode":200}]}`))
+	}, BreakerConfig{Endpoint: BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Minute}})
+
+	page := &PageRequest{URL: "https://example.com"}
+	for i := 0; i < 2; i++ {
+		if _, err := client.DoPage(page); err == nil {
+			t.Fatal("expected 500 error")
+		}
+	}
+
+	_, err := client.DoPage(page)
+	var openErr *CircuitOpenError
+	if !errors.Is(err, ErrCircuitOpen) || !errors.As(err, &openErr) || openErr.Scope != BreakerScopeEndpoint {
+		t.Fatalf("expected endpoint CircuitOpenError, got %v", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 2 {
+		t.Fatalf("expected open breaker to fail fast, server saw %d requests", got)
+	}
+	if client.BreakerStats().Endpoint != BreakerOpen {
+		t.Fatalf("expected open endpoint, got %v", client.BreakerStats().Endpoint)
+	}
+
+	// A failed probe reopens the breaker.
+	*now = now.Add(time.Minute)
+	if _, err := client.DoPage(page); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected probe to reach the API, got %v", err)
+	}
+	if _, err := client.DoPage(page); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected breaker to reopen after failed probe, got %v", err)
+	}
+
+	// A successful probe closes it.
+	healthy.Store(true)
+	*now = now.Add(time.Minute)
+	if _, err := client.DoPage(page); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if client.BreakerStats().Endpoint != BreakerClosed {
+		t.Fatalf("expected closed endpoint, got %v", client.BreakerStats().Endpoint)
+	}
+}
+
+func TestCircuitBreaker_ClientErrorsDoNotTrip(t *testing.T) {
+	client, _, _ := newBreakerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}, BreakerConfig{Endpoint: BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute}})
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("400 responses must not open the endpoint breaker: %v", err)
+		}
+	}
+}
+
+func TestCircuitBreaker_HostScope(t *testing.T) {
+	var mu sync.Mutex
+	var changes []string
+	cfg := BreakerConfig{
+		Host: BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Minute},
+		IsBlocked: func(res *UserResponseWithMeta) bool {
+			return strings.Contains(res.PageResponses[0].Content, "captcha")
+		},
+		OnStateChange: func(scope BreakerScope, host string, from, to BreakerState) {
+			mu.Lock()
+			defer mu.Unlock()
+			changes = append(changes, string(scope)+":"+host+":"+from.String()+"->"+to.String())
+		},
+	}
+	client, hits, _ := newBreakerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"pageResponses":[{"statusCode":200,"content":"solve this captcha"}]}`))
+	}, cfg)
+
+	blocked := &PageRequest{URL: "https://www.blocked.example/a"}
+	for i := 0; i < 2; i++ {
+		if _, err := client.DoPage(blocked); err != nil {
+			t.Fatalf("DoPage: %v", err)
+		}
+	}
+	_, err := client.DoPage(&PageRequest{URL: "https://blocked.example/b"})
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || openErr.Scope != BreakerScopeHost || openErr.Host != "blocked.example" {
+		t.Fatalf("expected host CircuitOpenError, got %v", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 2 {
+		t.Fatalf("expected 2 requests to reach the API, got %d", got)
+	}
+	if got := client.BreakerStats().Hosts["blocked.example"]; got != BreakerOpen {
+		t.Fatalf("expected open host breaker, got %v", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(changes) != 1 || changes[0] != "host:blocked.example:closed->open" {
+		t.Fatalf("unexpected state changes %v", changes)
+	}
+}
+
+func TestCircuitBreaker_HostStatusCodes(t *testing.T) {
+	client, _, _ := newBreakerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"pageResponses":[{"statusCode":200},{"statusCode":503}]}`))
+	}, BreakerConfig{Host: BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute}})
+
+	req := &UserRequest{Pages: []PageRequest{{URL: "https://up.example"}, {URL: "https://down.example"}}}
+	if _, err := client.Do(req); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	stats := client.BreakerStats()
+	if stats.Hosts["down.example"] != BreakerOpen {
+		t.Fatalf("expected down.example to open, got %v", stats.Hosts)
+	}
+	if _, ok := stats.Hosts["up.example"]; ok {
+		t.Fatalf("expected up.example to stay closed, got %v", stats.Hosts)
+	}
+	if _, err := client.DoPage(&PageRequest{URL: "https://up.example/other"}); err != nil {
+		t.Fatalf("expected healthy host to pass, got %v", err)
+	}
+}
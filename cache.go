@@ -0,0 +1,346 @@
+package phantomjscloud
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores encoded API responses by canonical request key.
+// Implementations must be safe for concurrent use and must not return
+// entries whose TTL has elapsed.
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(key string)
+}
+
+// CacheConfig controls which responses the client caches and for how long.
+type CacheConfig struct {
+	// Backend stores the entries. Use NewMemoryCache or NewDirCache.
+	Backend Cache
+	// TTL applies to render types without an entry in TTLByRenderType.
+	TTL time.Duration
+	// TTLByRenderType overrides TTL per render type ("html", "png", "pdf", ...).
+	// A zero or negative value disables caching for that render type.
+	TTLByRenderType map[string]time.Duration
+}
+
+// WithCache serves repeated identical requests from cfg.Backend instead of
+// calling the API. Cache hits skip retries, rate limits and ledger budgets and
+// are marked with ResponseMetadata.Cached. See WithCacheMode for per-call control.
+func WithCache(cfg CacheConfig) ClientOption {
+	return func(c *Client) {
+		if cfg.Backend == nil {
+			c.cache = nil
+			return
+		}
+		c.cache = &cfg
+	}
+}
+
+// CacheMode selects how a single call interacts with the client's cache.
+type CacheMode int
+
+const (
+	// CacheDefault reads from and writes to the cache.
+	CacheDefault CacheMode = iota
+	// CacheBypass neither reads from nor writes to the cache.
+	CacheBypass
+	// CacheRefresh skips the cached entry but stores the fresh response.
+	CacheRefresh
+)
+
+type cacheModeKey struct{}
+
+// WithCacheMode returns a context whose requests use mode instead of CacheDefault.
+func WithCacheMode(ctx context.Context, mode CacheMode) context.Context {
+	return context.WithValue(ctx, cacheModeKey{}, mode)
+}
+
+func cacheModeFrom(ctx context.Context) CacheMode {
+	if ctx == nil {
+		return CacheDefault
+	}
+	mode, _ := ctx.Value(cacheModeKey{}).(CacheMode)
+	return mode
+}
+
+// CacheKey returns the canonical cache key for req: a SHA-256 hex digest of
+// the request as it would be sent to the API, with object keys sorted.
+func CacheKey(req *UserRequest) (string, error) {
+	data, err := json.Marshal(normalizeUserRequestForAPI(req))
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	// Round-trip through generic values so every object, including ones from
+	// custom marshalers, is re-encoded with sorted keys.
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return "", fmt.Errorf("failed to canonicalize request: %w", err)
+	}
+	canonical, err := json.Marshal(generic)
+	if err != nil {
+		return "", fmt.Errorf("failed to canonicalize request: %w", err)
+	}
+
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ttlFor returns the TTL for req; the shortest TTL across its pages wins.
+func (cfg *CacheConfig) ttlFor(req *UserRequest) time.Duration {
+	if req == nil || len(req.Pages) == 0 {
+		return 0
+	}
+	var ttl time.Duration
+	for i, p := range req.Pages {
+		pageTTL := cfg.TTL
+		if v, ok := cfg.TTLByRenderType[ledgerRenderType(p.RenderType)]; ok {
+			pageTTL = v
+		}
+		if i == 0 || pageTTL < ttl {
+			ttl = pageTTL
+		}
+	}
+	return ttl
+}
+
+type cacheRecord struct {
+	StoredAt time.Time             `json:"storedAt"`
+	Response *UserResponseWithMeta `json:"response"`
+}
+
+// cacheLookup returns the cache key and TTL for req, and the cached response
+// when one is available and the context allows reading it. An empty key means
+// the request must not be cached.
+func (c *Client) cacheLookup(ctx context.Context, req *UserRequest) (string, time.Duration, *UserResponseWithMeta) {
+	mode := cacheModeFrom(ctx)
+	if c.cache == nil || mode == CacheBypass {
+		return "", 0, nil
+	}
+	ttl := c.cache.ttlFor(req)
+	if ttl <= 0 {
+		return "", 0, nil
+	}
+	key, err := CacheKey(req)
+	if err != nil {
+		return "", 0, nil
+	}
+	if mode == CacheRefresh {
+		return key, ttl, nil
+	}
+
+	data, ok := c.cache.Backend.Get(key)
+	if !ok {
+		return key, ttl, nil
+	}
+	var rec cacheRecord
+	if err := json.Unmarshal(data, &rec); err != nil || rec.Response == nil {
+		c.cache.Backend.Delete(key)
+		return key, ttl, nil
+	}
+	rec.Response.Metadata.Cached = true
+	rec.Response.Metadata.CachedAt = rec.StoredAt
+	return key, ttl, rec.Response
+}
+
+// cacheStore saves res under key. Responses that RetryConfig.IsBlocked
+// reports as blocked are never stored. Storage failures are not surfaced;
+// the response is still returned to the caller.
+func (c *Client) cacheStore(key string, ttl time.Duration, res *UserResponseWithMeta) {
+	if key == "" || res == nil {
+		return
+	}
+	if c.retryConfig != nil && c.retryConfig.IsBlocked != nil && c.retryConfig.IsBlocked(res) {
+		return
+	}
+	data, err := json.Marshal(cacheRecord{StoredAt: time.Now().UTC(), Response: res})
+	if err != nil {
+		return
+	}
+	_ = c.cache.Backend.Set(key, data, ttl)
+}
+
+// MemoryCache is an in-process Cache with least-recently-used eviction.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+	now        func() time.Time
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryCache creates a MemoryCache holding at most maxEntries responses.
+// A maxEntries of zero or less means no limit.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+// Get returns the value stored under key if it has not expired.
+func (m *MemoryCache) Get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*memoryEntry)
+	if !m.now().Before(e.expires) {
+		m.removeLocked(el)
+		return nil, false
+	}
+	m.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Set stores value under key for ttl.
+func (m *MemoryCache) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expires := m.now().Add(ttl)
+	if el, ok := m.entries[key]; ok {
+		e := el.Value.(*memoryEntry)
+		e.value, e.expires = value, expires
+		m.order.MoveToFront(el)
+		return nil
+	}
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for m.maxEntries > 0 && m.order.Len() > m.maxEntries {
+		m.removeLocked(m.order.Back())
+	}
+	return nil
+}
+
+// Delete removes key from the cache.
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.entries[key]; ok {
+		m.removeLocked(el)
+	}
+}
+
+// Len returns the number of stored entries, including expired ones not yet evicted.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+func (m *MemoryCache) removeLocked(el *list.Element) {
+	m.order.Remove(el)
+	delete(m.entries, el.Value.(*memoryEntry).key)
+}
+
+// DirCache is a Cache that stores one file per entry in a directory, so
+// cached renders survive process restarts.
+type DirCache struct {
+	dir string
+	now func() time.Time
+}
+
+type dirEntry struct {
+	Expires time.Time       `json:"expires"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// NewDirCache creates a DirCache rooted at dir, creating it if needed.
+func NewDirCache(dir string) (*DirCache, error) {
+	if dir == "" {
+		return nil, errors.New("cache directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &DirCache{dir: dir, now: time.Now}, nil
+}
+
+// Get returns the value stored under key if it has not expired.
+// Unreadable or expired entries are removed and reported as misses.
+func (d *DirCache) Get(key string) ([]byte, bool) {
+	path, ok := d.path(key)
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var e dirEntry
+	if err := json.Unmarshal(data, &e); err != nil || !d.now().Before(e.Expires) {
+		_ = os.Remove(path)
+		return nil, false
+	}
+	return e.Value, true
+}
+
+// Set stores value under key for ttl. The file is written atomically.
+func (d *DirCache) Set(key string, value []byte, ttl time.Duration) error {
+	path, ok := d.path(key)
+	if !ok {
+		return fmt.Errorf("invalid cache key %q", key)
+	}
+	data, err := json.Marshal(dirEntry{Expires: d.now().Add(ttl), Value: value})
+	if err != nil {
+		return fmt.Errorf("failed to encode cache entry: %w", err)
+	}
+	tmp, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Delete removes key from the cache.
+func (d *DirCache) Delete(key string) {
+	if path, ok := d.path(key); ok {
+		_ = os.Remove(path)
+	}
+}
+
+// path maps key to a file inside the cache directory, rejecting keys that
+// could escape it.
+func (d *DirCache) path(key string) (string, bool) {
+	if key == "" || strings.ContainsAny(key, `/\:.`) {
+		return "", false
+	}
+	return filepath.Join(d.dir, key+".json"), true
+}
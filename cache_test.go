@@ -0,0 +1,190 @@
+package phantomjscloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCountingServer(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("pjsc-billing-credit-cost", "1")
+		w.Write([]byte(`{"status":"success","pageResponses":[{"statusCode":200,"content":"ok"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestCacheKey_IsStableAcrossMapOrdering(t *testing.T) {
+	a := &UserRequest{Pages: []PageRequest{{
+		URL:             "https://example.com",
+		RequestSettings: RequestSettings{CustomHeaders: map[string]string{"a": "1", "b": "2", "c": "3"}},
+		Proxy:           ProxyBuiltin{Location: "us"},
+	}}}
+	b := &UserRequest{Pages: []PageRequest{{
+		URL:             "https://example.com",
+		RequestSettings: RequestSettings{CustomHeaders: map[string]string{"c": "3", "b": "2", "a": "1"}},
+		Proxy:           "anon-us",
+	}}}
+
+	ka, err := CacheKey(a)
+	if err != nil {
+		t.Fatalf("CacheKey failed: %v", err)
+	}
+	kb, err := CacheKey(b)
+	if err != nil {
+		t.Fatalf("CacheKey failed: %v", err)
+	}
+	if ka != kb {
+		t.Fatalf("expected equivalent requests to share a key: %s != %s", ka, kb)
+	}
+
+	c := &UserRequest{Pages: []PageRequest{{URL: "https://example.com", RenderType: "png"}}}
+	if kc, _ := CacheKey(c); kc == ka {
+		t.Fatal("expected different requests to have different keys")
+	}
+}
+
+func TestWithCache_ServesRepeatedRequests(t *testing.T) {
+	server, calls := newCountingServer(t)
+	ledger := NewLedger()
+	client := NewClient("test-key",
+		WithEndpoint(server.URL+"/"),
+		WithLedger(ledger),
+		WithCache(CacheConfig{Backend: NewMemoryCache(10), TTL: time.Minute}),
+	)
+	req := &PageRequest{URL: "https://example.com"}
+
+	first, err := client.DoPage(req)
+	if err != nil {
+		t.Fatalf("first call failed: %v", err)
+	}
+	if first.Metadata.Cached {
+		t.Fatal("expected first response to be live")
+	}
+	second, err := client.DoPage(req)
+	if err != nil {
+		t.Fatalf("second call failed: %v", err)
+	}
+	if !second.Metadata.Cached || second.Metadata.CachedAt.IsZero() {
+		t.Fatalf("expected cached response, got %+v", second.Metadata)
+	}
+	if second.PageResponses[0].Content != "ok" {
+		t.Fatalf("unexpected cached content %q", second.PageResponses[0].Content)
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Fatalf("expected a single API call, got %d", got)
+	}
+	if got := ledger.Snapshot().Total.Credits; got != 1 {
+		t.Fatalf("expected cache hit to spend no credits, ledger has %v", got)
+	}
+}
+
+func TestWithCache_ContextModes(t *testing.T) {
+	server, calls := newCountingServer(t)
+	client := NewClient("test-key",
+		WithEndpoint(server.URL+"/"),
+		WithCache(CacheConfig{Backend: NewMemoryCache(0), TTL: time.Minute}),
+	)
+	req := &PageRequest{URL: "https://example.com"}
+	bypass := WithCacheMode(context.Background(), CacheBypass)
+	refresh := WithCacheMode(context.Background(), CacheRefresh)
+
+	// Bypass neither reads nor writes.
+	if _, err := client.DoPageContext(bypass, req); err != nil {
+		t.Fatalf("bypass call failed: %v", err)
+	}
+	if res, _ := client.DoPage(req); res.Metadata.Cached {
+		t.Fatal("expected bypassed response not to be stored")
+	}
+	if res, _ := client.DoPageContext(bypass, req); res.Metadata.Cached {
+		t.Fatal("expected bypass to skip the cached entry")
+	}
+	// Refresh skips the entry but stores the fresh response.
+	if res, _ := client.DoPageContext(refresh, req); res.Metadata.Cached {
+		t.Fatal("expected refresh to call the API")
+	}
+	if res, _ := client.DoPage(req); !res.Metadata.Cached {
+		t.Fatal("expected default mode to hit the cache")
+	}
+	if got := atomic.LoadInt32(calls); got != 4 {
+		t.Fatalf("expected 4 API calls, got %d", got)
+	}
+}
+
+func TestWithCache_PerRenderTypeTTL(t *testing.T) {
+	server, calls := newCountingServer(t)
+	client := NewClient("test-key",
+		WithEndpoint(server.URL+"/"),
+		WithCache(CacheConfig{
+			Backend:         NewMemoryCache(0),
+			TTL:             time.Minute,
+			TTLByRenderType: map[string]time.Duration{"png": 0},
+		}),
+	)
+	req := &PageRequest{URL: "https://example.com", RenderType: "png"}
+	for i := 0; i < 2; i++ {
+		if _, err := client.DoPage(req); err != nil {
+			t.Fatalf("DoPage failed: %v", err)
+		}
+	}
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Fatalf("expected png caching to be disabled, got %d calls", got)
+	}
+}
+
+func TestMemoryCache_ExpiryAndEviction(t *testing.T) {
+	now := time.Now()
+	m := NewMemoryCache(2)
+	m.now = func() time.Time { return now }
+
+	m.Set("a", []byte("1"), time.Second)
+	m.Set("b", []byte("2"), time.Minute)
+	m.Get("a")
+	m.Set("c", []byte("3"), time.Minute)
+	if _, ok := m.Get("b"); ok {
+		t.Fatal("expected least recently used entry to be evicted")
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, ok := m.Get("a"); ok {
+		t.Fatal("expected expired entry to miss")
+	}
+	if v, ok := m.Get("c"); !ok || string(v) != "3" {
+		t.Fatalf("expected live entry, got %q %v", v, ok)
+	}
+}
+
+func TestDirCache_RoundTripAndExpiry(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDirCache(dir)
+	if err != nil {
+		t.Fatalf("NewDirCache failed: %v", err)
+	}
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	if err := d.Set("abc", []byte(`{"x":1}`), time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	reopened, _ := NewDirCache(dir)
+	reopened.now = d.now
+	if v, ok := reopened.Get("abc"); !ok || string(v) != `{"x":1}` {
+		t.Fatalf("expected persisted entry, got %q %v", v, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := reopened.Get("abc"); ok {
+		t.Fatal("expected expired entry to miss")
+	}
+	if err := d.Set("../escape", []byte(`1`), time.Minute); err == nil {
+		t.Fatal("expected path-like key to be rejected")
+	}
+}
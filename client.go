@@ -22,22 +22,6 @@ const (
 // ClientOption is a functional option for configuring a Client.
 type ClientOption func(*Client)
 
-// RetryConfig defines the strategy for automatic retries on transient errors.
-type RetryConfig struct {
-	MaxRetries      int
-	InitialInterval time.Duration
-	Multiplier      float64
-	MaxInterval     time.Duration
-}
-
-// DefaultRetryConfig provides a sensible default for most use cases.
-var DefaultRetryConfig = RetryConfig{
-	MaxRetries:      3,
-	InitialInterval: 1 * time.Second,
-	Multiplier:      2.0,
-	MaxInterval:     10 * time.Second,
-}
-
 // Interceptor allows modifying requests before they are sent or responses after they are received.
 type Interceptor func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error)
 
@@ -59,9 +43,13 @@ func WithTimeout(d time.Duration) ClientOption {
 	return func(c *Client) { c.httpClient.Timeout = d }
 }
 
-// WithRetry enables automatic retries for transient errors (429, 503, timeouts).
+// WithRetry enables automatic retries for transient errors (429, 5xx, timeouts).
+// See RetryConfig for jitter, Retry-After handling, per-class policies and budgets.
 func WithRetry(cfg RetryConfig) ClientOption {
-	return func(c *Client) { c.retryConfig = &cfg }
+	return func(c *Client) {
+		c.retryConfig = &cfg
+		c.retryBudget = newRetryBudget(cfg.Budget)
+	}
 }
 
 // WithInterceptor adds a middleware that can inspect/modify requests and responses.
@@ -72,9 +60,20 @@ func WithInterceptor(i Interceptor) ClientOption {
 // Client is a PhantomJsCloud API client.
 type Client struct {
 	apiKey       string
+	apiKeyHeader string
 	endpoint     string
 	httpClient   *http.Client
 	retryConfig  *RetryConfig
+	retryBudget  *retryBudget
+	limiter      *limiter
+	ledger       *Ledger
+	cache        *CacheConfig
+	flights      *flightGroup
+	observer     Observer
+	keyPool      *KeyPool
+	breakers     *breakerSet
+	hedger       *hedger
+	validate     bool
 	interceptors []Interceptor
 }
 
@@ -121,50 +120,146 @@ func (c *Client) Do(req *UserRequest) (*UserResponseWithMeta, error) {
 }
 
 // DoContext is like Do but honours the provided context for cancellation and deadlines.
-// It automatically handles retries if WithRetry was used during client initialization.
+// It automatically handles retries if WithRetry was used during client initialization,
+// and serves repeated requests from the cache if WithCache was used.
 func (c *Client) DoContext(ctx context.Context, req *UserRequest) (*UserResponseWithMeta, error) {
-	if c.apiKey == "" {
+	if c.apiKey == "" && c.keyPool == nil {
 		return nil, errors.New("API key is required")
 	}
-
-	if c.retryConfig == nil {
-		return c.doSingle(ctx, req)
+	if c.validate {
+		if err := req.Validate(); err != nil {
+			return nil, err
+		}
 	}
 
-	var lastErr error
-	cfg := c.retryConfig
-	interval := cfg.InitialInterval
+	obs := c.Observer()
+	start := time.Now()
+	obs.OnRequestStart(ctx, RequestStartEvent{Request: req, Time: start})
+	res, err := c.do(ctx, req, obs)
+	obs.OnRequestEnd(ctx, RequestEndEvent{Request: req, Response: res, Err: err, Duration: time.Since(start)})
+	return res, err
+}
 
-	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
-		res, err := c.doSingle(ctx, req)
+func (c *Client) do(ctx context.Context, req *UserRequest, obs Observer) (*UserResponseWithMeta, error) {
+	cacheKey, cacheTTL, cached := c.cacheLookup(ctx, req)
+	if cached != nil {
+		obs.OnCacheHit(ctx, CacheHitEvent{Request: req, Key: cacheKey, StoredAt: cached.Metadata.CachedAt})
+		return cached, nil
+	}
+
+	call := func(ctx context.Context) (*UserResponseWithMeta, error) {
+		var res *UserResponseWithMeta
+		var err error
+		if c.retryConfig == nil {
+			res, err = c.doAttempt(ctx, req, 0)
+		} else {
+			res, err = c.doWithRetry(ctx, req)
+		}
 		if err == nil {
-			return res, nil
+			c.cacheStore(cacheKey, cacheTTL, res)
 		}
+		return res, err
+	}
 
-		lastErr = err
+	if c.flights != nil {
+		key := cacheKey
+		if key == "" {
+			key, _ = CacheKey(req)
+		}
+		if key != "" {
+			return c.flights.do(ctx, key, call)
+		}
+	}
+	return call(ctx)
+}
 
-		// Only retry on transient errors (429, 503, or network/timeout)
-		if !isRetryable(err) || attempt == cfg.MaxRetries {
-			break
+// doSingle makes one attempt; attempt is its zero-based index within the call.
+// When sent is not nil, it is set once a request reaches the API.
+func (c *Client) doSingle(ctx context.Context, req *UserRequest, attempt int, sent *bool) (*UserResponseWithMeta, error) {
+	pass, err := c.breakers.admit(req)
+	if err != nil {
+		return nil, err
+	}
+	// res and err hold the API outcome once a request was sent; calls that
+	// stop earlier leave both nil and only release their breaker probes.
+	var res *UserResponseWithMeta
+	defer func() { pass.done(ctx, req, res, err) }()
+
+	if c.ledger != nil {
+		if err := c.ledger.Check(ctx, req); err != nil {
+			return nil, err
 		}
+	}
 
-		// Backoff
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case <-time.After(interval):
-			interval = time.Duration(float64(interval) * cfg.Multiplier)
-			if interval > cfg.MaxInterval {
-				interval = cfg.MaxInterval
+	release, acquireErr := c.limiter.acquire(ctx)
+	if acquireErr != nil {
+		return nil, acquireErr
+	}
+	defer release()
+
+	obs := c.Observer()
+	var lastErr error
+	// Each key is tried at most once per call, so failover cannot loop
+	// against the paid API.
+	tried := make(map[string]bool)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		key, keyID, ok := c.selectKey(tried)
+		if !ok {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w: %w", ErrNoAvailableKeys, lastErr)
 			}
+			return nil, ErrNoAvailableKeys
 		}
+
+		start := time.Now()
+		obs.OnAttemptStart(ctx, AttemptStartEvent{Request: req, Attempt: attempt, Time: start})
+		tried[key] = true
+		if sent != nil {
+			*sent = true
+		}
+		res, err = c.send(ctx, req, key)
+		err = redactError(err, key)
+		obs.OnAttemptEnd(ctx, AttemptEndEvent{Request: req, Attempt: attempt, Response: res, Err: err, Duration: time.Since(start)})
+		if err != nil {
+			if c.keyPool != nil && c.keyPool.fail(key, err) {
+				lastErr = err
+				continue
+			}
+			return nil, err
+		}
+		res.Metadata.KeyID = keyID
+		if c.keyPool != nil {
+			c.keyPool.record(key, creditCost(res))
+		}
+		break
+	}
+
+	if c.ledger != nil {
+		c.ledger.Record(ctx, req, res)
 	}
+	blocked := c.retryConfig != nil && c.retryConfig.IsBlocked != nil && c.retryConfig.IsBlocked(res)
+	obs.OnResponse(ctx, ResponseEvent{Request: req, Attempt: attempt, Response: res, Credits: creditCost(res), Blocked: blocked})
+	return res, nil
+}
 
-	return nil, lastErr
+// selectKey returns the key for the next HTTP call and its reportable ID.
+func (c *Client) selectKey(tried map[string]bool) (key, id string, ok bool) {
+	if c.keyPool == nil {
+		return c.apiKey, maskKey(c.apiKey), true
+	}
+	k, ok := c.keyPool.pick(tried)
+	return k.Key, k.ID, ok
 }
 
-func (c *Client) doSingle(ctx context.Context, req *UserRequest) (*UserResponseWithMeta, error) {
-	endpoint := c.endpoint + c.apiKey + "/"
+// send performs one HTTP round-trip to PhantomJsCloud with apiKey and decodes the response.
+func (c *Client) send(ctx context.Context, req *UserRequest, apiKey string) (*UserResponseWithMeta, error) {
+	endpoint := c.endpoint + apiKey + "/"
+	if c.apiKeyHeader != "" {
+		endpoint = c.endpoint
+	}
 	preparedReq := normalizeUserRequestForAPI(req)
 
 	// Since we might retry, we can't use io.Pipe easily if we want to avoid double encoding,
@@ -181,6 +276,9 @@ func (c *Client) doSingle(ctx context.Context, req *UserRequest) (*UserResponseW
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
+	if c.apiKeyHeader != "" {
+		httpReq.Header.Set(c.apiKeyHeader, apiKey)
+	}
 
 	// Apply interceptors
 	httpClientDo := c.httpClient.Do
@@ -203,9 +301,9 @@ func (c *Client) doSingle(ctx context.Context, req *UserRequest) (*UserResponseW
 		if err != nil {
 			return nil, fmt.Errorf("failed to read response body: %w", err)
 		}
-		err = fmt.Errorf("phantomjscloud returned HTTP Status %d: %s", httpResp.StatusCode, string(bodyBytes))
-		// Wrap with status code for retry logic
-		return nil, &httpError{StatusCode: httpResp.StatusCode, Err: err}
+		apiErr := newAPIError(httpResp.StatusCode, bodyBytes)
+		apiErr.RetryAfter = parseRetryAfter(httpResp.Header)
+		return nil, apiErr
 	}
 
 	bodyBytes, err := io.ReadAll(httpResp.Body)
@@ -251,28 +349,6 @@ func (c *Client) doSingle(ctx context.Context, req *UserRequest) (*UserResponseW
 	}, nil
 }
 
-type httpError struct {
-	StatusCode int
-	Err        error
-}
-
-func (e *httpError) Error() string { return e.Err.Error() }
-func (e *httpError) Unwrap() error { return e.Err }
-
-func isRetryable(err error) bool {
-	var hErr *httpError
-	if errors.As(err, &hErr) {
-		// 429 Too Many Requests, 503 Service Unavailable, 504 Gateway Timeout
-		return hErr.StatusCode == 429 || hErr.StatusCode == 503 || hErr.StatusCode == 504
-	}
-	// Also retry on network timeouts or connection refused
-	if errors.Is(err, context.DeadlineExceeded) {
-		return true
-	}
-	errStr := err.Error()
-	return strings.Contains(errStr, "timeout") || strings.Contains(errStr, "connection refused") || strings.Contains(errStr, "reset by peer")
-}
-
 // FetchPDF is a convenience method that returns the raw base64-decoded PDF bytes for a given URL.
 // It simplifies generating PDFs directly without handling the raw JSON wrapper.
 func (c *Client) FetchPDF(url string, overrideOptions *PdfOptions) ([]byte, error) {
@@ -287,21 +363,11 @@ func (c *Client) FetchPDF(url string, overrideOptions *PdfOptions) ([]byte, erro
 		}
 	}
 
-	res, err := c.DoPage(req)
+	res, err := c.Render(context.Background(), req)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(res.PageResponses) == 0 {
-		return nil, errors.New("no page response returned")
-	}
-
-	decoded, err := base64.StdEncoding.DecodeString(res.PageResponses[0].Content)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 content: %w", err)
-	}
-
-	return decoded, nil
+	return res.Bytes()
 }
 
 // FetchPlainText is a convenience method that returns the raw text context of the page, stripped of all HTML tags.
@@ -312,16 +378,11 @@ func (c *Client) FetchPlainText(url string) (string, error) {
 		RenderType: "plainText",
 	}
 
-	res, err := c.DoPage(req)
+	res, err := c.Render(context.Background(), req)
 	if err != nil {
 		return "", err
 	}
-
-	if len(res.PageResponses) == 0 {
-		return "", errors.New("no page response returned")
-	}
-
-	return res.PageResponses[0].Content, nil
+	return res.Text()
 }
 
 // FetchScreenshot is a convenience method that returns the raw base64-decoded image bytes.
@@ -339,21 +400,11 @@ func (c *Client) FetchScreenshot(url string, renderType string, renderSettings *
 		req.RenderSettings = *renderSettings
 	}
 
-	res, err := c.DoPage(req)
+	res, err := c.Render(context.Background(), req)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(res.PageResponses) == 0 {
-		return nil, errors.New("no page response returned")
-	}
-
-	decoded, err := base64.StdEncoding.DecodeString(res.PageResponses[0].Content)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 content: %w", err)
-	}
-
-	return decoded, nil
+	return res.Bytes()
 }
 
 // RenderRawHTML allows you to upload raw dynamic string HTML and render it natively through PhantomJS
@@ -375,24 +426,15 @@ func (c *Client) RenderRawHTML(html string, renderType string, renderSettings *R
 		req.RenderSettings = *renderSettings
 	}
 
-	res, err := c.DoPage(req)
+	res, err := c.Render(context.Background(), req)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(res.PageResponses) == 0 {
-		return nil, errors.New("no page response returned")
-	}
-
-	decoded, err := base64.StdEncoding.DecodeString(res.PageResponses[0].Content)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode base64 content: %w", err)
-	}
-
-	return decoded, nil
+	return res.Bytes()
 }
 
 // FetchWithAutomation executes a built overseerScript and automatically extracts the underlying arbitrary automationResult payload.
+// Use FetchAutomationAs to decode the payload into a typed value instead.
 func (c *Client) FetchWithAutomation(url string, builder *OverseerScriptBuilder) (interface{}, error) {
 	req := &PageRequest{
 		URL:            url,
@@ -401,20 +443,14 @@ func (c *Client) FetchWithAutomation(url string, builder *OverseerScriptBuilder)
 		OutputAsJson:   true,
 	}
 
-	res, err := c.DoPage(req)
+	res, err := c.Render(context.Background(), req)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(res.PageResponses) == 0 {
-		return nil, errors.New("no page response returned")
-	}
-
-	if res.PageResponses[0].AutomationResult != nil {
-		return res.PageResponses[0].AutomationResult, nil
+	if res.Page.AutomationResult == nil {
+		return nil, ErrNoAutomationResult
 	}
-
-	return nil, errors.New("automation result was omitted or empty in response")
+	return res.Page.AutomationResult, nil
 }
 
 // parseMetadata extracts PJSC specific headers from the response.
@@ -4,11 +4,15 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"image"
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+	"github.com/amafjarkasi/go-phantomjs/ext/viewport"
+	"github.com/amafjarkasi/go-phantomjs/ext/visual"
 )
 
 func main() {
@@ -23,6 +27,16 @@ func main() {
 	output := renderCmd.String("output", "html", "Output format (html, plainText, png, jpeg, pdf)")
 	file := renderCmd.String("file", "", "File path to save the output (for images/pdf)")
 
+	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
+	diffURL := diffCmd.String("url", "", "Target URL to screenshot")
+	preset := diffCmd.String("preset", "fhd", "Viewport preset ("+strings.Join(viewport.Names(), ", ")+") or WIDTHxHEIGHT")
+	baselines := diffCmd.String("baselines", "baselines", "Directory holding baseline PNGs")
+	threshold := diffCmd.Float64("threshold", visual.DefaultThreshold, "Per-pixel color distance (0-1) treated as a difference")
+	maxDiff := diffCmd.Float64("max-diff", 0, "Fraction of differing pixels allowed before failing")
+	ignore := diffCmd.String("ignore", "", "Regions to ignore as x0,y0,x1,y1 separated by semicolons")
+	countAA := diffCmd.Bool("count-aa", false, "Count anti-aliased pixels as differences")
+	update := diffCmd.Bool("update", false, "Replace the baseline with the current render")
+
 	if len(os.Args) < 2 {
 		printUsage()
 		os.Exit(1)
@@ -39,34 +53,28 @@ func main() {
 			os.Exit(1)
 		}
 
-		_, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 		defer cancel()
 
-		var result []byte
-		var err error
-		var textResult string
-
 		switch *output {
-		case "html":
-			textResult, err = client.FetchPlainText(*url) // FetchPlainText is misleadingly named in current client, it fetches content. Actually client has RenderRawHTML etc.
-			// Re-evaluating: client.DoPage is most versatile.
-			req := phantomjscloud.NewPageRequestBuilder(*url).WithRenderType("html").Build()
-			resp, err2 := client.DoPage(req)
-			if err2 == nil {
-				textResult = resp.PageResponses[0].Content
-			}
-			err = err2
-		case "plainText":
-			textResult, err = client.FetchPlainText(*url)
-		case "png", "jpeg":
-			result, err = client.FetchScreenshot(*url, *output, nil)
-		case "pdf":
-			result, err = client.FetchPDF(*url, nil)
+		case "html", "plainText", "png", "jpeg", "pdf":
 		default:
 			fmt.Printf("Unknown output format: %s\n", *output)
 			os.Exit(1)
 		}
 
+		res, err := client.Render(ctx, phantomjscloud.NewPageRequestBuilder(*url).WithRenderType(*output).Build())
+		if err != nil {
+			log.Fatalf("Render failed: %v", err)
+		}
+
+		var result []byte
+		var textResult string
+		if res.IsBinary() {
+			result, err = res.Bytes()
+		} else {
+			textResult, err = res.Text()
+		}
 		if err != nil {
 			log.Fatalf("Render failed: %v", err)
 		}
@@ -82,6 +90,57 @@ func main() {
 			fmt.Printf("Received %d bytes of binary data. Use -file to save it.\n", len(result))
 		}
 
+	case "diff":
+		diffCmd.Parse(os.Args[2:])
+		if *diffURL == "" {
+			fmt.Println("Error: -url is required")
+			diffCmd.Usage()
+			os.Exit(1)
+		}
+		p, ok := viewport.Lookup(*preset)
+		if !ok {
+			fmt.Printf("Unknown preset: %s\n", *preset)
+			os.Exit(1)
+		}
+		regions, err := parseRegions(*ignore)
+		if err != nil {
+			fmt.Printf("Invalid -ignore: %v\n", err)
+			os.Exit(1)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+		defer cancel()
+
+		checker := visual.NewChecker(client, visual.NewStore(*baselines), visual.Options{
+			Threshold:        *threshold,
+			MaxDiffRatio:     *maxDiff,
+			CountAntiAliased: *countAA,
+			Ignore:           regions,
+		})
+		check := checker.Check
+		if *update {
+			check = checker.UpdateBaseline
+		}
+		res, err := check(ctx, *diffURL, p)
+		if err != nil {
+			log.Fatalf("Diff failed: %v", err)
+		}
+
+		switch {
+		case res.Created:
+			fmt.Printf("Saved baseline to %s\n", res.BaselinePath)
+		case res.Passed():
+			fmt.Printf("PASS %s (%d of %d pixels differ)\n", res.Key, res.Comparison.DiffPixels, res.Comparison.TotalPixels)
+		default:
+			c := res.Comparison
+			if c.SizeMismatch {
+				fmt.Println("Screenshot size differs from the baseline.")
+			}
+			fmt.Printf("FAIL %s (%d of %d pixels differ, %.2f%%)\n", res.Key, c.DiffPixels, c.TotalPixels, c.Ratio*100)
+			fmt.Printf("Diff image written to %s\n", res.DiffPath)
+			os.Exit(1)
+		}
+
 	case "help":
 		printUsage()
 	default:
@@ -97,7 +156,26 @@ func printUsage() {
 	fmt.Println("  pjsc <command> [arguments]")
 	fmt.Println("\nCommands:")
 	fmt.Println("  render    Fetch and render a URL")
+	fmt.Println("  diff      Compare a screenshot with its stored baseline")
 	fmt.Println("  help      Show this help message")
 	fmt.Println("\nExample:")
 	fmt.Println("  pjsc render -url https://example.com -output png -file screenshot.png")
+	fmt.Println("  pjsc diff -url https://example.com -preset mobileportrait -baselines ./baselines")
+}
+
+// parseRegions parses "x0,y0,x1,y1;x0,y0,x1,y1" into rectangles.
+func parseRegions(s string) ([]image.Rectangle, error) {
+	var out []image.Rectangle
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		var x0, y0, x1, y1 int
+		if _, err := fmt.Sscanf(part, "%d,%d,%d,%d", &x0, &y0, &x1, &y1); err != nil {
+			return nil, fmt.Errorf("%q: %w", part, err)
+		}
+		out = append(out, image.Rect(x0, y0, x1, y1))
+	}
+	return out, nil
 }
@@ -0,0 +1,110 @@
+package phantomjscloud
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// WithRequestCoalescing makes concurrent identical calls share one API request.
+// Requests are identical when their CacheKey matches. Every waiter receives its
+// own copy of the response; waiters that joined an existing call see
+// ResponseMetadata.Coalesced set. A waiter whose context is cancelled returns
+// immediately, and the shared call is only cancelled once every waiter has gone.
+// The shared call carries the values (ledger tags, cache mode) of the first caller.
+func WithRequestCoalescing() ClientOption {
+	return func(c *Client) { c.flights = &flightGroup{calls: make(map[string]*flightCall)} }
+}
+
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done    chan struct{}
+	res     *UserResponseWithMeta
+	err     error
+	waiters int
+	dups    int
+	cancel  context.CancelFunc
+}
+
+// do runs fn once per key among concurrent callers. The shared call runs on a
+// context detached from every caller's cancellation and is cancelled once the
+// last waiter has left.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(context.Context) (*UserResponseWithMeta, error)) (*UserResponseWithMeta, error) {
+	g.mu.Lock()
+	call, joined := g.calls[key]
+	if joined {
+		call.waiters++
+		call.dups++
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &flightCall{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.calls[key] = call
+		go g.run(callCtx, key, call, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		g.leave(key, call)
+		return nil, ctx.Err()
+	}
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	if call.dups == 0 {
+		return call.res, nil
+	}
+	res := cloneResponse(call.res)
+	res.Metadata.Coalesced = joined
+	return res, nil
+}
+
+func (g *flightGroup) run(ctx context.Context, key string, call *flightCall, fn func(context.Context) (*UserResponseWithMeta, error)) {
+	defer call.cancel()
+	res, err := fn(ctx)
+
+	g.mu.Lock()
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+	call.res, call.err = res, err
+	g.mu.Unlock()
+	close(call.done)
+}
+
+// leave drops a waiter, cancelling the shared call when none remain.
+func (g *flightGroup) leave(key string, call *flightCall) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+	call.cancel()
+}
+
+// cloneResponse returns a deep copy of res so waiters sharing a call cannot
+// observe each other's mutations.
+func cloneResponse(res *UserResponseWithMeta) *UserResponseWithMeta {
+	if res == nil {
+		return nil
+	}
+	out := &UserResponseWithMeta{Metadata: res.Metadata}
+	data, err := json.Marshal(res.UserResponse)
+	if err == nil {
+		err = json.Unmarshal(data, &out.UserResponse)
+	}
+	if err != nil {
+		out.UserResponse = res.UserResponse
+	}
+	return out
+}
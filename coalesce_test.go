@@ -0,0 +1,168 @@
+package phantomjscloud
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newGatedServer blocks every request until release is closed and reports
+// through started when a request arrives and through aborted when the client
+// gave up on it.
+func newGatedServer(t *testing.T) (server *httptest.Server, calls *int32, started, aborted chan struct{}, release chan struct{}) {
+	t.Helper()
+	calls = new(int32)
+	started = make(chan struct{}, 10)
+	aborted = make(chan struct{}, 10)
+	release = make(chan struct{})
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Drain the body so the server notices when the client disconnects.
+		_, _ = io.Copy(io.Discard, r.Body)
+		atomic.AddInt32(calls, 1)
+		started <- struct{}{}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			aborted <- struct{}{}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","pageResponses":[{"statusCode":200,"content":"ok"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, calls, started, aborted, release
+}
+
+func waitForWaiters(t *testing.T, c *Client, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		c.flights.mu.Lock()
+		total := 0
+		for _, call := range c.flights.calls {
+			total += call.waiters
+		}
+		c.flights.mu.Unlock()
+		if total == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d waiters", n)
+}
+
+func TestWithRequestCoalescing_SharesOneCall(t *testing.T) {
+	server, calls, started, _, release := newGatedServer(t)
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRequestCoalescing())
+
+	const n = 5
+	results := make([]*UserResponseWithMeta, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res, err := client.DoPage(&PageRequest{URL: "https://example.com"})
+			if err != nil {
+				t.Errorf("DoPage failed: %v", err)
+				return
+			}
+			results[i] = res
+		}(i)
+	}
+	<-started
+	waitForWaiters(t, client, n)
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Fatalf("expected one API call, got %d", got)
+	}
+	coalesced := 0
+	for _, res := range results {
+		if res == nil {
+			t.Fatal("missing result")
+		}
+		if res.Metadata.Coalesced {
+			coalesced++
+		}
+	}
+	if coalesced != n-1 {
+		t.Fatalf("expected %d coalesced responses, got %d", n-1, coalesced)
+	}
+
+	results[0].PageResponses[0].Content = "mutated"
+	if results[1].PageResponses[0].Content != "ok" {
+		t.Fatal("expected waiters to receive independent copies")
+	}
+}
+
+func TestWithRequestCoalescing_CancelledWaiterDoesNotCancelSharedCall(t *testing.T) {
+	server, calls, started, aborted, release := newGatedServer(t)
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRequestCoalescing())
+	req := &PageRequest{URL: "https://example.com"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := client.DoPageContext(ctx, req)
+		firstErr <- err
+	}()
+	<-started
+
+	secondRes := make(chan *UserResponseWithMeta, 1)
+	go func() {
+		res, err := client.DoPage(req)
+		if err != nil {
+			t.Errorf("second waiter failed: %v", err)
+		}
+		secondRes <- res
+	}()
+	waitForWaiters(t, client, 2)
+
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Fatalf("expected cancelled waiter to return context.Canceled, got %v", err)
+	}
+	close(release)
+
+	res := <-secondRes
+	if res == nil || res.PageResponses[0].Content != "ok" {
+		t.Fatalf("expected remaining waiter to get the shared response, got %+v", res)
+	}
+	select {
+	case <-aborted:
+		t.Fatal("shared call was cancelled while a waiter remained")
+	default:
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Fatalf("expected one API call, got %d", got)
+	}
+}
+
+func TestWithRequestCoalescing_LastWaiterCancelsSharedCall(t *testing.T) {
+	server, _, started, aborted, release := newGatedServer(t)
+	defer close(release)
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRequestCoalescing())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		client.DoPageContext(ctx, &PageRequest{URL: "https://example.com"}) //nolint:errcheck
+		close(done)
+	}()
+	<-started
+	cancel()
+	<-done
+
+	select {
+	case <-aborted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected shared call to be cancelled once no waiters remained")
+	}
+}
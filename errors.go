@@ -0,0 +1,175 @@
+package phantomjscloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Sentinel errors for the failure classes PhantomJsCloud reports. An *APIError
+// matches the sentinel for its class, so callers can branch with errors.Is:
+//
+//	if errors.Is(err, phantomjscloud.ErrOutOfCredits) { ... }
+var (
+	ErrInvalidAPIKey = errors.New("phantomjscloud: invalid API key")
+	ErrOutOfCredits  = errors.New("phantomjscloud: out of credits")
+	ErrRateLimited   = errors.New("phantomjscloud: rate limited")
+	ErrRenderTimeout = errors.New("phantomjscloud: render timeout")
+	ErrBadRequest    = errors.New("phantomjscloud: bad request")
+)
+
+// APIError is returned when PhantomJsCloud answers with a non-2xx status.
+// It carries whatever could be parsed from the error JSON body; Body always
+// holds the raw payload.
+type APIError struct {
+	// StatusCode is the HTTP status of the API response.
+	StatusCode int
+	// Code is the error name or code reported by the API, e.g. "HttpStatusCodeException".
+	Code string
+	// Message is the human-readable error message reported by the API.
+	Message string
+	// PageIndex is the index of the page in UserRequest.Pages that caused the
+	// error, or -1 when the error is not tied to a page.
+	PageIndex int
+	// Body is the raw response body.
+	Body string
+	// RetryAfter is the wait requested by the server's Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	detail := e.Message
+	if detail == "" {
+		detail = e.Body
+	}
+	if e.Code != "" {
+		detail = e.Code + ": " + detail
+	}
+	if e.PageIndex >= 0 {
+		return fmt.Sprintf("phantomjscloud returned HTTP Status %d for page %d: %s", e.StatusCode, e.PageIndex, detail)
+	}
+	return fmt.Sprintf("phantomjscloud returned HTTP Status %d: %s", e.StatusCode, detail)
+}
+
+// Is reports whether target is the sentinel error for e's failure class.
+func (e *APIError) Is(target error) bool {
+	return target != nil && target == e.class()
+}
+
+// class maps the status code, and failing that the code and message, onto a sentinel.
+func (e *APIError) class() error {
+	switch e.StatusCode {
+	case 401:
+		return ErrInvalidAPIKey
+	case 402:
+		return ErrOutOfCredits
+	case 429:
+		return ErrRateLimited
+	case 408, 504:
+		return ErrRenderTimeout
+	}
+
+	text := strings.ToLower(e.Code + " " + e.Message)
+	if e.Message == "" {
+		text += " " + strings.ToLower(e.Body)
+	}
+	switch {
+	case strings.Contains(text, "api key") || strings.Contains(text, "apikey") || strings.Contains(text, "api-key"):
+		return ErrInvalidAPIKey
+	case strings.Contains(text, "credit") || strings.Contains(text, "quota"):
+		return ErrOutOfCredits
+	case strings.Contains(text, "rate limit") || strings.Contains(text, "too many requests"):
+		return ErrRateLimited
+	case strings.Contains(text, "timeout") || strings.Contains(text, "timed out"):
+		return ErrRenderTimeout
+	}
+
+	if e.StatusCode == 400 || e.StatusCode == 422 {
+		return ErrBadRequest
+	}
+	return nil
+}
+
+// newAPIError builds an APIError from a failed response, parsing the
+// PhantomJsCloud error JSON when the body contains one.
+func newAPIError(statusCode int, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: statusCode,
+		PageIndex:  -1,
+		Body:       string(body),
+	}
+
+	var payload struct {
+		Name          string          `json:"name"`
+		Code          json.RawMessage `json:"code"`
+		ErrorCode     json.RawMessage `json:"errorCode"`
+		Message       string          `json:"message"`
+		StatusMessage string          `json:"statusMessage"`
+		Error         string          `json:"error"`
+		PageIndex     *int            `json:"pageIndex"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return e
+	}
+
+	e.Code = firstNonEmpty(rawCode(payload.ErrorCode), rawCode(payload.Code), payload.Name)
+	e.Message = firstNonEmpty(payload.Message, payload.StatusMessage, payload.Error)
+	if payload.PageIndex != nil {
+		e.PageIndex = *payload.PageIndex
+	}
+	return e
+}
+
+// rawCode accepts error codes sent as either JSON strings or numbers.
+func rawCode(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// isNetworkError reports whether err is a transport failure worth retrying:
+// timeouts, refused or reset connections and truncated responses.
+func isNetworkError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
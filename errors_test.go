@@ -0,0 +1,118 @@
+package phantomjscloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+)
+
+func TestNewAPIError_ParsesErrorJSON(t *testing.T) {
+	body := []byte(`{"name":"HttpStatusCodeException","statusCode":400,"message":"invalid renderType","pageIndex":1}`)
+	err := newAPIError(400, body)
+
+	if err.Code != "HttpStatusCodeException" {
+		t.Errorf("expected code HttpStatusCodeException, got %q", err.Code)
+	}
+	if err.Message != "invalid renderType" {
+		t.Errorf("expected message, got %q", err.Message)
+	}
+	if err.PageIndex != 1 {
+		t.Errorf("expected page index 1, got %d", err.PageIndex)
+	}
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("expected ErrBadRequest classification")
+	}
+	if got := err.Error(); got != "phantomjscloud returned HTTP Status 400 for page 1: HttpStatusCodeException: invalid renderType" {
+		t.Errorf("unexpected error string: %q", got)
+	}
+}
+
+func TestNewAPIError_RawBody(t *testing.T) {
+	err := newAPIError(503, []byte("service unavailable"))
+	if err.PageIndex != -1 {
+		t.Errorf("expected page index -1, got %d", err.PageIndex)
+	}
+	if got := err.Error(); got != "phantomjscloud returned HTTP Status 503: service unavailable" {
+		t.Errorf("unexpected error string: %q", got)
+	}
+}
+
+func TestAPIError_Sentinels(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   error
+	}{
+		{401, `{"message":"unauthorized"}`, ErrInvalidAPIKey},
+		{403, `{"message":"invalid apiKey supplied"}`, ErrInvalidAPIKey},
+		{402, `{}`, ErrOutOfCredits},
+		{400, `{"message":"not enough credits remaining"}`, ErrOutOfCredits},
+		{429, ``, ErrRateLimited},
+		{504, ``, ErrRenderTimeout},
+		{500, `{"message":"page render timed out"}`, ErrRenderTimeout},
+		{400, `{"message":"bad json"}`, ErrBadRequest},
+	}
+	sentinels := []error{ErrInvalidAPIKey, ErrOutOfCredits, ErrRateLimited, ErrRenderTimeout, ErrBadRequest}
+
+	for _, tt := range tests {
+		err := fmt.Errorf("wrapped: %w", newAPIError(tt.status, []byte(tt.body)))
+		for _, s := range sentinels {
+			if got := errors.Is(err, s); got != (s == tt.want) {
+				t.Errorf("status %d body %q: errors.Is(%v) = %v", tt.status, tt.body, s, got)
+			}
+		}
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want RetryClass
+	}{
+		{"rate limited", newAPIError(429, nil), RetryClassRateLimited},
+		{"bad gateway", newAPIError(502, nil), RetryClassServerError},
+		{"service unavailable", newAPIError(503, nil), RetryClassServerError},
+		{"gateway timeout", newAPIError(504, nil), RetryClassServerError},
+		{"invalid key", newAPIError(401, nil), RetryClassNone},
+		{"bad request", newAPIError(400, nil), RetryClassNone},
+		{"server error", newAPIError(500, []byte("boom")), RetryClassNone},
+		{"connection refused", fmt.Errorf("http request failed: %w", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}), RetryClassNetwork},
+		{"reset", fmt.Errorf("http request failed: %w", syscall.ECONNRESET), RetryClassNetwork},
+		{"unexpected eof", fmt.Errorf("http request failed: %w", io.ErrUnexpectedEOF), RetryClassNetwork},
+		{"deadline", context.DeadlineExceeded, RetryClassNetwork},
+		{"cancelled", context.Canceled, RetryClassNone},
+		{"plain", errors.New("request timeout in body text"), RetryClassNone},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("%s: classifyError = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDoContext_ReturnsAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"name":"HttpStatusCodeException","statusCode":401,"message":"invalid apiKey"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("bad-key", WithEndpoint(server.URL+"/"))
+	_, err := client.DoPage(&PageRequest{URL: "https://example.com"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %T: %v", err, err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("unexpected api error: %#v", apiErr)
+	}
+}
@@ -0,0 +1,308 @@
+// Package har exports the network resources of PhantomJsCloud renders as
+// HAR 1.2 files, which browser devtools and HAR analyzers can open.
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+)
+
+// Version is the HAR format version written by this package.
+const Version = "1.2"
+
+// DefaultCreator is recorded as the creator of every HAR built by this package.
+var DefaultCreator = Creator{Name: "go-phantomjs", Version: "0.1.0"}
+
+// HAR is the root object of a HAR file.
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+// Log is the HAR log object.
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Pages   []Page  `json:"pages"`
+	Entries []Entry `json:"entries"`
+}
+
+// Creator names the application that produced the file.
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Page is one rendered page.
+type Page struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	ID              string      `json:"id"`
+	Title           string      `json:"title"`
+	PageTimings     PageTimings `json:"pageTimings"`
+}
+
+// PageTimings holds page load times in milliseconds, -1 when unknown.
+type PageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+// Entry is one request/response pair.
+type Entry struct {
+	Pageref         string   `json:"pageref,omitempty"`
+	StartedDateTime string   `json:"startedDateTime"`
+	Time            float64  `json:"time"`
+	Request         Request  `json:"request"`
+	Response        Response `json:"response"`
+	Cache           struct{} `json:"cache"`
+	Timings         Timings  `json:"timings"`
+	// Error carries the failure reason of failed requests, as Chrome does.
+	Error string `json:"_error,omitempty"`
+}
+
+// Request is the HAR request object.
+type Request struct {
+	Method      string          `json:"method"`
+	URL         string          `json:"url"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []NameValuePair `json:"cookies"`
+	Headers     []NameValuePair `json:"headers"`
+	QueryString []NameValuePair `json:"queryString"`
+	PostData    *PostData       `json:"postData,omitempty"`
+	HeadersSize int64           `json:"headersSize"`
+	BodySize    int64           `json:"bodySize"`
+}
+
+// Response is the HAR response object.
+type Response struct {
+	Status      int             `json:"status"`
+	StatusText  string          `json:"statusText"`
+	HTTPVersion string          `json:"httpVersion"`
+	Cookies     []NameValuePair `json:"cookies"`
+	Headers     []NameValuePair `json:"headers"`
+	Content     Content         `json:"content"`
+	RedirectURL string          `json:"redirectURL"`
+	HeadersSize int64           `json:"headersSize"`
+	BodySize    int64           `json:"bodySize"`
+}
+
+// NameValuePair is a header, cookie or query parameter.
+type NameValuePair struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PostData is a request body.
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// Content describes a response body.
+type Content struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Timings holds request phase durations in milliseconds, -1 when not applicable.
+type Timings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// FromPageResponse builds a HAR with one page from a page response.
+// Render with OutputAsJson to receive resources, and set
+// RequestSettings.RecordResourceBody to include response bodies.
+func FromPageResponse(p *phantomjscloud.PageResponse) (*HAR, error) {
+	h := newHAR()
+	if err := h.addPage(p, "page_1"); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// FromResponse builds a HAR with one page per page response of res.
+func FromResponse(res *phantomjscloud.UserResponseWithMeta) (*HAR, error) {
+	h := newHAR()
+	for i := range res.PageResponses {
+		if err := h.addPage(&res.PageResponses[i], fmt.Sprintf("page_%d", i+1)); err != nil {
+			return nil, fmt.Errorf("page %d: %w", i, err)
+		}
+	}
+	return h, nil
+}
+
+// WriteJSON writes h as indented JSON.
+func (h *HAR) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(h)
+}
+
+func newHAR() *HAR {
+	return &HAR{Log: Log{
+		Version: Version,
+		Creator: DefaultCreator,
+		Pages:   []Page{},
+		Entries: []Entry{},
+	}}
+}
+
+func (h *HAR) addPage(p *phantomjscloud.PageResponse, id string) error {
+	resources, err := p.NetworkResources()
+	if err != nil {
+		return err
+	}
+
+	started := time.Unix(0, 0).UTC()
+	for _, r := range resources {
+		if !r.Timing.Start.IsZero() {
+			started = r.Timing.Start
+			break
+		}
+	}
+
+	page := Page{
+		StartedDateTime: formatTime(started),
+		ID:              id,
+		Title:           pageTitle(p, resources),
+		PageTimings:     PageTimings{OnContentLoad: -1, OnLoad: -1},
+	}
+	if m := p.Metrics; m.PageLoadFinishTime > m.PageLoadStartTime && m.PageLoadStartTime > 0 {
+		page.PageTimings.OnLoad = float64(m.PageLoadFinishTime - m.PageLoadStartTime)
+	}
+	h.Log.Pages = append(h.Log.Pages, page)
+
+	for _, r := range resources {
+		h.Log.Entries = append(h.Log.Entries, entry(r, id, started))
+	}
+	return nil
+}
+
+func entry(r phantomjscloud.Resource, pageref string, fallback time.Time) Entry {
+	start := r.Timing.Start
+	if start.IsZero() {
+		start = fallback
+	}
+	ms := float64(r.Timing.Duration) / float64(time.Millisecond)
+
+	e := Entry{
+		Pageref:         pageref,
+		StartedDateTime: formatTime(start),
+		Time:            ms,
+		Request: Request{
+			Method:      r.Request.Method,
+			URL:         r.Request.URL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []NameValuePair{},
+			Headers:     pairs(r.Request.Headers),
+			QueryString: queryString(r.Request.URL),
+			HeadersSize: -1,
+			BodySize:    int64(len(r.Request.PostData)),
+		},
+		Response: Response{
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []NameValuePair{},
+			Headers:     []NameValuePair{},
+			Content:     Content{Size: max(r.Size, 0), MimeType: "x-unknown"},
+			HeadersSize: -1,
+			BodySize:    r.Size,
+		},
+		Timings: Timings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: ms},
+		Error:   r.FailureReason,
+	}
+	if r.Request.PostData != "" {
+		e.Request.PostData = &PostData{
+			MimeType: headerValue(r.Request.Headers, "Content-Type"),
+			Text:     r.Request.PostData,
+		}
+	}
+	if resp := r.Response; resp != nil {
+		e.Response.Status = resp.Status
+		e.Response.StatusText = resp.StatusText
+		e.Response.Headers = pairs(resp.Headers)
+		e.Response.RedirectURL = headerValue(resp.Headers, "Location")
+		if resp.ContentType != "" {
+			e.Response.Content.MimeType = resp.ContentType
+		}
+		e.Response.Content.Text = resp.Body
+		e.Response.Content.Encoding = resp.BodyEncoding
+		if r.Size < 0 && resp.Body != "" && resp.BodyEncoding == "" {
+			e.Response.Content.Size = int64(len(resp.Body))
+		}
+	}
+	return e
+}
+
+func pageTitle(p *phantomjscloud.PageResponse, resources []phantomjscloud.Resource) string {
+	if p.FrameData != nil && p.FrameData.Url != "" {
+		return p.FrameData.Url
+	}
+	if len(resources) > 0 {
+		return resources[0].Request.URL
+	}
+	return ""
+}
+
+func pairs(headers []phantomjscloud.Header) []NameValuePair {
+	out := make([]NameValuePair, 0, len(headers))
+	for _, h := range headers {
+		out = append(out, NameValuePair{Name: h.Name, Value: h.Value})
+	}
+	return out
+}
+
+func headerValue(headers []phantomjscloud.Header, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+func queryString(rawURL string) []NameValuePair {
+	out := []NameValuePair{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return out
+	}
+	return append(out, splitQuery(u.RawQuery)...)
+}
+
+// splitQuery keeps query parameters in URL order, which url.Values loses.
+func splitQuery(raw string) []NameValuePair {
+	var out []NameValuePair
+	for raw != "" {
+		var part string
+		part, raw, _ = strings.Cut(raw, "&")
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		if n, err := url.QueryUnescape(name); err == nil {
+			name = n
+		}
+		if v, err := url.QueryUnescape(value); err == nil {
+			value = v
+		}
+		out = append(out, NameValuePair{Name: name, Value: value})
+	}
+	return out
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z07:00")
+}
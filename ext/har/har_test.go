@@ -0,0 +1,83 @@
+package har
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+)
+
+const pageJSON = `{
+	"frameData":{"url":"https://example.com/"},
+	"metrics":{"pageLoadStartTime":1000,"pageLoadFinishTime":1800},
+	"resources":[
+		{"id":1,"request":{"url":"https://example.com/?q=red+shoes&page=2","method":"GET","headers":[{"name":"Accept","value":"text/html"}]},
+		 "response":{"status":301,"statusText":"Moved","headers":{"Location":"https://example.com/shoes","Content-Type":"text/html"},"bodySize":0},
+		 "startTime":"2024-01-02T03:04:05Z","duration":120},
+		{"id":2,"url":"https://tracker.example/pixel","startTime":"2024-01-02T03:04:05.500Z","errorText":"blocked"}
+	]}`
+
+func TestFromPageResponse(t *testing.T) {
+	var page phantomjscloud.PageResponse
+	if err := json.Unmarshal([]byte(pageJSON), &page); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := FromPageResponse(&page)
+	if err != nil {
+		t.Fatalf("FromPageResponse: %v", err)
+	}
+	if h.Log.Version != "1.2" || len(h.Log.Pages) != 1 || len(h.Log.Entries) != 2 {
+		t.Fatalf("unexpected log %+v", h.Log)
+	}
+	p := h.Log.Pages[0]
+	if p.Title != "https://example.com/" || p.StartedDateTime != "2024-01-02T03:04:05.000Z" || p.PageTimings.OnLoad != 800 {
+		t.Fatalf("unexpected page %+v", p)
+	}
+
+	first := h.Log.Entries[0]
+	if first.Pageref != "page_1" || first.Time != 120 || first.Response.Status != 301 {
+		t.Fatalf("unexpected entry %+v", first)
+	}
+	if first.Response.RedirectURL != "https://example.com/shoes" || first.Response.Content.MimeType != "text/html" {
+		t.Fatalf("unexpected response %+v", first.Response)
+	}
+	if len(first.Request.QueryString) != 2 || first.Request.QueryString[0].Value != "red shoes" {
+		t.Fatalf("unexpected query string %+v", first.Request.QueryString)
+	}
+
+	failed := h.Log.Entries[1]
+	if failed.Error != "blocked" || failed.Response.Status != 0 || failed.Response.BodySize != -1 {
+		t.Fatalf("unexpected failed entry %+v", failed)
+	}
+}
+
+func TestWriteJSON_RequiredFields(t *testing.T) {
+	res := &phantomjscloud.UserResponseWithMeta{}
+	res.PageResponses = []phantomjscloud.PageResponse{{}, {}}
+	h, err := FromResponse(res)
+	if err != nil {
+		t.Fatalf("FromResponse: %v", err)
+	}
+	if len(h.Log.Pages) != 2 || h.Log.Pages[1].ID != "page_2" {
+		t.Fatalf("expected one HAR page per page response, got %+v", h.Log.Pages)
+	}
+
+	var buf bytes.Buffer
+	if err := h.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	var decoded map[string]map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"version", "creator", "pages", "entries"} {
+		if _, ok := decoded["log"][key]; !ok {
+			t.Errorf("missing log.%s", key)
+		}
+	}
+	if entries, _ := decoded["log"]["entries"].([]interface{}); entries == nil {
+		t.Error("entries must be an array, not null")
+	}
+}
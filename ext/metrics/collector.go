@@ -0,0 +1,334 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+	"github.com/amafjarkasi/go-phantomjs/ext/blockpolicy"
+	"github.com/amafjarkasi/go-phantomjs/ext/proxy"
+)
+
+// DefaultMaxHosts is the default number of distinct host label values a
+// Collector tracks before folding further hosts into "other".
+const DefaultMaxHosts = 100
+
+// Collector is a phantomjscloud.Observer that records client, batch and
+// orchestration events as Prometheus metrics. Register it on a client with
+// phantomjscloud.WithObserver and serve it with ServeHTTP:
+//
+//	collector := metrics.NewCollector()
+//	client := phantomjscloud.NewClient(key, phantomjscloud.WithObserver(collector))
+//	http.Handle("/metrics", collector)
+//
+// Label values are bounded: hosts are normalized like ext/proxy and capped by
+// WithMaxHosts, render types outside the documented set become "other", and errors
+// are reduced to a fixed set of classes.
+type Collector struct {
+	registry *Registry
+	hosts    *hostLimiter
+
+	requests        *CounterVec
+	requestDuration *HistogramVec
+	attempts        *CounterVec
+	attemptDuration *HistogramVec
+	retries         *CounterVec
+	credits         *CounterVec
+	pages           *CounterVec
+	cacheHits       *CounterVec
+	orchestration   *CounterVec
+	batches         *CounterVec
+	batchPages      *CounterVec
+	batchDuration   *HistogramVec
+	proxyHealth     *GaugeVec
+	isBlocked       func(*phantomjscloud.UserResponseWithMeta) bool
+
+	mu      sync.Mutex
+	routers []*proxy.HealthRouter
+}
+
+var _ phantomjscloud.Observer = (*Collector)(nil)
+
+// Option configures a Collector.
+type Option func(*collectorConfig)
+
+type collectorConfig struct {
+	registry  *Registry
+	maxHosts  int
+	buckets   []float64
+	isBlocked func(*phantomjscloud.UserResponseWithMeta) bool
+}
+
+// WithRegistry registers the Collector's metrics on r instead of a new registry.
+func WithRegistry(r *Registry) Option {
+	return func(c *collectorConfig) { c.registry = r }
+}
+
+// WithMaxHosts caps the number of distinct host label values. Default is DefaultMaxHosts.
+func WithMaxHosts(n int) Option {
+	return func(c *collectorConfig) { c.maxHosts = n }
+}
+
+// WithBuckets overrides the latency histogram bounds, in seconds.
+func WithBuckets(b []float64) Option {
+	return func(c *collectorConfig) { c.buckets = b }
+}
+
+// WithIsBlocked sets how responses are classified for the blocked label of
+// pjsc_pages_total. Default is blockpolicy.LooksBlocked; nil leaves only the
+// client's RetryConfig.IsBlocked verdict.
+func WithIsBlocked(fn func(*phantomjscloud.UserResponseWithMeta) bool) Option {
+	return func(c *collectorConfig) { c.isBlocked = fn }
+}
+
+// NewCollector creates a Collector and registers its metrics.
+func NewCollector(opts ...Option) *Collector {
+	cfg := collectorConfig{maxHosts: DefaultMaxHosts, buckets: DefaultBuckets, isBlocked: blockpolicy.LooksBlocked}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.registry == nil {
+		cfg.registry = NewRegistry()
+	}
+	r := cfg.registry
+
+	c := &Collector{
+		registry:  r,
+		hosts:     newHostLimiter(cfg.maxHosts),
+		isBlocked: cfg.isBlocked,
+
+		requests:        r.Counter("pjsc_requests_total", "Calls made through Client.DoContext by outcome.", "render_type", "outcome"),
+		requestDuration: r.Histogram("pjsc_request_duration_seconds", "End-to-end Client.DoContext latency, including retries.", cfg.buckets, "render_type"),
+		attempts:        r.Counter("pjsc_attempts_total", "HTTP attempts sent to PhantomJsCloud by result.", "render_type", "result"),
+		attemptDuration: r.Histogram("pjsc_attempt_duration_seconds", "Latency of a single HTTP attempt.", cfg.buckets, "render_type"),
+		retries:         r.Counter("pjsc_retries_total", "Retries scheduled by failure class.", "class"),
+		credits:         r.Counter("pjsc_credits_total", "Credits billed by PhantomJsCloud.", "host", "render_type"),
+		pages:           r.Counter("pjsc_pages_total", "Pages in decoded responses by block verdict.", "host", "blocked"),
+		cacheHits:       r.Counter("pjsc_cache_hits_total", "Responses served from the client cache.", "render_type"),
+		orchestration:   r.Counter("pjsc_orchestration_attempts_total", "ext/scraper orchestration attempts by verdict.", "orchestrator", "host", "result"),
+		batches:         r.Counter("pjsc_batches_total", "BatchProcessor batch calls by result.", "result"),
+		batchPages:      r.Counter("pjsc_batch_pages_total", "Pages processed by BatchProcessor by result.", "result"),
+		batchDuration:   r.Histogram("pjsc_batch_duration_seconds", "Latency of a BatchProcessor batch call.", cfg.buckets),
+		proxyHealth:     r.Gauge("pjsc_proxy_health_penalty", "HealthRouter penalty score per host pool and proxy; 0 is healthy.", "host", "proxy"),
+	}
+	r.OnCollect(c.collectProxyHealth)
+	return c
+}
+
+// Registry returns the registry holding the Collector's metrics.
+func (c *Collector) Registry() *Registry { return c.registry }
+
+// ServeHTTP serves the registry in the Prometheus text format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.registry.ServeHTTP(w, r)
+}
+
+// WatchHealthRouter exports the router's proxy health scores on every scrape.
+func (c *Collector) WatchHealthRouter(r *proxy.HealthRouter) *Collector {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.routers = append(c.routers, r)
+	return c
+}
+
+func (c *Collector) collectProxyHealth() {
+	c.mu.Lock()
+	routers := append([]*proxy.HealthRouter(nil), c.routers...)
+	c.mu.Unlock()
+	if len(routers) == 0 {
+		return
+	}
+	c.proxyHealth.Reset()
+	for _, r := range routers {
+		for host, entries := range r.HealthSnapshot() {
+			label := host
+			if host != "default" {
+				label = c.hosts.label(host)
+			}
+			for _, e := range entries {
+				c.proxyHealth.Set(float64(e.Score), label, phantomjscloud.ProxyLabel(e.Proxy))
+			}
+		}
+	}
+}
+
+// OnRequestStart implements phantomjscloud.Observer.
+func (c *Collector) OnRequestStart(context.Context, phantomjscloud.RequestStartEvent) {}
+
+// OnRequestEnd implements phantomjscloud.Observer.
+func (c *Collector) OnRequestEnd(_ context.Context, e phantomjscloud.RequestEndEvent) {
+	rt := requestRenderType(e.Request)
+	outcome := errorClass(e.Err)
+	if e.Err == nil && e.Response != nil {
+		switch {
+		case e.Response.Metadata.Cached:
+			outcome = "cached"
+		case e.Response.Metadata.Coalesced:
+			outcome = "coalesced"
+		}
+	}
+	c.requests.Inc(rt, outcome)
+	c.requestDuration.Observe(e.Duration.Seconds(), rt)
+}
+
+// OnAttemptStart implements phantomjscloud.Observer.
+func (c *Collector) OnAttemptStart(context.Context, phantomjscloud.AttemptStartEvent) {}
+
+// OnAttemptEnd implements phantomjscloud.Observer.
+func (c *Collector) OnAttemptEnd(_ context.Context, e phantomjscloud.AttemptEndEvent) {
+	rt := requestRenderType(e.Request)
+	c.attempts.Inc(rt, errorClass(e.Err))
+	c.attemptDuration.Observe(e.Duration.Seconds(), rt)
+}
+
+// OnRetryScheduled implements phantomjscloud.Observer.
+func (c *Collector) OnRetryScheduled(_ context.Context, e phantomjscloud.RetryScheduledEvent) {
+	class := string(e.Class)
+	if class == "" {
+		class = "other"
+	}
+	c.retries.Inc(class)
+}
+
+// OnResponse implements phantomjscloud.Observer. Credits are split evenly
+// across the request's pages. A response is counted as blocked when the
+// client's RetryConfig.IsBlocked or the Collector's WithIsBlocked says so.
+func (c *Collector) OnResponse(_ context.Context, e phantomjscloud.ResponseEvent) {
+	if e.Request == nil || len(e.Request.Pages) == 0 {
+		return
+	}
+	perPage := e.Credits / float64(len(e.Request.Pages))
+	blocked := boolLabel(e.Blocked || (c.isBlocked != nil && e.Response != nil && c.isBlocked(e.Response)))
+	for _, p := range e.Request.Pages {
+		host := c.hosts.label(proxy.ExtractHost(p.URL))
+		c.credits.Add(perPage, host, renderType(p.RenderType))
+		c.pages.Inc(host, blocked)
+	}
+}
+
+// OnCacheHit implements phantomjscloud.Observer.
+func (c *Collector) OnCacheHit(_ context.Context, e phantomjscloud.CacheHitEvent) {
+	c.cacheHits.Inc(requestRenderType(e.Request))
+}
+
+// OnOrchestrationAttempt implements phantomjscloud.Observer.
+func (c *Collector) OnOrchestrationAttempt(_ context.Context, e phantomjscloud.OrchestrationAttemptEvent) {
+	result := errorClass(e.Err)
+	if e.Err == nil && e.Blocked {
+		result = "blocked"
+	}
+	c.orchestration.Inc(orchestratorLabel(e.Orchestrator), c.hosts.label(proxy.ExtractHost(e.URL)), result)
+}
+
+// OnBatch implements phantomjscloud.Observer.
+func (c *Collector) OnBatch(_ context.Context, e phantomjscloud.BatchEvent) {
+	c.batches.Inc(errorClass(e.Err))
+	c.batchPages.Add(float64(e.Pages-e.Failed), "ok")
+	c.batchPages.Add(float64(e.Failed), "failed")
+	c.batchDuration.Observe(e.Duration.Seconds())
+}
+
+// hostLimiter caps the number of distinct host label values.
+type hostLimiter struct {
+	mu   sync.Mutex
+	max  int
+	seen map[string]struct{}
+}
+
+func newHostLimiter(limit int) *hostLimiter {
+	return &hostLimiter{max: limit, seen: make(map[string]struct{})}
+}
+
+func (h *hostLimiter) label(host string) string {
+	if host == "" {
+		return "none"
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.seen[host]; ok {
+		return host
+	}
+	if len(h.seen) >= h.max {
+		return "other"
+	}
+	h.seen[host] = struct{}{}
+	return host
+}
+
+var knownRenderTypes = map[string]bool{
+	"html": true, "plainText": true, "jpeg": true, "jpg": true, "png": true,
+	"pdf": true, "json": true, "script": true, "automation": true,
+}
+
+func renderType(rt string) string {
+	if rt == "" {
+		return "html"
+	}
+	if knownRenderTypes[rt] {
+		return rt
+	}
+	return "other"
+}
+
+// requestRenderType returns the render type shared by every page, or "mixed".
+func requestRenderType(req *phantomjscloud.UserRequest) string {
+	if req == nil || len(req.Pages) == 0 {
+		return "none"
+	}
+	rt := renderType(req.Pages[0].RenderType)
+	for _, p := range req.Pages[1:] {
+		if renderType(p.RenderType) != rt {
+			return "mixed"
+		}
+	}
+	return rt
+}
+
+func orchestratorLabel(name string) string {
+	switch name {
+	case "adaptive", "routing", "challenge":
+		return name
+	}
+	return "other"
+}
+
+// errorClass reduces err to a bounded label value.
+func errorClass(err error) string {
+	var apiErr *phantomjscloud.APIError
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline"
+	case errors.Is(err, phantomjscloud.ErrBudgetExceeded):
+		return "budget_exceeded"
+	case errors.Is(err, phantomjscloud.ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, phantomjscloud.ErrInvalidRequest):
+		return "invalid_request"
+	case errors.Is(err, phantomjscloud.ErrInvalidAPIKey):
+		return "invalid_api_key"
+	case errors.Is(err, phantomjscloud.ErrOutOfCredits):
+		return "out_of_credits"
+	case errors.Is(err, phantomjscloud.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, phantomjscloud.ErrRenderTimeout):
+		return "render_timeout"
+	case errors.Is(err, phantomjscloud.ErrBadRequest):
+		return "bad_request"
+	case errors.As(err, &apiErr):
+		return "api_error"
+	}
+	return "error"
+}
+
+func boolLabel(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+	"github.com/amafjarkasi/go-phantomjs/ext/proxy"
+	"github.com/amafjarkasi/go-phantomjs/ext/scraper"
+)
+
+func scrape(t *testing.T, c *Collector) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestCollector_RecordsClientMetrics(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"message":"busy"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","billing":{"creditCost":2},"pageResponses":[{"statusCode":200,"content":"ok"}]}`))
+	}))
+	defer server.Close()
+
+	collector := NewCollector()
+	client := phantomjscloud.NewClient("test-key",
+		phantomjscloud.WithEndpoint(server.URL+"/"),
+		phantomjscloud.WithRetry(phantomjscloud.RetryConfig{MaxRetries: 1, InitialInterval: time.Millisecond}),
+		phantomjscloud.WithCache(phantomjscloud.CacheConfig{Backend: phantomjscloud.NewMemoryCache(0), TTL: time.Minute}),
+		phantomjscloud.WithObserver(collector),
+	)
+	req := &phantomjscloud.PageRequest{URL: "https://WWW.Example.com/item"}
+	for i := 0; i < 2; i++ {
+		if _, err := client.DoPage(req); err != nil {
+			t.Fatalf("DoPage failed: %v", err)
+		}
+	}
+
+	body := scrape(t, collector)
+	for _, want := range []string{
+		`pjsc_requests_total{render_type="html",outcome="ok"} 1`,
+		`pjsc_requests_total{render_type="html",outcome="cached"} 1`,
+		`pjsc_attempts_total{render_type="html",result="ok"} 1`,
+		`pjsc_attempts_total{render_type="html",result="api_error"} 1`,
+		`pjsc_retries_total{class="serverError"} 1`,
+		`pjsc_credits_total{host="example.com",render_type="html"} 2`,
+		`pjsc_pages_total{host="example.com",blocked="false"} 1`,
+		`pjsc_cache_hits_total{render_type="html"} 1`,
+		`pjsc_request_duration_seconds_count{render_type="html"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in exposition:\n%s", want, body)
+		}
+	}
+}
+
+func TestCollector_RecordsBatchMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","pageResponses":[{"statusCode":200},{"statusCode":404}]}`))
+	}))
+	defer server.Close()
+
+	collector := NewCollector()
+	client := phantomjscloud.NewClient("test-key", phantomjscloud.WithEndpoint(server.URL+"/"), phantomjscloud.WithObserver(collector))
+	bp := scraper.NewBatchProcessor(client, 1, 2)
+	if _, err := bp.ScrapeSimple(context.Background(), []string{"https://a.test", "https://b.test"}); err != nil {
+		t.Fatalf("ScrapeSimple failed: %v", err)
+	}
+
+	body := scrape(t, collector)
+	for _, want := range []string{
+		`pjsc_batches_total{result="ok"} 1`,
+		`pjsc_batch_pages_total{result="ok"} 1`,
+		`pjsc_batch_pages_total{result="failed"} 1`,
+		`pjsc_batch_duration_seconds_count 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in exposition:\n%s", want, body)
+		}
+	}
+}
+
+func TestCollector_OrchestrationAndProxyHealth(t *testing.T) {
+	collector := NewCollector(WithMaxHosts(1))
+	router := proxy.NewHealthRouter(phantomjscloud.ProxyAnonUS).
+		RouteHost("example.com", "custom-proxy.example:secret")
+	router.ReportFailure("https://example.com", "custom-proxy.example:secret")
+	collector.WatchHealthRouter(router)
+
+	ctx := context.Background()
+	collector.OnOrchestrationAttempt(ctx, phantomjscloud.OrchestrationAttemptEvent{Orchestrator: "challenge", URL: "https://example.com", Blocked: true})
+	collector.OnOrchestrationAttempt(ctx, phantomjscloud.OrchestrationAttemptEvent{Orchestrator: "challenge", URL: "https://other.test"})
+
+	body := scrape(t, collector)
+	for _, want := range []string{
+		`pjsc_orchestration_attempts_total{orchestrator="challenge",host="example.com",result="blocked"} 1`,
+		`pjsc_orchestration_attempts_total{orchestrator="challenge",host="other",result="ok"} 1`,
+		`pjsc_proxy_health_penalty{host="example.com",proxy="custom-proxy.example"} 1`,
+		`pjsc_proxy_health_penalty{host="default",proxy="anon-us"} 0`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in exposition:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "secret") {
+		t.Fatalf("proxy credentials leaked into labels:\n%s", body)
+	}
+}
+
+func TestCollector_ClassifiesBlockedPages(t *testing.T) {
+	ctx := context.Background()
+	req := &phantomjscloud.UserRequest{Pages: []phantomjscloud.PageRequest{{URL: "https://example.com"}}}
+	blocked := &phantomjscloud.UserResponseWithMeta{}
+	blocked.PageResponses = []phantomjscloud.PageResponse{{StatusCode: 403}}
+
+	// Without RetryConfig.IsBlocked, the block-page heuristic decides.
+	collector := NewCollector()
+	collector.OnResponse(ctx, phantomjscloud.ResponseEvent{Request: req, Response: blocked})
+	if body := scrape(t, collector); !strings.Contains(body, `pjsc_pages_total{host="example.com",blocked="true"} 1`) {
+		t.Errorf("expected a blocked page:\n%s", body)
+	}
+
+	collector = NewCollector(WithIsBlocked(nil))
+	collector.OnResponse(ctx, phantomjscloud.ResponseEvent{Request: req, Response: blocked})
+	collector.OnResponse(ctx, phantomjscloud.ResponseEvent{Request: req, Response: blocked, Blocked: true})
+	body := scrape(t, collector)
+	for _, want := range []string{
+		`pjsc_pages_total{host="example.com",blocked="false"} 1`,
+		`pjsc_pages_total{host="example.com",blocked="true"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in exposition:\n%s", want, body)
+		}
+	}
+}
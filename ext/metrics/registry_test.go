@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WritesPrometheusText(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("test_total", "A counter.", "host")
+	g := r.Gauge("test_gauge", "A gauge.")
+	h := r.Histogram("test_seconds", "A histogram.", []float64{1, 0.5}, "kind")
+
+	c.Inc("b.com")
+	c.Add(2, `a"quoted\`)
+	g.Set(3.5)
+	h.Observe(0.2, "x")
+	h.Observe(0.7, "x")
+	h.Observe(4, "x")
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	want := `# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge 3.5
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{kind="x",le="0.5"} 1
+test_seconds_bucket{kind="x",le="1"} 2
+test_seconds_bucket{kind="x",le="+Inf"} 3
+test_seconds_sum{kind="x"} 4.9
+test_seconds_count{kind="x"} 3
+# HELP test_total A counter.
+# TYPE test_total counter
+test_total{host="a\"quoted\\"} 2
+test_total{host="b.com"} 1
+`
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegistry_ReadsDoNotCreateSeries(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("reads_total", "Reads.", "k")
+	if v := c.Value("missing"); v != 0 {
+		t.Fatalf("expected 0, got %v", v)
+	}
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if strings.Contains(b.String(), "missing") {
+		t.Fatalf("expected no series to be created by Value, got:\n%s", b.String())
+	}
+}
+
+func TestRegistry_ReRegistering(t *testing.T) {
+	r := NewRegistry()
+	c := r.Counter("jobs_total", "Jobs.", "queue")
+	if again := r.Counter("jobs_total", "Jobs.", "queue"); again.f != c.f {
+		t.Fatal("expected the existing counter to be returned")
+	}
+
+	mustPanic := func(name string, register func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%s: expected a panic", name)
+			}
+		}()
+		register()
+	}
+	mustPanic("kind", func() { r.Gauge("jobs_total", "Jobs.", "queue") })
+	mustPanic("histogram", func() { r.Histogram("jobs_total", "Jobs.", nil, "queue") })
+	mustPanic("labels", func() { r.Counter("jobs_total", "Jobs.", "host") })
+}
+
+func TestHostLimiter_FoldsOverflowIntoOther(t *testing.T) {
+	h := newHostLimiter(2)
+	h.label("a.com")
+	h.label("b.com")
+	if got := h.label("c.com"); got != "other" {
+		t.Fatalf("expected overflow host to fold into other, got %q", got)
+	}
+	if got := h.label("a.com"); got != "a.com" {
+		t.Fatalf("expected known host to keep its label, got %q", got)
+	}
+	if got := h.label(""); got != "none" {
+		t.Fatalf("expected empty host to be none, got %q", got)
+	}
+}
@@ -154,6 +154,35 @@ func (r *HealthRouter) HealthForURL(rawURL string) []ProxyHealth {
 	return out
 }
 
+// HealthSnapshot returns the current health of every routed pool, keyed by
+// host. The default pool is keyed by "default". Pools are sorted as in HealthForURL.
+func (r *HealthRouter) HealthSnapshot() map[string][]ProxyHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make(map[string][]ProxyHealth, len(r.byHost)+1)
+	add := func(key string, pool []interface{}) {
+		if len(pool) == 0 {
+			return
+		}
+		scores := r.healthByHost[key]
+		entries := make([]ProxyHealth, 0, len(pool))
+		for _, idx := range rankPoolByHealth(pool, scores) {
+			entries = append(entries, ProxyHealth{Proxy: pool[idx], Score: scoreForProxy(scores, pool[idx])})
+		}
+		name := key
+		if key == "__default__" {
+			name = "default"
+		}
+		out[name] = entries
+	}
+	add("__default__", r.defaultProxies)
+	for host, pool := range r.byHost {
+		add(host, pool)
+	}
+	return out
+}
+
 func (r *HealthRouter) poolForHost(host string) ([]interface{}, string) {
 	if host != "" {
 		if p, ok := r.byHost[host]; ok {
@@ -100,3 +100,20 @@ func TestHealthRouter_ReportSuccess_DecreasesPenalty(t *testing.T) {
 		t.Fatalf("expected p1 score reduced to 1, got %d", p1Score)
 	}
 }
+
+func TestHealthRouter_HealthSnapshot_CoversEveryPool(t *testing.T) {
+	r := NewHealthRouter("d1").RouteHost("example.com", "p1", "p2")
+	r.ReportFailure("https://example.com", "p1")
+	r.ReportFailure("https://other.test", "d1")
+
+	snap := r.HealthSnapshot()
+	if len(snap) != 2 {
+		t.Fatalf("expected 2 pools, got %#v", snap)
+	}
+	if got := snap["example.com"]; len(got) != 2 || got[1].Proxy != "p1" || got[1].Score != 1 {
+		t.Fatalf("unexpected example.com health: %#v", got)
+	}
+	if got := snap["default"]; len(got) != 1 || got[0].Score != 1 {
+		t.Fatalf("unexpected default health: %#v", got)
+	}
+}
@@ -109,6 +109,13 @@ func (r *HostRouter) poolForHost(host string) ([]interface{}, string) {
 	return r.defaultProxies, "__default__"
 }
 
+// ExtractHost returns the normalized host used for routing decisions:
+// lowercased, without scheme, port, path or a leading "www.".
+// Other packages use it so per-host data lines up with proxy routing.
+func ExtractHost(rawURL string) string {
+	return extractHost(rawURL)
+}
+
 func extractHost(rawURL string) string {
 	if rawURL == "" {
 		return ""
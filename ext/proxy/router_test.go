@@ -42,3 +42,17 @@ func TestHostRouter_DefaultPool(t *testing.T) {
 		t.Fatalf("expected default proxy d2, got %v", got)
 	}
 }
+
+func TestExtractHost_Normalizes(t *testing.T) {
+	cases := map[string]string{
+		"https://WWW.Example.com:8443/path?q=1": "example.com",
+		"example.com/products":                  "example.com",
+		"http://shop.example.com":               "shop.example.com",
+		"":                                      "",
+	}
+	for in, want := range cases {
+		if got := ExtractHost(in); got != want {
+			t.Errorf("ExtractHost(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
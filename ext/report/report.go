@@ -0,0 +1,382 @@
+// Package report renders html/template documents into self-contained HTML
+// for RenderRawHTML-style renders. Local stylesheets, fonts and images are
+// inlined from an fs.FS, so the renderer never needs to reach internal asset
+// hosts.
+package report
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+)
+
+// BlankURL is the page URL used for content renders, as RenderRawHTML does.
+const BlankURL = "http://localhost/blank"
+
+const (
+	// DefaultMaxContentSize caps the final document when Config.MaxContentSize is zero.
+	DefaultMaxContentSize = 10 << 20
+	// DefaultMaxAssetSize caps each inlined asset when Config.MaxAssetSize is zero.
+	DefaultMaxAssetSize = 5 << 20
+)
+
+// ErrTooLarge is returned when an asset or the final document exceeds its size limit.
+var ErrTooLarge = errors.New("report: too large")
+
+// Config configures a Renderer.
+type Config struct {
+	// Assets holds the local files templates reference, such as css/app.css
+	// or img/logo.png. Leading slashes in references are ignored.
+	Assets fs.FS
+	// MaxContentSize caps the final HTML in bytes. Defaults to DefaultMaxContentSize.
+	MaxContentSize int
+	// MaxAssetSize caps each inlined asset in bytes. Defaults to DefaultMaxAssetSize.
+	MaxAssetSize int
+	// PublicHosts lists remote hosts known to be reachable from PhantomJsCloud.
+	// References to other remote hosts produce a warning.
+	PublicHosts []string
+}
+
+// Warning describes a reference that may not resolve when rendered.
+type Warning struct {
+	Ref     string
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Ref + ": " + w.Message
+}
+
+// Document is a rendered, self-contained HTML document.
+type Document struct {
+	Content string
+	// Assets lists each inlined asset path once.
+	Assets   []string
+	Warnings []Warning
+}
+
+// Size returns the length of Content in bytes.
+func (d *Document) Size() int {
+	return len(d.Content)
+}
+
+// PageRequest returns a request that renders the document with renderType.
+func (d *Document) PageRequest(renderType string) *phantomjscloud.PageRequest {
+	return &phantomjscloud.PageRequest{URL: BlankURL, Content: d.Content, RenderType: renderType}
+}
+
+// PDFRequest returns a pdf render request for the document using opts.
+func (d *Document) PDFRequest(opts phantomjscloud.PdfOptions) *phantomjscloud.PageRequest {
+	req := d.PageRequest("pdf")
+	req.RenderSettings.PdfOptions = &opts
+	return req
+}
+
+// Renderer executes templates and inlines their assets.
+type Renderer struct {
+	cfg Config
+}
+
+// NewRenderer creates a Renderer.
+func NewRenderer(cfg Config) *Renderer {
+	if cfg.MaxContentSize <= 0 {
+		cfg.MaxContentSize = DefaultMaxContentSize
+	}
+	if cfg.MaxAssetSize <= 0 {
+		cfg.MaxAssetSize = DefaultMaxAssetSize
+	}
+	return &Renderer{cfg: cfg}
+}
+
+// Funcs returns template functions backed by the renderer's assets. Add them
+// with template.Funcs before parsing:
+//
+//	asset "img/logo.png"  a data URI, usable in src and href attributes
+func (r *Renderer) Funcs() template.FuncMap {
+	return template.FuncMap{
+		"asset": func(name string) (template.URL, error) {
+			uri, err := r.dataURI(name)
+			return template.URL(uri), err
+		},
+	}
+}
+
+// Render executes tmpl with data and inlines every local asset the output
+// references.
+func (r *Renderer) Render(tmpl *template.Template, data interface{}) (*Document, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("report: %w", err)
+	}
+	return r.Inline(buf.String())
+}
+
+// Inline inlines the local assets referenced by an HTML document.
+func (r *Renderer) Inline(doc string) (*Document, error) {
+	in := &inliner{r: r, seen: map[string]bool{}, inlining: map[string]bool{}}
+	content, err := in.html(doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > r.cfg.MaxContentSize {
+		return nil, fmt.Errorf("%w: document is %d bytes, limit %d", ErrTooLarge, len(content), r.cfg.MaxContentSize)
+	}
+	return &Document{Content: content, Assets: in.assets, Warnings: in.warnings}, nil
+}
+
+var (
+	tagRe        = regexp.MustCompile(`(?is)<(img|script|link|source|video|audio|input|image|use|embed|object|iframe)\b[^>]*>`)
+	attrRe       = regexp.MustCompile(`(?is)\b(src|href|xlink:href|poster|data)\s*=\s*("[^"]*"|'[^']*')`)
+	relRe        = regexp.MustCompile(`(?is)\brel\s*=\s*["']?([^"'>]*)`)
+	styleBlockRe = regexp.MustCompile(`(?is)(<style\b[^>]*>)(.*?)(</style>)`)
+	styleAttrRe  = regexp.MustCompile(`(?is)\bstyle\s*=\s*("[^"]*"|'[^']*')`)
+	cssURLRe     = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^)\s]*))\s*\)`)
+	cssImportRe  = regexp.MustCompile(`(?i)@import\s+(?:"([^"]*)"|'([^']*)')`)
+)
+
+type inliner struct {
+	r        *Renderer
+	assets   []string
+	seen     map[string]bool
+	inlining map[string]bool
+	warnings []Warning
+	err      error
+}
+
+func (in *inliner) html(doc string) (string, error) {
+	doc = styleBlockRe.ReplaceAllStringFunc(doc, func(m string) string {
+		parts := styleBlockRe.FindStringSubmatch(m)
+		return parts[1] + in.css(parts[2], "") + parts[3]
+	})
+	doc = styleAttrRe.ReplaceAllStringFunc(doc, func(m string) string {
+		value := styleAttrRe.FindStringSubmatch(m)[1]
+		quote := value[:1]
+		css := in.css(html.UnescapeString(value[1:len(value)-1]), "")
+		return "style=" + quote + escapeAttr(css, quote) + quote
+	})
+	doc = tagRe.ReplaceAllStringFunc(doc, in.tag)
+	return doc, in.err
+}
+
+func (in *inliner) tag(tag string) string {
+	name := strings.ToLower(tagRe.FindStringSubmatch(tag)[1])
+	if name == "link" {
+		rel := ""
+		if m := relRe.FindStringSubmatch(tag); m != nil {
+			rel = strings.ToLower(m[1])
+		}
+		if strings.Contains(rel, "stylesheet") {
+			if m := attrRe.FindStringSubmatch(tag); m != nil {
+				ref := html.UnescapeString(m[2][1 : len(m[2])-1])
+				if data, name, ok := in.read(ref, ""); ok {
+					if css, ok := in.stylesheet(ref, name, data); ok {
+						return "<style>" + css + "</style>"
+					}
+				}
+			}
+			return tag
+		}
+	}
+	return attrRe.ReplaceAllStringFunc(tag, func(m string) string {
+		parts := attrRe.FindStringSubmatch(m)
+		quote := parts[2][:1]
+		ref := html.UnescapeString(parts[2][1 : len(parts[2])-1])
+		uri, ok := in.uri(ref, "")
+		if !ok {
+			return m
+		}
+		return parts[1] + "=" + quote + escapeAttr(uri, quote) + quote
+	})
+}
+
+// css inlines url() and @import references in a stylesheet. base is the
+// stylesheet's own asset path, which relative references resolve against.
+func (in *inliner) css(css, base string) string {
+	css = cssImportRe.ReplaceAllStringFunc(css, func(m string) string {
+		parts := cssImportRe.FindStringSubmatch(m)
+		ref := parts[1] + parts[2]
+		if uri, ok := in.uri(ref, base); ok {
+			return "@import url(" + quoteCSS(uri) + ")"
+		}
+		return m
+	})
+	return cssURLRe.ReplaceAllStringFunc(css, func(m string) string {
+		parts := cssURLRe.FindStringSubmatch(m)
+		ref := parts[1] + parts[2] + parts[3]
+		if uri, ok := in.uri(ref, base); ok {
+			return "url(" + quoteCSS(uri) + ")"
+		}
+		return m
+	})
+}
+
+// stylesheet inlines the references in the stylesheet name. It reports false
+// for a stylesheet that is already being inlined, so import cycles end.
+func (in *inliner) stylesheet(ref, name string, data []byte) (string, bool) {
+	if in.inlining[name] {
+		in.warn(ref, "stylesheet import cycle; reference left as is")
+		return "", false
+	}
+	in.inlining[name] = true
+	defer delete(in.inlining, name)
+	return in.css(string(data), name), true
+}
+
+// uri returns the data URI for a local reference. It reports false for
+// references that are left as they are, warning about remote ones.
+func (in *inliner) uri(ref, base string) (string, bool) {
+	data, name, ok := in.read(ref, base)
+	if !ok {
+		return "", false
+	}
+	if mimeType(name) == "text/css" {
+		css, ok := in.stylesheet(ref, name, data)
+		if !ok {
+			return "", false
+		}
+		data = []byte(css)
+	}
+	uri := "data:" + mimeType(name) + ";base64," + base64.StdEncoding.EncodeToString(data)
+	if _, frag, found := strings.Cut(ref, "#"); found {
+		uri += "#" + frag
+	}
+	return uri, true
+}
+
+// read loads a local reference from the asset FS.
+func (in *inliner) read(ref, base string) ([]byte, string, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return nil, "", false
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		in.warn(ref, "unparseable reference")
+		return nil, "", false
+	}
+	switch {
+	case u.Scheme == "http" || u.Scheme == "https" || strings.HasPrefix(ref, "//"):
+		if !in.public(u.Hostname()) {
+			in.warn(ref, "remote reference; the renderer fetches it and it must be publicly reachable")
+		}
+		return nil, "", false
+	case u.Scheme != "":
+		// data:, mailto:, javascript: and similar need no inlining.
+		return nil, "", false
+	}
+	if in.r.cfg.Assets == nil {
+		in.warn(ref, "local reference but no asset FS is configured")
+		return nil, "", false
+	}
+
+	name := u.Path
+	if base != "" && !strings.HasPrefix(name, "/") {
+		name = path.Join(path.Dir(base), name)
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	data, err := in.r.readAsset(name)
+	if err != nil {
+		if errors.Is(err, ErrTooLarge) && in.err == nil {
+			in.err = err
+		}
+		in.warn(ref, strings.TrimPrefix(err.Error(), "report: "))
+		return nil, "", false
+	}
+	if !in.seen[name] {
+		in.seen[name] = true
+		in.assets = append(in.assets, name)
+	}
+	return data, name, true
+}
+
+func (in *inliner) warn(ref, msg string) {
+	for _, w := range in.warnings {
+		if w.Ref == ref && w.Message == msg {
+			return
+		}
+	}
+	in.warnings = append(in.warnings, Warning{Ref: ref, Message: msg})
+}
+
+func (in *inliner) public(host string) bool {
+	for _, h := range in.r.cfg.PublicHosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Renderer) readAsset(name string) ([]byte, error) {
+	info, err := fs.Stat(r.cfg.Assets, name)
+	if err != nil {
+		return nil, fmt.Errorf("report: asset %q not found", name)
+	}
+	if info.Size() > int64(r.cfg.MaxAssetSize) {
+		return nil, fmt.Errorf("%w: asset %q is %d bytes, limit %d", ErrTooLarge, name, info.Size(), r.cfg.MaxAssetSize)
+	}
+	data, err := fs.ReadFile(r.cfg.Assets, name)
+	if err != nil {
+		return nil, fmt.Errorf("report: asset %q: %w", name, err)
+	}
+	return data, nil
+}
+
+func (r *Renderer) dataURI(name string) (string, error) {
+	if r.cfg.Assets == nil {
+		return "", errors.New("report: no asset FS is configured")
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	data, err := r.readAsset(name)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + mimeType(name) + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+var mimeTypes = map[string]string{
+	".css":   "text/css",
+	".js":    "text/javascript",
+	".svg":   "image/svg+xml",
+	".png":   "image/png",
+	".jpg":   "image/jpeg",
+	".jpeg":  "image/jpeg",
+	".gif":   "image/gif",
+	".webp":  "image/webp",
+	".ico":   "image/x-icon",
+	".woff":  "font/woff",
+	".woff2": "font/woff2",
+	".ttf":   "font/ttf",
+	".otf":   "font/otf",
+}
+
+func mimeType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if t, ok := mimeTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+func escapeAttr(s, quote string) string {
+	s = strings.ReplaceAll(s, "&", "&amp;")
+	return strings.ReplaceAll(s, quote, html.EscapeString(quote))
+}
+
+func quoteCSS(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
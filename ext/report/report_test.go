@@ -0,0 +1,112 @@
+package report
+
+import (
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+var assets = fstest.MapFS{
+	"css/app.css":        {Data: []byte(`@import "print.css"; body { font-family: Inter; background: url(../img/bg.png) }`)},
+	"css/print.css":      {Data: []byte(`@page { margin: 1cm }`)},
+	"fonts/inter.woff2":  {Data: []byte("wOF2")},
+	"img/bg.png":         {Data: []byte("\x89PNG")},
+	"img/logo.svg":       {Data: []byte("<svg/>")},
+	"img/huge.png":       {Data: make([]byte, 2048)},
+	"templates/unused.t": {Data: []byte("x")},
+}
+
+func TestRender_InlinesAssets(t *testing.T) {
+	r := NewRenderer(Config{Assets: assets, PublicHosts: []string{"fonts.googleapis.com"}})
+	tmpl := template.Must(template.New("invoice").Funcs(r.Funcs()).Parse(`<html><head>
+<link rel="stylesheet" href="/css/app.css">
+<link rel="stylesheet" href="https://fonts.googleapis.com/css?family=Inter">
+<style>@font-face { font-family: Inter; src: url('fonts/inter.woff2') format('woff2') }</style>
+</head><body>
+<img src="img/logo.svg" alt="{{.Customer}}">
+<img src="{{asset "img/bg.png"}}">
+<img src="https://assets.internal.example/logo.png">
+<img src="img/missing.png">
+<div style="background-image: url(img/bg.png)">Total: {{.Total}}</div>
+<a href="https://example.com/terms">terms</a>
+</body></html>`))
+
+	doc, err := r.Render(tmpl, map[string]string{"Customer": "Acme & Co", "Total": "$10"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	dataURI := func(mime, data string) string {
+		return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString([]byte(data))
+	}
+	for _, want := range []string{
+		`<style>@import url("` + dataURI("text/css", "@page { margin: 1cm }") + `")`,
+		`background: url("` + dataURI("image/png", "\x89PNG") + `")`,
+		`src: url("` + dataURI("font/woff2", "wOF2") + `")`,
+		`<img src="` + dataURI("image/svg+xml", "<svg/>") + `" alt="Acme &amp; Co">`,
+		`<img src="` + dataURI("image/png", "\x89PNG") + `">`,
+		`style="background-image: url(&#34;` + dataURI("image/png", "\x89PNG") + `&#34;)"`,
+		`<a href="https://example.com/terms">`,
+		`href="https://fonts.googleapis.com/css?family=Inter"`,
+	} {
+		if !strings.Contains(doc.Content, want) {
+			t.Errorf("content missing %q:\n%s", want, doc.Content)
+		}
+	}
+
+	if strings.Join(doc.Assets, ",") != "fonts/inter.woff2,img/bg.png,css/app.css,css/print.css,img/logo.svg" {
+		t.Errorf("unexpected assets %v", doc.Assets)
+	}
+	if len(doc.Warnings) != 2 {
+		t.Fatalf("expected warnings for the internal host and the missing file, got %v", doc.Warnings)
+	}
+	if doc.Warnings[0].Ref != "https://assets.internal.example/logo.png" || !strings.Contains(doc.Warnings[1].String(), "img/missing.png") {
+		t.Errorf("unexpected warnings %v", doc.Warnings)
+	}
+
+	req := doc.PageRequest("png")
+	if req.URL != BlankURL || req.Content != doc.Content || doc.Size() != len(doc.Content) {
+		t.Errorf("unexpected page request %+v", req)
+	}
+}
+
+func TestRender_SizeLimits(t *testing.T) {
+	r := NewRenderer(Config{Assets: assets, MaxAssetSize: 1024})
+	if _, err := r.Inline(`<img src="img/huge.png">`); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge for an oversized asset, got %v", err)
+	}
+	tmpl := template.Must(template.New("t").Funcs(r.Funcs()).Parse(`<img src="{{asset "img/huge.png"}}">`))
+	if _, err := r.Render(tmpl, nil); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge from the asset func, got %v", err)
+	}
+
+	r = NewRenderer(Config{Assets: assets, MaxContentSize: 64})
+	if _, err := r.Inline(`<img src="img/logo.svg">` + strings.Repeat(" ", 64)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge for an oversized document, got %v", err)
+	}
+}
+
+func TestRender_ImportCycles(t *testing.T) {
+	r := NewRenderer(Config{Assets: fstest.MapFS{
+		"a.css":    {Data: []byte(`@import "b.css"; a { color: red }`)},
+		"b.css":    {Data: []byte(`@import "a.css"; b { color: blue }`)},
+		"self.css": {Data: []byte(`@import "self.css";`)},
+	}})
+	doc, err := r.Inline(`<link rel="stylesheet" href="a.css"><link rel="stylesheet" href="self.css">`)
+	if err != nil {
+		t.Fatalf("Inline: %v", err)
+	}
+	if !strings.Contains(doc.Content, `<style>@import url("data:text/css;base64,`) || strings.Count(doc.Content, `@import "self.css"`) != 1 {
+		t.Errorf("unexpected content:\n%s", doc.Content)
+	}
+	var refs []string
+	for _, w := range doc.Warnings {
+		refs = append(refs, w.Ref)
+	}
+	if strings.Join(refs, ",") != "a.css,self.css" {
+		t.Errorf("expected a warning per cycle, got %v", doc.Warnings)
+	}
+}
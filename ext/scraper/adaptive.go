@@ -48,6 +48,16 @@ func DoPageWithAdaptiveBlockPolicy(
 			Response: resp,
 			Err:      err,
 		})
+		client.Observer().OnOrchestrationAttempt(ctx, phantomjscloud.OrchestrationAttemptEvent{
+			Orchestrator: "adaptive",
+			Attempt:      i,
+			URL:          req.URL,
+			Level:        string(level),
+			Proxy:        req.Proxy,
+			Blocked:      blocked,
+			Response:     resp,
+			Err:          err,
+		})
 
 		if err == nil && !blocked {
 			return resp, attempts, nil
@@ -126,6 +136,16 @@ func DoPageWithRoutingAndAdaptivePolicy(
 			Response: resp,
 			Err:      err,
 		})
+		client.Observer().OnOrchestrationAttempt(ctx, phantomjscloud.OrchestrationAttemptEvent{
+			Orchestrator: "routing",
+			Attempt:      i,
+			URL:          req.URL,
+			Level:        string(level),
+			Proxy:        req.Proxy,
+			Blocked:      blocked,
+			Response:     resp,
+			Err:          err,
+		})
 
 		if err == nil && !blocked {
 			return resp, attempts, nil
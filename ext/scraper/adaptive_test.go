@@ -229,3 +229,46 @@ func TestDoPageWithRoutingAndAdaptivePolicy_HealthRouterFeedbackBetweenAttempts(
 		t.Fatalf("expected health snapshots in attempts, got %#v", attempts)
 	}
 }
+
+type orchestrationRecorder struct {
+	phantomjscloud.NopObserver
+	events []phantomjscloud.OrchestrationAttemptEvent
+}
+
+func (o *orchestrationRecorder) OnOrchestrationAttempt(_ context.Context, e phantomjscloud.OrchestrationAttemptEvent) {
+	o.events = append(o.events, e)
+}
+
+func TestDoPageWithAdaptiveBlockPolicy_EmitsOrchestrationEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req phantomjscloud.UserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		content := "ok page"
+		if len(req.Pages[0].RequestSettings.ResourceModifier) > 55 {
+			content = "Robot or human?"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"success","pageResponses":[{"statusCode":200,"content":"` + content + `"}]}`))
+	}))
+	defer server.Close()
+
+	obs := &orchestrationRecorder{}
+	client := phantomjscloud.NewClient("test-key", phantomjscloud.WithEndpoint(server.URL+"/"), phantomjscloud.WithObserver(obs))
+	baseReq := phantomjscloud.NewPageRequestBuilder("https://example.com").Build()
+
+	if _, _, err := DoPageWithAdaptiveBlockPolicy(context.Background(), client, baseReq, blockpolicy.LevelAggressive, 2); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if len(obs.events) != 2 {
+		t.Fatalf("expected 2 orchestration events, got %d", len(obs.events))
+	}
+	first, second := obs.events[0], obs.events[1]
+	if first.Orchestrator != "adaptive" || first.Attempt != 0 || !first.Blocked || first.Level != string(blockpolicy.LevelAggressive) {
+		t.Fatalf("unexpected first event: %+v", first)
+	}
+	if second.Attempt != 1 || second.Blocked || second.URL != "https://example.com" {
+		t.Fatalf("unexpected second event: %+v", second)
+	}
+}
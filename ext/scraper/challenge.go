@@ -102,6 +102,17 @@ func DoPageWithChallengeOrchestration(
 			Response: resp,
 			Err:      err,
 		})
+		client.Observer().OnOrchestrationAttempt(ctx, phantomjscloud.OrchestrationAttemptEvent{
+			Orchestrator: "challenge",
+			Attempt:      i,
+			URL:          req.URL,
+			Level:        string(level),
+			Persona:      personaName,
+			Proxy:        req.Proxy,
+			Blocked:      blocked,
+			Response:     resp,
+			Err:          err,
+		})
 
 		if err == nil && !blocked {
 			return resp, attempts, nil
@@ -0,0 +1,222 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+	"github.com/amafjarkasi/go-phantomjs/ext/session"
+)
+
+// FlowBuilder chains named navigation steps into one UserRequest. PhantomJsCloud
+// runs every page of a UserRequest in the same browser session, so cookies set
+// by earlier steps, such as a home page visit, carry over to later ones.
+//
+//	flow := scraper.NewFlowBuilder().
+//	    WithSession(store).
+//	    Visit("home", "https://example.com/").
+//	    Wait(2 * time.Second).
+//	    PostForm("search", "https://example.com/search", url.Values{"q": {"shoes"}}).
+//	    Render("results", &phantomjscloud.PageRequest{URL: "https://example.com/results", RenderType: "png"})
+//	res, err := flow.Run(ctx, client)
+//	page, _ := res.Step("results")
+type FlowBuilder struct {
+	steps   []flowStep
+	session *session.Store
+	proxy   interface{}
+	err     error
+}
+
+type flowStep struct {
+	name string
+	page phantomjscloud.PageRequest
+}
+
+// NewFlowBuilder returns an empty flow.
+func NewFlowBuilder() *FlowBuilder {
+	return &FlowBuilder{}
+}
+
+// WithSession seeds the first step with the store's cookies for its URL and
+// stores the final step's cookies after Run.
+func (b *FlowBuilder) WithSession(s *session.Store) *FlowBuilder {
+	b.session = s
+	return b
+}
+
+// WithProxy routes every step through the same proxy, so the site sees one client.
+func (b *FlowBuilder) WithProxy(proxy interface{}) *FlowBuilder {
+	b.proxy = proxy
+	return b
+}
+
+// Visit adds a step that loads rawURL as html.
+func (b *FlowBuilder) Visit(name, rawURL string) *FlowBuilder {
+	return b.Step(name, phantomjscloud.PageRequest{URL: rawURL, RenderType: "html"})
+}
+
+// PostForm adds a step that submits form to rawURL as
+// application/x-www-form-urlencoded.
+func (b *FlowBuilder) PostForm(name, rawURL string, form url.Values) *FlowBuilder {
+	return b.Step(name, phantomjscloud.PageRequest{
+		URL:        rawURL,
+		RenderType: "html",
+		UrlSettings: &phantomjscloud.UrlSettings{
+			Operation: "POST",
+			Data:      form.Encode(),
+			Headers:   map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+		},
+	})
+}
+
+// Render adds the final output step, e.g. a screenshot or automation render.
+func (b *FlowBuilder) Render(name string, req *phantomjscloud.PageRequest) *FlowBuilder {
+	if req == nil {
+		b.fail(fmt.Errorf("flow step %q: nil request", name))
+		return b
+	}
+	return b.Step(name, *req)
+}
+
+// Step adds an arbitrary page as a named step.
+func (b *FlowBuilder) Step(name string, page phantomjscloud.PageRequest) *FlowBuilder {
+	switch {
+	case name == "":
+		b.fail(errors.New("flow step name is required"))
+	case b.index(name) >= 0:
+		b.fail(fmt.Errorf("duplicate flow step %q", name))
+	default:
+		b.steps = append(b.steps, flowStep{name: name, page: page})
+	}
+	return b
+}
+
+// Wait makes the previous step wait d after the page load before finishing.
+func (b *FlowBuilder) Wait(d time.Duration) *FlowBuilder {
+	if s := b.last("Wait"); s != nil {
+		s.page.RequestSettings.WaitInterval = int(d / time.Millisecond)
+	}
+	return b
+}
+
+// WaitForSelector makes the previous step finish once selector is present.
+func (b *FlowBuilder) WaitForSelector(selector string) *FlowBuilder {
+	if s := b.last("WaitForSelector"); s != nil {
+		s.page.RequestSettings.DoneWhen = append(s.page.RequestSettings.DoneWhen, phantomjscloud.DoneWhen{Selector: selector})
+	}
+	return b
+}
+
+// Build returns the flow as a UserRequest with one page per step, in order.
+func (b *FlowBuilder) Build() (*phantomjscloud.UserRequest, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.steps) == 0 {
+		return nil, errors.New("flow has no steps")
+	}
+	if len(b.steps) > phantomjscloud.MaxPagesPerRequest {
+		return nil, fmt.Errorf("flow has %d steps; the limit is %d", len(b.steps), phantomjscloud.MaxPagesPerRequest)
+	}
+
+	req := &phantomjscloud.UserRequest{
+		Pages:        make([]phantomjscloud.PageRequest, len(b.steps)),
+		OutputAsJson: true,
+	}
+	for i, s := range b.steps {
+		page := s.page
+		if b.proxy != nil {
+			page.Proxy = b.proxy
+		}
+		req.Pages[i] = page
+	}
+	if b.session != nil {
+		first := &req.Pages[0]
+		if cookies := b.session.CookiesForURL(first.URL); len(cookies) > 0 {
+			first.RequestSettings.Cookies = append(cookies, first.RequestSettings.Cookies...)
+		}
+	}
+	return req, nil
+}
+
+// Run sends the flow as a single call and maps the page responses back to steps.
+func (b *FlowBuilder) Run(ctx context.Context, client *phantomjscloud.Client) (*FlowResult, error) {
+	req, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.DoContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &FlowResult{Response: res, index: make(map[string]int, len(b.steps))}
+	for i, s := range b.steps {
+		out.names = append(out.names, s.name)
+		out.index[s.name] = i
+	}
+	if len(res.PageResponses) < len(b.steps) {
+		return out, fmt.Errorf("flow returned %d page responses for %d steps", len(res.PageResponses), len(b.steps))
+	}
+	if b.session != nil {
+		b.session.Upsert(res.PageResponses[len(b.steps)-1].Cookies)
+	}
+	return out, nil
+}
+
+func (b *FlowBuilder) index(name string) int {
+	for i, s := range b.steps {
+		if s.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (b *FlowBuilder) last(method string) *flowStep {
+	if len(b.steps) == 0 {
+		b.fail(fmt.Errorf("%s called before any flow step", method))
+		return nil
+	}
+	return &b.steps[len(b.steps)-1]
+}
+
+func (b *FlowBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// FlowResult maps a flow's page responses back to its named steps.
+type FlowResult struct {
+	// Response is the full API response for the flow.
+	Response *phantomjscloud.UserResponseWithMeta
+	names    []string
+	index    map[string]int
+}
+
+// Steps returns the step names in order.
+func (r *FlowResult) Steps() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Step returns the page response for the named step.
+func (r *FlowResult) Step(name string) (*phantomjscloud.PageResponse, bool) {
+	i, ok := r.index[name]
+	if !ok || i >= len(r.Response.PageResponses) {
+		return nil, false
+	}
+	return &r.Response.PageResponses[i], true
+}
+
+// Final returns the page response of the last step, or nil if it is missing.
+func (r *FlowResult) Final() *phantomjscloud.PageResponse {
+	if len(r.names) == 0 {
+		return nil
+	}
+	p, _ := r.Step(r.names[len(r.names)-1])
+	return p
+}
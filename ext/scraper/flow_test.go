@@ -0,0 +1,121 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+	"github.com/amafjarkasi/go-phantomjs/ext/session"
+)
+
+func TestFlowBuilder_BuildsOneRequest(t *testing.T) {
+	store := session.NewStore()
+	store.Upsert([]phantomjscloud.Cookie{{Name: "sid", Value: "old", Domain: "example.com", Path: "/"}})
+
+	req, err := NewFlowBuilder().
+		WithSession(store).
+		WithProxy("anon-us").
+		Visit("home", "https://example.com/").
+		Wait(1500*time.Millisecond).
+		PostForm("search", "https://example.com/search", url.Values{"q": {"red shoes"}}).
+		WaitForSelector("#results").
+		Render("results", &phantomjscloud.PageRequest{URL: "https://example.com/results", RenderType: "png"}).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if len(req.Pages) != 3 || !req.OutputAsJson {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	home, search, results := req.Pages[0], req.Pages[1], req.Pages[2]
+	if home.RequestSettings.WaitInterval != 1500 {
+		t.Errorf("expected Wait on home step, got %d", home.RequestSettings.WaitInterval)
+	}
+	if len(home.RequestSettings.Cookies) != 1 || home.RequestSettings.Cookies[0].Value != "old" {
+		t.Errorf("expected session cookies on the first step, got %+v", home.RequestSettings.Cookies)
+	}
+	if search.UrlSettings == nil || search.UrlSettings.Operation != "POST" || search.UrlSettings.Data != "q=red+shoes" {
+		t.Errorf("unexpected form step %+v", search.UrlSettings)
+	}
+	if len(search.RequestSettings.DoneWhen) != 1 || search.RequestSettings.DoneWhen[0].Selector != "#results" {
+		t.Errorf("expected WaitForSelector on search step, got %+v", search.RequestSettings.DoneWhen)
+	}
+	if results.RenderType != "png" {
+		t.Errorf("expected final png render, got %q", results.RenderType)
+	}
+	for i, p := range req.Pages {
+		if p.Proxy != "anon-us" {
+			t.Errorf("page %d: expected shared proxy, got %v", i, p.Proxy)
+		}
+	}
+}
+
+func TestFlowBuilder_Errors(t *testing.T) {
+	cases := map[string]*FlowBuilder{
+		"empty":      NewFlowBuilder(),
+		"duplicate":  NewFlowBuilder().Visit("a", "https://example.com").Visit("a", "https://example.com"),
+		"unnamed":    NewFlowBuilder().Visit("", "https://example.com"),
+		"wait first": NewFlowBuilder().Wait(time.Second).Visit("a", "https://example.com"),
+		"nil render": NewFlowBuilder().Render("a", nil),
+		"too many": func() *FlowBuilder {
+			b := NewFlowBuilder()
+			for i := 0; i <= phantomjscloud.MaxPagesPerRequest; i++ {
+				b.Visit(fmt.Sprintf("step-%d", i), "https://example.com")
+			}
+			return b
+		}(),
+	}
+	for name, b := range cases {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestFlowBuilder_RunMapsStepsAndStoresCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req phantomjscloud.UserRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Pages) != 2 {
+			t.Errorf("expected a two-page request, got %d pages (%v)", len(req.Pages), err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"pageResponses":[
+			{"content":"home","statusCode":200,"cookies":[{"name":"visited","value":"1","domain":"example.com","path":"/"}]},
+			{"content":"results","statusCode":200,"cookies":[{"name":"visited","value":"1","domain":"example.com","path":"/"},{"name":"cart","value":"42","domain":"example.com","path":"/"}]}
+		]}`))
+	}))
+	defer server.Close()
+
+	store := session.NewStore()
+	client := phantomjscloud.NewClient("test-key", phantomjscloud.WithEndpoint(server.URL+"/"))
+	res, err := NewFlowBuilder().
+		WithSession(store).
+		Visit("home", "https://example.com/").
+		Visit("results", "https://example.com/results").
+		Run(context.Background(), client)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if got := res.Steps(); len(got) != 2 || got[0] != "home" || got[1] != "results" {
+		t.Fatalf("Steps() = %v", got)
+	}
+	if p, ok := res.Step("home"); !ok || p.Content != "home" {
+		t.Fatalf("Step(home) = %+v, %v", p, ok)
+	}
+	if res.Final().Content != "results" {
+		t.Fatalf("Final() = %+v", res.Final())
+	}
+	if _, ok := res.Step("missing"); ok {
+		t.Fatal("expected unknown step to be missing")
+	}
+	if cookies := store.CookiesForURL("https://example.com/"); len(cookies) != 2 {
+		t.Fatalf("expected final step cookies in store, got %+v", cookies)
+	}
+}
@@ -3,6 +3,7 @@ package scraper
 import (
 	"context"
 	"sync"
+	"time"
 
 	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
 )
@@ -10,6 +11,10 @@ import (
 // BatchProcessor manages high-volume scraping tasks.
 // It automatically batches requests to PhantomJsCloud's multi-page API
 // and handles concurrency, ensuring you stay within rate limits.
+//
+// Concurrency here only bounds a single Scrape call. To share account limits
+// with other call paths, configure the client with WithRateLimit and
+// WithMaxConcurrency.
 type BatchProcessor struct {
 	client      *phantomjscloud.Client
 	concurrency int
@@ -21,6 +26,8 @@ type Result struct {
 	Request  phantomjscloud.PageRequest
 	Response *phantomjscloud.PageResponse
 	Metadata phantomjscloud.ResponseMetadata
+	// Duration is how long the batch call that produced this result took.
+	Duration time.Duration
 	Error    error
 }
 
@@ -31,8 +38,8 @@ func NewBatchProcessor(client *phantomjscloud.Client, concurrency, batchSize int
 	if batchSize < 1 {
 		batchSize = 1
 	}
-	if batchSize > 100 {
-		batchSize = 100 // PJSC limit
+	if batchSize > phantomjscloud.MaxPagesPerRequest {
+		batchSize = phantomjscloud.MaxPagesPerRequest
 	}
 	if concurrency < 1 {
 		concurrency = 1
@@ -77,14 +84,34 @@ func (p *BatchProcessor) Scrape(ctx context.Context, requests []phantomjscloud.P
 					Pages: b,
 				}
 
+				start := time.Now()
 				res, err := p.client.DoContext(ctx, userReq)
+				elapsed := time.Since(start)
 				if err != nil {
+					p.client.Observer().OnBatch(ctx, phantomjscloud.BatchEvent{
+						Pages:    len(b),
+						Failed:   len(b),
+						Duration: elapsed,
+						Err:      err,
+					})
 					for _, req := range b {
-						resultChan <- Result{Request: req, Error: err}
+						resultChan <- Result{Request: req, Duration: elapsed, Error: err}
 					}
 					return
 				}
 
+				failed := 0
+				for i := range res.PageResponses {
+					if res.PageResponses[i].StatusCode >= 400 {
+						failed++
+					}
+				}
+				p.client.Observer().OnBatch(ctx, phantomjscloud.BatchEvent{
+					Pages:    len(b),
+					Failed:   failed,
+					Duration: elapsed,
+				})
+
 				// Map results back to requests
 				for i := range res.PageResponses {
 					pageRes := res.PageResponses[i]
@@ -92,6 +119,7 @@ func (p *BatchProcessor) Scrape(ctx context.Context, requests []phantomjscloud.P
 						Request:  b[i],
 						Response: &pageRes,
 						Metadata: res.Metadata,
+						Duration: elapsed,
 					}
 				}
 			}(batch)
@@ -1,6 +1,12 @@
 package viewport
 
-import phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+)
 
 // Preset is a named viewport configuration.
 type Preset struct {
@@ -123,3 +129,43 @@ func Custom(width, height int) Preset {
 		Viewport: phantomjscloud.Viewport{Width: width, Height: height},
 	}
 }
+
+var byName = map[string]Preset{
+	"hd":              HD,
+	"fhd":             FHD,
+	"qhd":             QHD,
+	"uhd":             UHD,
+	"laptop":          Laptop,
+	"mobileportrait":  MobilePortrait,
+	"mobilelandscape": MobileLandscape,
+	"tabletportrait":  TabletPortrait,
+	"tabletlandscape": TabletLandscape,
+	"thumbnail640":    Thumbnail640,
+	"thumbnail1200":   Thumbnail1200,
+}
+
+// Lookup returns the preset with the given name, such as "fhd" or
+// "MobilePortrait", matched case-insensitively. A "WIDTHxHEIGHT" name
+// returns a Custom preset.
+func Lookup(name string) (Preset, bool) {
+	if p, ok := byName[strings.ToLower(name)]; ok {
+		return p, true
+	}
+	var w, h int
+	if n, err := fmt.Sscanf(strings.ToLower(name), "%dx%d", &w, &h); err == nil && n == 2 && w > 0 && h > 0 {
+		if fmt.Sprintf("%dx%d", w, h) == strings.ToLower(name) {
+			return Custom(w, h), true
+		}
+	}
+	return Preset{}, false
+}
+
+// Names returns the names accepted by Lookup, sorted.
+func Names() []string {
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -104,3 +104,22 @@ func TestZoomFactorMapping(t *testing.T) {
 		t.Errorf("FHD: ZoomFactor should be 0 (use default), got %v", rs.ZoomFactor)
 	}
 }
+
+func TestLookup(t *testing.T) {
+	if p, ok := viewport.Lookup("MobilePortrait"); !ok || p.Viewport != viewport.MobilePortrait.Viewport {
+		t.Errorf("Lookup(MobilePortrait) = %+v, %v", p, ok)
+	}
+	if p, ok := viewport.Lookup("800x600"); !ok || p.Viewport.Width != 800 || p.Viewport.Height != 600 {
+		t.Errorf("Lookup(800x600) = %+v, %v", p, ok)
+	}
+	for _, name := range []string{"", "watch", "800x", "0x600", "800x600px"} {
+		if _, ok := viewport.Lookup(name); ok {
+			t.Errorf("Lookup(%q) should fail", name)
+		}
+	}
+	for _, name := range viewport.Names() {
+		if _, ok := viewport.Lookup(name); !ok {
+			t.Errorf("Names() lists %q but Lookup rejects it", name)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package visual
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// DefaultThreshold is the per-pixel color distance used when
+// Options.Threshold is zero.
+const DefaultThreshold = 0.1
+
+// maxYIQDelta is the largest possible squared YIQ distance between two colors.
+const maxYIQDelta = 35215.0
+
+// Options configures a comparison.
+type Options struct {
+	// Threshold is the color distance, from 0 to 1, above which two pixels
+	// differ. Defaults to DefaultThreshold.
+	Threshold float64
+	// MaxDiffRatio is the fraction of differing pixels a comparison may have
+	// and still pass. Zero fails on any difference.
+	MaxDiffRatio float64
+	// CountAntiAliased counts anti-aliased pixels as differences. By default
+	// they are tolerated, since font smoothing varies between renders.
+	CountAntiAliased bool
+	// Ignore masks out regions, in baseline pixel coordinates, such as
+	// timestamps or rotating banners.
+	Ignore []image.Rectangle
+}
+
+// Comparison is the result of comparing a render with its baseline.
+type Comparison struct {
+	DiffPixels  int
+	AntiAliased int
+	TotalPixels int
+	// Ratio is DiffPixels divided by TotalPixels.
+	Ratio float64
+	// SizeMismatch is set when the images have different bounds. Pixels
+	// outside either image count as differences.
+	SizeMismatch bool
+	Passed       bool
+	// Image highlights differences in red and tolerated anti-aliasing in
+	// yellow over a faded copy of the baseline. Ignored regions are tinted blue.
+	Image *image.RGBA
+}
+
+// WritePNG encodes the highlighted diff image as PNG.
+func (c *Comparison) WritePNG(w io.Writer) error {
+	return png.Encode(w, c.Image)
+}
+
+var (
+	diffColor    = color.RGBA{R: 255, A: 255}
+	aaColor      = color.RGBA{R: 255, G: 255, A: 255}
+	ignoredColor = color.RGBA{R: 200, G: 220, B: 255, A: 255}
+)
+
+// Compare diffs current against baseline pixel by pixel.
+func Compare(baseline, current image.Image, opts Options) *Comparison {
+	if opts.Threshold <= 0 {
+		opts.Threshold = DefaultThreshold
+	}
+	maxDelta := maxYIQDelta * opts.Threshold * opts.Threshold
+
+	a, b := toRGBA(baseline), toRGBA(current)
+	width := max(a.Rect.Dx(), b.Rect.Dx())
+	height := max(a.Rect.Dy(), b.Rect.Dy())
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	c := &Comparison{
+		TotalPixels:  width * height,
+		SizeMismatch: a.Rect.Dx() != b.Rect.Dx() || a.Rect.Dy() != b.Rect.Dy(),
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if ignored(opts.Ignore, x, y) {
+				out.SetRGBA(x, y, ignoredColor)
+				continue
+			}
+			pa, inA := pixel(a, x, y)
+			pb, inB := pixel(b, x, y)
+			if !inA || !inB {
+				c.DiffPixels++
+				out.SetRGBA(x, y, diffColor)
+				continue
+			}
+			if colorDelta(pa, pb, false) <= maxDelta {
+				out.SetRGBA(x, y, faded(pa))
+				continue
+			}
+			if !opts.CountAntiAliased && (antiAliased(a, b, x, y) || antiAliased(b, a, x, y)) {
+				c.AntiAliased++
+				out.SetRGBA(x, y, aaColor)
+				continue
+			}
+			c.DiffPixels++
+			out.SetRGBA(x, y, diffColor)
+		}
+	}
+	if c.TotalPixels > 0 {
+		c.Ratio = float64(c.DiffPixels) / float64(c.TotalPixels)
+	}
+	c.Passed = !c.SizeMismatch && c.Ratio <= opts.MaxDiffRatio
+	c.Image = out
+	return c
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			out.Set(x, y, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return out
+}
+
+func pixel(img *image.RGBA, x, y int) (color.RGBA, bool) {
+	if x >= img.Rect.Dx() || y >= img.Rect.Dy() {
+		return color.RGBA{}, false
+	}
+	return img.RGBAAt(x, y), true
+}
+
+func ignored(regions []image.Rectangle, x, y int) bool {
+	p := image.Pt(x, y)
+	for _, r := range regions {
+		if p.In(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// colorDelta is the squared YIQ distance between two colors after blending
+// them over white, signed by which one is brighter. With yOnly it returns
+// the brightness difference alone.
+func colorDelta(p1, p2 color.RGBA, yOnly bool) float64 {
+	r1, g1, b1 := blend(p1)
+	r2, g2, b2 := blend(p2)
+	y := yiqY(r1, g1, b1) - yiqY(r2, g2, b2)
+	if yOnly {
+		return y
+	}
+	i := yiqI(r1, g1, b1) - yiqI(r2, g2, b2)
+	q := yiqQ(r1, g1, b1) - yiqQ(r2, g2, b2)
+	return 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+}
+
+func blend(p color.RGBA) (float64, float64, float64) {
+	// RGBA holds premultiplied alpha, so blending over white adds the
+	// uncovered share of white to each channel.
+	white := 255 - float64(p.A)
+	return float64(p.R) + white, float64(p.G) + white, float64(p.B) + white
+}
+
+func yiqY(r, g, b float64) float64 { return r*0.29889531 + g*0.58662247 + b*0.11448223 }
+func yiqI(r, g, b float64) float64 { return r*0.59597799 - g*0.27417610 - b*0.32180189 }
+func yiqQ(r, g, b float64) float64 { return r*0.21147017 - g*0.52261711 + b*0.31114694 }
+
+// antiAliased reports whether the pixel at x, y of img looks like
+// anti-aliasing: it sits between a darker and a brighter neighbour, and one
+// of those neighbours is part of a flat area in both images.
+func antiAliased(img, other *image.RGBA, x, y int) bool {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	x0, y0 := max(x-1, 0), max(y-1, 0)
+	x1, y1 := min(x+1, w-1), min(y+1, h-1)
+	zeroes := 0
+	if x == x0 || x == x1 || y == y0 || y == y1 {
+		zeroes = 1
+	}
+	center := img.RGBAAt(x, y)
+	var minDelta, maxDelta float64
+	var minX, minY, maxX, maxY int
+	for nx := x0; nx <= x1; nx++ {
+		for ny := y0; ny <= y1; ny++ {
+			if nx == x && ny == y {
+				continue
+			}
+			delta := colorDelta(center, img.RGBAAt(nx, ny), true)
+			switch {
+			case delta == 0:
+				zeroes++
+				if zeroes > 2 {
+					return false
+				}
+			case delta < minDelta:
+				minDelta, minX, minY = delta, nx, ny
+			case delta > maxDelta:
+				maxDelta, maxX, maxY = delta, nx, ny
+			}
+		}
+	}
+	if minDelta == 0 || maxDelta == 0 {
+		return false
+	}
+	return (flat(img, minX, minY) && flat(other, minX, minY)) ||
+		(flat(img, maxX, maxY) && flat(other, maxX, maxY))
+}
+
+// flat reports whether the pixel at x, y has at least three identical neighbours.
+func flat(img *image.RGBA, x, y int) bool {
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	if x >= w || y >= h {
+		return false
+	}
+	x0, y0 := max(x-1, 0), max(y-1, 0)
+	x1, y1 := min(x+1, w-1), min(y+1, h-1)
+	zeroes := 0
+	if x == x0 || x == x1 || y == y0 || y == y1 {
+		zeroes = 1
+	}
+	center := img.RGBAAt(x, y)
+	for nx := x0; nx <= x1; nx++ {
+		for ny := y0; ny <= y1; ny++ {
+			if (nx != x || ny != y) && img.RGBAAt(nx, ny) == center {
+				zeroes++
+				if zeroes > 2 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// faded returns p as a light gray, so highlights stand out.
+func faded(p color.RGBA) color.RGBA {
+	r, g, b := blend(p)
+	v := uint8(255 - (255-yiqY(r, g, b))*0.1)
+	return color.RGBA{R: v, G: v, B: v, A: 255}
+}
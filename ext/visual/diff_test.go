@@ -0,0 +1,83 @@
+package visual
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// edgeImage is white left of x=5 and black from x=5, with the edge column
+// replaced by edge on the rows from 1 to 8.
+func edgeImage(edge color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			c := color.Color(color.White)
+			if x >= 5 {
+				c = color.Black
+			}
+			if x == 5 && y >= 1 && y <= 8 && edge != nil {
+				c = edge
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestCompare_Identical(t *testing.T) {
+	c := Compare(edgeImage(nil), edgeImage(nil), Options{})
+	if !c.Passed || c.DiffPixels != 0 || c.TotalPixels != 100 {
+		t.Fatalf("unexpected comparison %+v", c)
+	}
+}
+
+func TestCompare_AntiAliasingTolerance(t *testing.T) {
+	gray := color.Gray{Y: 128}
+	c := Compare(edgeImage(nil), edgeImage(gray), Options{})
+	if !c.Passed || c.AntiAliased != 8 {
+		t.Fatalf("expected the smoothed edge to be tolerated, got diff=%d aa=%d", c.DiffPixels, c.AntiAliased)
+	}
+	if got := c.Image.RGBAAt(5, 4); got != aaColor {
+		t.Errorf("expected anti-aliased pixel highlighted yellow, got %v", got)
+	}
+
+	c = Compare(edgeImage(nil), edgeImage(gray), Options{CountAntiAliased: true})
+	if c.Passed || c.DiffPixels != 8 {
+		t.Fatalf("expected 8 differing pixels, got %+v", c)
+	}
+}
+
+func TestCompare_ChangedRegionAndMasks(t *testing.T) {
+	current := edgeImage(nil)
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			current.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+
+	c := Compare(edgeImage(nil), current, Options{})
+	if c.Passed || c.DiffPixels != 9 || c.Ratio != 0.09 {
+		t.Fatalf("expected 9 differing pixels, got %+v", c)
+	}
+	if got := c.Image.RGBAAt(1, 1); got != diffColor {
+		t.Errorf("expected differing pixel highlighted red, got %v", got)
+	}
+
+	if c := Compare(edgeImage(nil), current, Options{MaxDiffRatio: 0.1}); !c.Passed {
+		t.Errorf("expected pass under MaxDiffRatio 0.1, got %+v", c)
+	}
+	if c := Compare(edgeImage(nil), current, Options{Ignore: []image.Rectangle{image.Rect(0, 0, 3, 3)}}); !c.Passed || c.DiffPixels != 0 {
+		t.Errorf("expected masked region to be ignored, got %+v", c)
+	}
+	if c := Compare(edgeImage(nil), current, Options{Threshold: 1}); !c.Passed {
+		t.Errorf("expected pass at threshold 1, got %+v", c)
+	}
+}
+
+func TestCompare_SizeMismatch(t *testing.T) {
+	c := Compare(edgeImage(nil), image.NewRGBA(image.Rect(0, 0, 10, 12)), Options{MaxDiffRatio: 1})
+	if c.Passed || !c.SizeMismatch || c.Image.Bounds().Dy() != 12 {
+		t.Fatalf("expected size mismatch to fail, got %+v", c)
+	}
+}
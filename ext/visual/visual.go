@@ -0,0 +1,216 @@
+// Package visual is a visual regression checker for PhantomJsCloud
+// screenshots. Baselines are PNG files keyed by URL and viewport preset, and
+// renders are compared with a pure-Go pixel diff.
+package visual
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+	"github.com/amafjarkasi/go-phantomjs/ext/viewport"
+)
+
+// ErrNoBaseline is returned when no baseline exists for a URL and preset.
+var ErrNoBaseline = errors.New("visual: no baseline")
+
+// Store keeps baseline PNGs in a directory.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a Store rooted at dir. The directory is created on the first save.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Key identifies the baseline for url rendered with preset. It is readable
+// and file-name safe, e.g. "example.com_pricing-1280x720-1a2b3c4d".
+func Key(rawURL string, preset viewport.Preset) string {
+	slug := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+		slug = u.Host + u.Path
+	}
+	slug = strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		}
+		return '_'
+	}, slug), "_")
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+
+	presetJSON, _ := json.Marshal(preset)
+	sum := sha256.Sum256(append([]byte(rawURL+"\n"), presetJSON...))
+	return fmt.Sprintf("%s-%dx%d-%s", slug, preset.Viewport.Width, preset.Viewport.Height, hex.EncodeToString(sum[:4]))
+}
+
+// Path returns where the baseline for url and preset is stored.
+func (s *Store) Path(rawURL string, preset viewport.Preset) string {
+	return filepath.Join(s.dir, Key(rawURL, preset)+".png")
+}
+
+// DiffPath returns where the diff image for url and preset is written.
+func (s *Store) DiffPath(rawURL string, preset viewport.Preset) string {
+	return filepath.Join(s.dir, Key(rawURL, preset)+".diff.png")
+}
+
+// Load reads the baseline for url and preset, returning ErrNoBaseline when
+// there is none.
+func (s *Store) Load(rawURL string, preset viewport.Preset) (image.Image, error) {
+	f, err := os.Open(s.Path(rawURL, preset))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNoBaseline
+	}
+	if err != nil {
+		return nil, fmt.Errorf("visual: %w", err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("visual: decode baseline: %w", err)
+	}
+	return img, nil
+}
+
+// Save stores img as the baseline for url and preset.
+func (s *Store) Save(rawURL string, preset viewport.Preset, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("visual: encode baseline: %w", err)
+	}
+	return s.write(s.Path(rawURL, preset), buf.Bytes())
+}
+
+func (s *Store) write(path string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("visual: %w", err)
+	}
+	// Write through a temporary file so a crash never leaves a truncated baseline.
+	tmp, err := os.CreateTemp(s.dir, ".baseline-*")
+	if err != nil {
+		return fmt.Errorf("visual: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("visual: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("visual: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("visual: %w", err)
+	}
+	return nil
+}
+
+// Checker renders pages and compares them with their baselines.
+type Checker struct {
+	client *phantomjscloud.Client
+	store  *Store
+	opts   Options
+}
+
+// CheckResult is the outcome of one Check.
+type CheckResult struct {
+	URL          string
+	Key          string
+	BaselinePath string
+	// DiffPath is set when a failing comparison wrote a diff image.
+	DiffPath string
+	// Created is set when no baseline existed and the render became the baseline.
+	Created    bool
+	Comparison *Comparison
+}
+
+// Passed reports whether the render matched its baseline or became a new one.
+func (r *CheckResult) Passed() bool {
+	return r.Created || (r.Comparison != nil && r.Comparison.Passed)
+}
+
+// NewChecker creates a Checker.
+func NewChecker(client *phantomjscloud.Client, store *Store, opts Options) *Checker {
+	return &Checker{client: client, store: store, opts: opts}
+}
+
+// Check screenshots url with preset and compares it with the stored
+// baseline. A missing baseline is created from the render. A failing
+// comparison writes a highlighted diff PNG next to the baseline.
+func (c *Checker) Check(ctx context.Context, rawURL string, preset viewport.Preset) (*CheckResult, error) {
+	current, err := c.screenshot(ctx, rawURL, preset)
+	if err != nil {
+		return nil, err
+	}
+	res := &CheckResult{URL: rawURL, Key: Key(rawURL, preset), BaselinePath: c.store.Path(rawURL, preset)}
+
+	baseline, err := c.store.Load(rawURL, preset)
+	if errors.Is(err, ErrNoBaseline) {
+		if err := c.store.Save(rawURL, preset, current); err != nil {
+			return nil, err
+		}
+		res.Created = true
+		return res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res.Comparison = Compare(baseline, current, c.opts)
+	diffPath := c.store.DiffPath(rawURL, preset)
+	if res.Comparison.Passed {
+		if err := os.Remove(diffPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("visual: %w", err)
+		}
+		return res, nil
+	}
+	var buf bytes.Buffer
+	if err := res.Comparison.WritePNG(&buf); err != nil {
+		return nil, fmt.Errorf("visual: encode diff: %w", err)
+	}
+	if err := c.store.write(diffPath, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	res.DiffPath = diffPath
+	return res, nil
+}
+
+// UpdateBaseline screenshots url with preset and stores it as the new baseline.
+func (c *Checker) UpdateBaseline(ctx context.Context, rawURL string, preset viewport.Preset) (*CheckResult, error) {
+	current, err := c.screenshot(ctx, rawURL, preset)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.store.Save(rawURL, preset, current); err != nil {
+		return nil, err
+	}
+	return &CheckResult{URL: rawURL, Key: Key(rawURL, preset), BaselinePath: c.store.Path(rawURL, preset), Created: true}, nil
+}
+
+func (c *Checker) screenshot(ctx context.Context, rawURL string, preset viewport.Preset) (image.Image, error) {
+	res, err := c.client.Render(ctx, &phantomjscloud.PageRequest{
+		URL:            rawURL,
+		RenderType:     "png",
+		RenderSettings: preset.AsRenderSettings(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.Image()
+}
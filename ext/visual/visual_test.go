@@ -0,0 +1,99 @@
+package visual
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+	"github.com/amafjarkasi/go-phantomjs/ext/viewport"
+)
+
+func TestKey(t *testing.T) {
+	k := Key("https://example.com/pricing?x=1", viewport.HD)
+	if !strings.HasPrefix(k, "example.com_pricing-1280x720-") {
+		t.Fatalf("unexpected key %q", k)
+	}
+	if k == Key("https://example.com/pricing?x=2", viewport.HD) {
+		t.Error("different URLs must have different keys")
+	}
+	if k == Key("https://example.com/pricing?x=1", viewport.MobilePortrait) {
+		t.Error("different presets must have different keys")
+	}
+}
+
+func TestChecker_CreatesThenCompares(t *testing.T) {
+	var shot []byte
+	var gotViewport int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if bytes.Contains(body, []byte(`"width":390`)) {
+			gotViewport = 390
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write(shot)
+	}))
+	defer server.Close()
+
+	encode := func(img image.Image) []byte {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	client := phantomjscloud.NewClient("test-key", phantomjscloud.WithEndpoint(server.URL+"/"))
+	store := NewStore(t.TempDir())
+	checker := NewChecker(client, store, Options{})
+	ctx := context.Background()
+	const page = "https://example.com/"
+
+	shot = encode(edgeImage(nil))
+	res, err := checker.Check(ctx, page, viewport.MobilePortrait)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if !res.Created || !res.Passed() || gotViewport != 390 {
+		t.Fatalf("expected a new baseline rendered at the preset viewport, got %+v (viewport %d)", res, gotViewport)
+	}
+	if _, err := store.Load(page, viewport.MobilePortrait); err != nil {
+		t.Fatalf("baseline not stored: %v", err)
+	}
+
+	changed := edgeImage(nil)
+	changed.Set(1, 1, color.RGBA{B: 255, A: 255})
+	shot = encode(changed)
+	res, err = checker.Check(ctx, page, viewport.MobilePortrait)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if res.Passed() || res.Comparison.DiffPixels != 1 || res.DiffPath == "" {
+		t.Fatalf("expected a failing comparison with a diff image, got %+v", res)
+	}
+	if _, err := os.Stat(res.DiffPath); err != nil {
+		t.Fatalf("diff image not written: %v", err)
+	}
+
+	if _, err := checker.UpdateBaseline(ctx, page, viewport.MobilePortrait); err != nil {
+		t.Fatalf("UpdateBaseline: %v", err)
+	}
+	res, err = checker.Check(ctx, page, viewport.MobilePortrait)
+	if err != nil || !res.Passed() {
+		t.Fatalf("expected pass after updating the baseline, got %+v, %v", res, err)
+	}
+	if _, err := os.Stat(store.DiffPath(page, viewport.MobilePortrait)); !os.IsNotExist(err) {
+		t.Errorf("expected stale diff image to be removed, got %v", err)
+	}
+	if _, err := store.Load("https://example.com/other", viewport.HD); err != ErrNoBaseline {
+		t.Errorf("expected ErrNoBaseline, got %v", err)
+	}
+}
@@ -0,0 +1,477 @@
+// Package warc archives PhantomJsCloud renders as WARC/1.1 files.
+//
+// Each page produces a request record, a resource record holding the
+// rendition PhantomJsCloud returned, and a metadata record with the response
+// status and headers and billing, proxy, persona and timing details. The
+// rendered DOM is not the bytes the browser received, so it is never written
+// as a response record. Files can be gzipped one record per
+// member and are rotated by size.
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+	"github.com/amafjarkasi/go-phantomjs/ext/scraper"
+)
+
+// Version is the WARC version written by this package.
+const Version = "WARC/1.1"
+
+// DefaultMaxFileSize is the file size at which a Writer rotates when
+// Config.MaxFileSize is zero.
+const DefaultMaxFileSize int64 = 1 << 30
+
+// ErrClosed is returned when writing to a closed Writer.
+var ErrClosed = errors.New("warc: writer is closed")
+
+// Config configures a Writer.
+type Config struct {
+	// Dir is the directory that receives the WARC files. It is created if needed.
+	Dir string
+	// Prefix starts every file name. Defaults to "pjsc".
+	Prefix string
+	// MaxFileSize rotates to a new file once the current one reaches this
+	// many bytes. The records of one page are never split across files.
+	MaxFileSize int64
+	// Gzip compresses each record as its own gzip member and names files .warc.gz.
+	Gzip bool
+	// Software is recorded in each file's warcinfo record. Defaults to "go-phantomjs".
+	Software string
+	// Annotate supplies metadata the result doesn't carry, such as the
+	// persona, for results written by Consume.
+	Annotate func(scraper.Result) Info
+}
+
+// Info is extra metadata recorded alongside a page.
+type Info struct {
+	Persona string
+	// Duration overrides the result's duration when set.
+	Duration time.Duration
+	// Fields are written as additional metadata fields.
+	Fields map[string]string
+}
+
+// Writer writes WARC records to size-rotated files. It is safe for concurrent use.
+type Writer struct {
+	cfg Config
+
+	mu      sync.Mutex
+	file    *os.File
+	written int64
+	seq     int
+	files   []string
+	closed  bool
+}
+
+// NewWriter creates a Writer. The first file is opened on the first write.
+func NewWriter(cfg Config) (*Writer, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("warc: Config.Dir is required")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "pjsc"
+	}
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = DefaultMaxFileSize
+	}
+	if cfg.Software == "" {
+		cfg.Software = "go-phantomjs"
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("warc: %w", err)
+	}
+	return &Writer{cfg: cfg}, nil
+}
+
+// Files returns the paths of the files written so far, oldest first.
+func (w *Writer) Files() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.files...)
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// WriteResult archives one BatchProcessor result. Failed results are
+// archived as a request record and a metadata record holding the error.
+func (w *Writer) WriteResult(r scraper.Result, info Info) error {
+	if info.Duration == 0 {
+		info.Duration = r.Duration
+	}
+	return w.writePage(r.Request, r.Response, r.Metadata, info, r.Error)
+}
+
+// WriteResponse archives every page of res. req must be the request that
+// produced res, so pages can be matched by index.
+func (w *Writer) WriteResponse(req *phantomjscloud.UserRequest, res *phantomjscloud.UserResponseWithMeta, info Info) error {
+	if len(req.Pages) != len(res.PageResponses) {
+		return fmt.Errorf("warc: request has %d pages but response has %d", len(req.Pages), len(res.PageResponses))
+	}
+	for i := range res.PageResponses {
+		page := req.Pages[i]
+		if page.Proxy == nil {
+			page.Proxy = req.Proxy
+		}
+		if err := w.writePage(page, &res.PageResponses[i], res.Metadata, info, nil); err != nil {
+			return fmt.Errorf("page %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Consume writes every result received from results until the channel is
+// closed, so it can sit directly behind BatchProcessor.Scrape. It keeps
+// draining after a write error and returns the first one.
+func (w *Writer) Consume(results <-chan scraper.Result) error {
+	var first error
+	for r := range results {
+		var info Info
+		if w.cfg.Annotate != nil {
+			info = w.cfg.Annotate(r)
+		}
+		if err := w.WriteResult(r, info); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+type record struct {
+	headers [][2]string
+	block   []byte
+}
+
+func (w *Writer) writePage(req phantomjscloud.PageRequest, page *phantomjscloud.PageResponse, meta phantomjscloud.ResponseMetadata, info Info, pageErr error) error {
+	now := time.Now().UTC()
+	target := req.URL
+	if target == "" {
+		target = "about:blank"
+	}
+
+	reqID, err := newRecordID()
+	if err != nil {
+		return err
+	}
+	records := []record{newRecord("request", reqID, target, now, "application/http;msgtype=request", httpRequest(req))}
+
+	refersTo := reqID
+	if page != nil {
+		respID, err := newRecordID()
+		if err != nil {
+			return err
+		}
+		rec, err := contentRecord(respID, target, now, req.RenderType, page)
+		if err != nil {
+			return err
+		}
+		rec.headers = append(rec.headers, [2]string{"WARC-Concurrent-To", reqID})
+		records = append(records, rec)
+		refersTo = respID
+	}
+
+	metaID, err := newRecordID()
+	if err != nil {
+		return err
+	}
+	rec := newRecord("metadata", metaID, target, now, "application/warc-fields", metadataBlock(req, page, meta, info, pageErr))
+	rec.headers = append(rec.headers, [2]string{"WARC-Refers-To", refersTo})
+	records = append(records, rec)
+
+	return w.writeRecords(records)
+}
+
+func (w *Writer) writeRecords(records []record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	if w.file == nil || w.written >= w.cfg.MaxFileSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	for _, r := range records {
+		if err := w.writeRecord(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rotate closes the current file and opens the next one, starting it with a
+// warcinfo record. Callers must hold w.mu.
+func (w *Writer) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("warc: %w", err)
+		}
+		w.file = nil
+	}
+	w.seq++
+	name := fmt.Sprintf("%s-%s-%05d.warc", w.cfg.Prefix, time.Now().UTC().Format("20060102150405"), w.seq)
+	if w.cfg.Gzip {
+		name += ".gz"
+	}
+	path := filepath.Join(w.cfg.Dir, name)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("warc: %w", err)
+	}
+	w.file, w.written = f, 0
+	w.files = append(w.files, path)
+
+	id, err := newRecordID()
+	if err != nil {
+		return err
+	}
+	fields := warcFields([][2]string{
+		{"software", w.cfg.Software},
+		{"format", "WARC File Format 1.1"},
+		{"conformsTo", "https://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/"},
+	})
+	info := newRecord("warcinfo", id, "", time.Now().UTC(), "application/warc-fields", fields)
+	info.headers = append(info.headers, [2]string{"WARC-Filename", name})
+	return w.writeRecord(info)
+}
+
+// writeRecord serializes r to the current file. Callers must hold w.mu.
+func (w *Writer) writeRecord(r record) error {
+	var buf bytes.Buffer
+	buf.WriteString(Version + "\r\n")
+	for _, h := range r.headers {
+		buf.WriteString(singleLine(h[0]) + ": " + singleLine(h[1]) + "\r\n")
+	}
+	buf.WriteString("Content-Length: " + strconv.Itoa(len(r.block)) + "\r\n\r\n")
+	buf.Write(r.block)
+	buf.WriteString("\r\n\r\n")
+
+	var out io.Reader = &buf
+	if w.cfg.Gzip {
+		var gz bytes.Buffer
+		zw := gzip.NewWriter(&gz)
+		if _, err := zw.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("warc: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("warc: %w", err)
+		}
+		out = &gz
+	}
+	n, err := io.Copy(w.file, out)
+	w.written += n
+	if err != nil {
+		return fmt.Errorf("warc: %w", err)
+	}
+	return nil
+}
+
+func newRecord(typ, id, target string, date time.Time, contentType string, block []byte) record {
+	headers := [][2]string{
+		{"WARC-Type", typ},
+		{"WARC-Record-ID", id},
+		{"WARC-Date", date.Format("2006-01-02T15:04:05.000000Z")},
+	}
+	if target != "" {
+		headers = append(headers, [2]string{"WARC-Target-URI", target})
+	}
+	headers = append(headers,
+		[2]string{"Content-Type", contentType},
+		[2]string{"WARC-Block-Digest", digest(block)},
+	)
+	return record{headers: headers, block: block}
+}
+
+// contentRecord archives the rendition PhantomJsCloud returned for the page
+// as a resource record. The response status and headers go to the metadata
+// record.
+func contentRecord(id, target string, date time.Time, renderType string, page *phantomjscloud.PageResponse) (record, error) {
+	rr := phantomjscloud.RenderResult{RenderType: renderType, Page: *page}
+	body, err := rr.Bytes()
+	if err != nil {
+		return record{}, fmt.Errorf("warc: %w", err)
+	}
+	return newRecord("resource", id, target, date, renditionType(renderType), body), nil
+}
+
+// httpRequest reconstructs the request the browser made for the page.
+func httpRequest(req phantomjscloud.PageRequest) []byte {
+	method, path, host := "GET", "/", ""
+	if u, err := url.Parse(req.URL); err == nil && u.Host != "" {
+		host = u.Host
+		path = u.RequestURI()
+	}
+	var body string
+	headers := map[string]string{}
+	for k, v := range req.RequestSettings.CustomHeaders {
+		headers[k] = v
+	}
+	if s := req.UrlSettings; s != nil {
+		if s.Operation != "" {
+			method = strings.ToUpper(s.Operation)
+		}
+		for k, v := range s.Headers {
+			headers[k] = v
+		}
+		body = s.Data
+	}
+	if req.RequestSettings.UserAgent != "" {
+		headers["User-Agent"] = req.RequestSettings.UserAgent
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s HTTP/1.1\r\n", singleLine(method), path)
+	if host != "" {
+		b.WriteString("Host: " + host + "\r\n")
+	}
+	for _, name := range sortedKeys(headers) {
+		b.WriteString(singleLine(name) + ": " + singleLine(headers[name]) + "\r\n")
+	}
+	if body != "" {
+		fmt.Fprintf(&b, "Content-Length: %d\r\n", len(body))
+	}
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	return b.Bytes()
+}
+
+func metadataBlock(req phantomjscloud.PageRequest, page *phantomjscloud.PageResponse, meta phantomjscloud.ResponseMetadata, info Info, pageErr error) []byte {
+	renderType := req.RenderType
+	if renderType == "" {
+		renderType = "html"
+	}
+	fields := [][2]string{{"render-type", renderType}}
+	add := func(name, value string) {
+		if value != "" {
+			fields = append(fields, [2]string{name, value})
+		}
+	}
+	add("proxy", phantomjscloud.ProxyLabel(req.Proxy))
+	add("persona", info.Persona)
+	add("billing-credits", formatFloat(meta.BillingCostCredits))
+	add("billing-credit-cost", formatFloat(meta.BillingCreditCost))
+	add("api-status", meta.Status)
+	add("key-id", meta.KeyID)
+	add("done-when", meta.ContentDoneWhen)
+	if meta.Cached {
+		add("cached-at", meta.CachedAt.UTC().Format(time.RFC3339))
+	}
+	if info.Duration > 0 {
+		add("duration-ms", strconv.FormatInt(info.Duration.Milliseconds(), 10))
+	}
+	if page != nil {
+		add("status-code", strconv.Itoa(page.StatusCode))
+		add("status-text", page.StatusText)
+		for _, name := range sortedKeys(page.Headers) {
+			add("http-header", name+": "+page.Headers[name])
+		}
+		if page.Metrics.TotalRenderTimeMs > 0 {
+			add("render-time-ms", strconv.Itoa(page.Metrics.TotalRenderTimeMs))
+		}
+		if page.Metrics.BillingRenderMs > 0 {
+			add("billing-render-ms", strconv.Itoa(page.Metrics.BillingRenderMs))
+		}
+		if page.FrameData != nil && page.FrameData.Url != req.URL {
+			add("final-url", page.FrameData.Url)
+		}
+	}
+	if pageErr != nil {
+		add("error", pageErr.Error())
+	}
+	for _, name := range sortedKeys(info.Fields) {
+		add(name, info.Fields[name])
+	}
+	return warcFields(fields)
+}
+
+func warcFields(fields [][2]string) []byte {
+	var b bytes.Buffer
+	for _, f := range fields {
+		b.WriteString(singleLine(f[0]) + ": " + singleLine(f[1]) + "\r\n")
+	}
+	return b.Bytes()
+}
+
+var lineBreaks = strings.NewReplacer("\r", " ", "\n", " ")
+
+// singleLine replaces CR and LF in a header name or value, so a value taken
+// from a page or request cannot start a header of its own.
+func singleLine(s string) string {
+	return lineBreaks.Replace(s)
+}
+
+func renditionType(renderType string) string {
+	switch renderType {
+	case "png":
+		return "image/png"
+	case "jpeg", "jpg":
+		return "image/jpeg"
+	case "pdf":
+		return "application/pdf"
+	case "plainText":
+		return "text/plain; charset=utf-8"
+	case "automation", "script":
+		return "application/json"
+	case "", "html":
+		return "text/html; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
+
+func newRecordID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("warc: record id: %w", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+func digest(b []byte) string {
+	sum := sha1.Sum(b)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+func formatFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
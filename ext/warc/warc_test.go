@@ -0,0 +1,250 @@
+package warc
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+	"github.com/amafjarkasi/go-phantomjs/ext/scraper"
+)
+
+type testRecord struct {
+	headers map[string]string
+	block   string
+}
+
+// readRecords parses every record in a WARC file, gzipped or not.
+func readRecords(t *testing.T, path string) []testRecord {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gz
+	}
+	br := bufio.NewReader(r)
+
+	var out []testRecord
+	for {
+		line, err := br.ReadString('\n')
+		if err == io.EOF {
+			return out
+		}
+		if err != nil || line != Version+"\r\n" {
+			t.Fatalf("expected version line, got %q (%v)", line, err)
+		}
+		rec := testRecord{headers: map[string]string{}}
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line == "\r\n" {
+				break
+			}
+			name, value, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ": ")
+			rec.headers[name] = value
+		}
+		n, err := strconv.Atoi(rec.headers["Content-Length"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := make([]byte, n+4)
+		if _, err := io.ReadFull(br, block); err != nil {
+			t.Fatal(err)
+		}
+		if string(block[n:]) != "\r\n\r\n" {
+			t.Fatalf("record not terminated by CRLF CRLF")
+		}
+		rec.block = string(block[:n])
+		out = append(out, rec)
+	}
+}
+
+func TestWriter_WriteResult(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(Config{Dir: dir, Gzip: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.WriteResult(scraper.Result{
+		Request: phantomjscloud.PageRequest{
+			URL:         "https://example.com/search?q=1",
+			Proxy:       "anon-us",
+			UrlSettings: &phantomjscloud.UrlSettings{Operation: "post", Data: "q=1"},
+		},
+		Response: &phantomjscloud.PageResponse{
+			Content:    "<html>hi</html>",
+			StatusCode: 200,
+			Headers:    map[string]string{"Content-Type": "text/html", "Content-Encoding": "gzip"},
+			Metrics:    phantomjscloud.Metrics{TotalRenderTimeMs: 1200},
+		},
+		Metadata: phantomjscloud.ResponseMetadata{BillingCostCredits: 0.5, KeyID: "key-abcd"},
+		Duration: 1500 * time.Millisecond,
+	}, Info{Persona: "desktop-us", Fields: map[string]string{"job": "nightly"}})
+	if err != nil {
+		t.Fatalf("WriteResult: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files := w.Files()
+	if len(files) != 1 || !strings.HasSuffix(files[0], ".warc.gz") {
+		t.Fatalf("unexpected files %v", files)
+	}
+	records := readRecords(t, files[0])
+	if len(records) != 4 {
+		t.Fatalf("expected warcinfo, request, resource and metadata records, got %d", len(records))
+	}
+	info, req, resp, meta := records[0], records[1], records[2], records[3]
+	if info.headers["WARC-Type"] != "warcinfo" {
+		t.Errorf("unexpected first record %v", info.headers)
+	}
+	if req.headers["WARC-Type"] != "request" || !strings.HasPrefix(req.block, "POST /search?q=1 HTTP/1.1\r\nHost: example.com\r\n") || !strings.HasSuffix(req.block, "\r\n\r\nq=1") {
+		t.Errorf("unexpected request record %v %q", req.headers, req.block)
+	}
+	// The rendered DOM is archived as what it is, not as an HTTP response.
+	if resp.headers["WARC-Type"] != "resource" || resp.headers["Content-Type"] != "text/html; charset=utf-8" || resp.headers["WARC-Concurrent-To"] != req.headers["WARC-Record-ID"] {
+		t.Errorf("unexpected resource headers %v", resp.headers)
+	}
+	if resp.block != "<html>hi</html>" {
+		t.Errorf("unexpected resource block %q", resp.block)
+	}
+	if meta.headers["WARC-Refers-To"] != resp.headers["WARC-Record-ID"] {
+		t.Errorf("metadata does not refer to the response: %v", meta.headers)
+	}
+	for _, want := range []string{"proxy: anon-us", "persona: desktop-us", "status-code: 200", "http-header: Content-Encoding: gzip", "http-header: Content-Type: text/html", "billing-credits: 0.5", "duration-ms: 1500", "render-time-ms: 1200", "job: nightly"} {
+		if !strings.Contains(meta.block, want+"\r\n") {
+			t.Errorf("metadata missing %q:\n%s", want, meta.block)
+		}
+	}
+	if err := w.WriteResult(scraper.Result{}, Info{}); !errors.Is(err, ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestWriter_RotatesBySize(t *testing.T) {
+	w, err := NewWriter(Config{Dir: t.TempDir(), MaxFileSize: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	req := &phantomjscloud.UserRequest{Pages: []phantomjscloud.PageRequest{
+		{URL: "https://example.com/a.png", RenderType: "png"},
+		{URL: "https://example.com/b"},
+	}}
+	res := &phantomjscloud.UserResponseWithMeta{}
+	res.PageResponses = []phantomjscloud.PageResponse{
+		{Content: "iVBORw0KGgo=", StatusCode: 200},
+		{Content: strings.Repeat("x", 600), StatusCode: 200},
+	}
+	if err := w.WriteResponse(req, res, Info{}); err != nil {
+		t.Fatalf("WriteResponse: %v", err)
+	}
+
+	files := w.Files()
+	if len(files) != 2 {
+		t.Fatalf("expected rotation into 2 files, got %v", files)
+	}
+	first := readRecords(t, files[0])
+	if len(first) != 4 || first[2].headers["WARC-Type"] != "resource" || first[2].headers["Content-Type"] != "image/png" {
+		t.Fatalf("unexpected first file %+v", first)
+	}
+	if first[2].block != "\x89PNG\r\n\x1a\n" {
+		t.Errorf("expected decoded png bytes, got %q", first[2].block)
+	}
+	if second := readRecords(t, files[1]); len(second) != 4 || second[0].headers["WARC-Type"] != "warcinfo" {
+		t.Fatalf("expected the second file to start with warcinfo, got %+v", second)
+	}
+}
+
+func TestWriter_ConsumeScrape(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"pageResponses":[{"content":"<p>one</p>","statusCode":200},{"content":"<p>two</p>","statusCode":404}]}`))
+	}))
+	defer server.Close()
+
+	client := phantomjscloud.NewClient("test-key", phantomjscloud.WithEndpoint(server.URL+"/"))
+	w, err := NewWriter(Config{
+		Dir:      t.TempDir(),
+		Annotate: func(r scraper.Result) Info { return Info{Persona: "mobile"} },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	results := scraper.NewBatchProcessor(client, 1, 2).Scrape(context.Background(), []phantomjscloud.PageRequest{
+		{URL: "https://example.com/1"},
+		{URL: "https://example.com/2"},
+	})
+	if err := w.Consume(results); err != nil {
+		t.Fatalf("Consume: %v", err)
+	}
+
+	records := readRecords(t, w.Files()[0])
+	if len(records) != 7 {
+		t.Fatalf("expected warcinfo plus 3 records per page, got %d", len(records))
+	}
+	if records[5].block != "<p>two</p>" || !strings.Contains(records[6].block, "status-code: 404\r\n") {
+		t.Errorf("unexpected second page %q %q", records[5].block, records[6].block)
+	}
+	if !strings.Contains(records[6].block, "persona: mobile\r\n") {
+		t.Errorf("expected annotated persona, got %q", records[6].block)
+	}
+}
+
+func TestWriter_StripsLineBreaksFromHeaders(t *testing.T) {
+	w, err := NewWriter(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	inject := "x\r\nX-Injected: 1"
+	err = w.WriteResult(scraper.Result{
+		Request: phantomjscloud.PageRequest{
+			URL:             "https://example.com/" + inject,
+			RequestSettings: phantomjscloud.RequestSettings{CustomHeaders: map[string]string{"X-Custom": inject, inject: "v"}},
+		},
+		Response: &phantomjscloud.PageResponse{
+			Content:    "<p>hi</p>",
+			StatusCode: 200,
+			Headers:    map[string]string{"Set-Cookie": inject},
+		},
+	}, Info{Fields: map[string]string{"note": inject}})
+	if err != nil {
+		t.Fatalf("WriteResult: %v", err)
+	}
+
+	for _, rec := range readRecords(t, w.Files()[0]) {
+		if _, ok := rec.headers["X-Injected"]; ok {
+			t.Errorf("header injected into %s record: %v", rec.headers["WARC-Type"], rec.headers)
+		}
+		if strings.Contains(rec.block, "\r\nX-Injected") || strings.Contains(rec.block, "\nX-Injected") {
+			t.Errorf("line injected into %s block: %q", rec.headers["WARC-Type"], rec.block)
+		}
+	}
+}
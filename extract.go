@@ -0,0 +1,466 @@
+package phantomjscloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrMissingValue is matched through errors.Is when a required field found
+// no element or attribute on the page.
+var ErrMissingValue = errors.New("phantomjscloud: missing value")
+
+// ExtractFieldError is a problem with one extracted field, located by its Go
+// field path, e.g. "Items[2].Price".
+type ExtractFieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *ExtractFieldError) Error() string { return e.Field + ": " + e.Err.Error() }
+
+// Unwrap returns the underlying error, such as ErrMissingValue.
+func (e *ExtractFieldError) Unwrap() error { return e.Err }
+
+// ExtractionError lists every field that could not be extracted. Fields that
+// decoded are still set on the target.
+type ExtractionError struct {
+	Errors []*ExtractFieldError
+}
+
+func (e *ExtractionError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "phantomjscloud: extraction failed: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the individual field errors.
+func (e *ExtractionError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// Extractor is a struct compiled into an extraction script. Fields are
+// mapped with `pjsc` tags:
+//
+//	type Product struct {
+//	    Title  string    `pjsc:"css=h1"`
+//	    Price  float64   `pjsc:"css=meta[itemprop=price],attr=content"`
+//	    Image  *url.URL  `pjsc:"css=img.hero,attr=src"`
+//	    Listed time.Time `pjsc:"css=.listed,format=Jan 2, 2006,optional"`
+//	    Specs  []struct {
+//	        Name  string `pjsc:"css=th"`
+//	        Value string `pjsc:"css=td"`
+//	    } `pjsc:"css=table.specs tr"`
+//	}
+//
+// css selects the element, relative to the enclosing struct's element; for
+// slices it selects every match. attr reads an attribute instead of the
+// trimmed text, and html reads the inner HTML. Numbers, bools, time.Time
+// (with an optional format layout) and url.URL values resolved against the
+// page URL are coerced from the text. Pointer fields and fields marked
+// optional may be missing; any other missing field is an ErrMissingValue.
+type Extractor struct {
+	typ    reflect.Type
+	fields []*extractField
+	spec   []byte
+}
+
+type extractField struct {
+	Name     string          `json:"name"`
+	CSS      string          `json:"css,omitempty"`
+	Attr     string          `json:"attr,omitempty"`
+	HTML     bool            `json:"html,omitempty"`
+	List     bool            `json:"list,omitempty"`
+	Fields   []*extractField `json:"fields,omitempty"`
+	index    int
+	optional bool
+	format   string
+	// typ is the leaf or struct type, with slices and pointers removed.
+	typ reflect.Type
+	ptr bool
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	urlType  = reflect.TypeOf(url.URL{})
+)
+
+// NewExtractor compiles the pjsc tags of v, a struct or pointer to struct.
+func NewExtractor(v any) (*Extractor, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("phantomjscloud: extractor needs a struct, got %T", v)
+	}
+	fields, err := compileFields(t, t.Name(), map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("phantomjscloud: %s has no pjsc tags", t)
+	}
+	spec, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("phantomjscloud: encode extraction spec: %w", err)
+	}
+	return &Extractor{typ: t, fields: fields, spec: spec}, nil
+}
+
+// compileFields compiles the tagged fields of t. active holds the struct types
+// being compiled on the current path, so recursive types are rejected.
+func compileFields(t reflect.Type, path string, active map[reflect.Type]bool) ([]*extractField, error) {
+	active[t] = true
+	defer delete(active, t)
+	var out []*extractField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("pjsc")
+		if !ok || tag == "-" {
+			continue
+		}
+		if !sf.IsExported() {
+			return nil, fmt.Errorf("phantomjscloud: %s.%s: pjsc tag on unexported field", path, sf.Name)
+		}
+		f, err := parseExtractTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("phantomjscloud: %s.%s: %w", path, sf.Name, err)
+		}
+		f.Name, f.index = sf.Name, i
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8 {
+			f.List = true
+			ft = ft.Elem()
+			if f.CSS == "" {
+				return nil, fmt.Errorf("phantomjscloud: %s.%s: slices need a css selector", path, sf.Name)
+			}
+		}
+		if ft.Kind() == reflect.Pointer {
+			f.ptr = true
+			ft = ft.Elem()
+		}
+		f.typ = ft
+
+		switch {
+		case ft.Kind() == reflect.Struct && ft != timeType && ft != urlType:
+			if f.Attr != "" || f.HTML {
+				return nil, fmt.Errorf("phantomjscloud: %s.%s: attr and html do not apply to structs", path, sf.Name)
+			}
+			if active[ft] {
+				return nil, fmt.Errorf("phantomjscloud: %s.%s: recursive type %s", path, sf.Name, ft)
+			}
+			nested, err := compileFields(ft, path+"."+sf.Name, active)
+			if err != nil {
+				return nil, err
+			}
+			f.Fields = nested
+		case !extractable(ft):
+			return nil, fmt.Errorf("phantomjscloud: %s.%s: unsupported type %s", path, sf.Name, sf.Type)
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+func extractable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return t == timeType || t == urlType
+}
+
+// parseExtractTag splits a tag into its options. Commas inside a value, as
+// in "css=h1, h2" or "format=Jan 2, 2006", belong to that value unless they
+// start a known option.
+func parseExtractTag(tag string) (*extractField, error) {
+	var parts []string
+	for _, p := range strings.Split(tag, ",") {
+		key, _, _ := strings.Cut(strings.TrimSpace(p), "=")
+		switch key {
+		case "css", "attr", "format", "optional", "html":
+			parts = append(parts, strings.TrimSpace(p))
+		default:
+			if len(parts) == 0 {
+				return nil, fmt.Errorf("unknown pjsc option %q", p)
+			}
+			parts[len(parts)-1] += "," + p
+		}
+	}
+
+	f := &extractField{}
+	for _, p := range parts {
+		key, value, _ := strings.Cut(p, "=")
+		switch key {
+		case "css":
+			f.CSS = value
+		case "attr":
+			f.Attr = value
+		case "format":
+			f.format = value
+		case "optional":
+			f.optional = true
+		case "html":
+			f.HTML = true
+		}
+	}
+	return f, nil
+}
+
+// extractJS runs in the page. It walks the spec from the document, reading
+// text, attributes or inner HTML, and reports the page URL for resolving
+// relative links.
+const extractJS = `(spec) => {
+  const read = (el, f) => f.attr ? el.getAttribute(f.attr) : f.html ? el.innerHTML : (el.textContent || "").trim();
+  const walk = (root, fields) => {
+    const out = {};
+    for (const f of fields) {
+      const one = (el) => f.fields ? walk(el, f.fields) : read(el, f);
+      if (f.list) {
+        out[f.name] = Array.from(root.querySelectorAll(f.css)).map(one);
+        continue;
+      }
+      const el = f.css ? root.querySelector(f.css) : root;
+      out[f.name] = el ? one(el) : null;
+    }
+    return out;
+  };
+  return {url: location.href, data: walk(document, spec)};
+}`
+
+// Extract appends a step that runs x in the page and returns its result
+// through __pjsc_result.
+func (b *OverseerScriptBuilder) Extract(x *Extractor) *OverseerScriptBuilder {
+	b.script.WriteString("window.__pjsc_result = await page.evaluate(")
+	b.script.WriteString(extractJS)
+	b.script.WriteString(", ")
+	// The spec is JSON, so it is already a safely escaped JS literal.
+	b.script.Write(x.spec)
+	b.script.WriteString(");\n")
+	return b
+}
+
+// Script returns a complete overseer script that only runs x.
+func (x *Extractor) Script() string {
+	return NewOverseerScriptBuilder().Extract(x).Build()
+}
+
+// Decode coerces an automation result produced by the extraction script into
+// into, which must point to the struct x was compiled from. It returns an
+// *ExtractionError listing every missing or unparseable field.
+func (x *Extractor) Decode(result interface{}, into any) error {
+	rv := reflect.ValueOf(into)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Type() != x.typ {
+		return fmt.Errorf("phantomjscloud: Decode needs a *%s, got %T", x.typ, into)
+	}
+	if result == nil {
+		return ErrNoAutomationResult
+	}
+	envelope, ok := result.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("phantomjscloud: unexpected extraction result %T", result)
+	}
+	data, ok := envelope["data"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("phantomjscloud: extraction result has no data")
+	}
+	d := &extractDecoder{}
+	if s, ok := envelope["url"].(string); ok {
+		d.base, _ = url.Parse(s)
+	}
+	d.object(rv.Elem(), x.fields, data, "")
+	if len(d.errs) > 0 {
+		return &ExtractionError{Errors: d.errs}
+	}
+	return nil
+}
+
+// Extract renders pageURL with an automation script compiled from into's
+// pjsc tags, and decodes the result into it.
+func (c *Client) Extract(ctx context.Context, pageURL string, into any) error {
+	x, err := NewExtractor(into)
+	if err != nil {
+		return err
+	}
+	res, err := c.Render(ctx, &PageRequest{
+		URL:            pageURL,
+		RenderType:     "automation",
+		OverseerScript: x.Script(),
+		OutputAsJson:   true,
+	})
+	if err != nil {
+		return err
+	}
+	return x.Decode(res.Page.AutomationResult, into)
+}
+
+type extractDecoder struct {
+	base *url.URL
+	errs []*ExtractFieldError
+}
+
+func (d *extractDecoder) fail(path string, err error) {
+	d.errs = append(d.errs, &ExtractFieldError{Field: path, Err: err})
+}
+
+func (d *extractDecoder) object(v reflect.Value, fields []*extractField, data map[string]interface{}, path string) {
+	for _, f := range fields {
+		fv := v.Field(f.index)
+		fpath := f.Name
+		if path != "" {
+			fpath = path + "." + f.Name
+		}
+		raw := data[f.Name]
+		if !f.List {
+			d.value(fv, f, raw, fpath)
+			continue
+		}
+		items, _ := raw.([]interface{})
+		slice := reflect.MakeSlice(fv.Type(), len(items), len(items))
+		for i, item := range items {
+			d.value(slice.Index(i), f, item, fmt.Sprintf("%s[%d]", fpath, i))
+		}
+		fv.Set(slice)
+	}
+}
+
+func (d *extractDecoder) value(v reflect.Value, f *extractField, raw interface{}, path string) {
+	if raw == nil {
+		if !f.ptr && !f.optional {
+			d.fail(path, ErrMissingValue)
+		}
+		return
+	}
+	if f.ptr {
+		p := reflect.New(f.typ)
+		v.Set(p)
+		v = p.Elem()
+	}
+	if f.Fields != nil {
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			d.fail(path, fmt.Errorf("expected an object, got %T", raw))
+			return
+		}
+		d.object(v, f.Fields, obj, path)
+		return
+	}
+	s, ok := raw.(string)
+	if !ok {
+		s = fmt.Sprint(raw)
+	}
+	if err := d.coerce(v, f, s); err != nil {
+		d.fail(path, err)
+	}
+}
+
+var numberRe = regexp.MustCompile(`[-+]?\d[\d,]*(?:\.\d+)?|[-+]?\.\d+`)
+
+// coerce parses s into v. Numbers are taken from the first number in the
+// text, so "$1,299.00" is 1299; commas are read as thousands separators.
+func (d *extractDecoder) coerce(v reflect.Value, f *extractField, s string) error {
+	switch {
+	case f.typ == timeType:
+		t, err := parseExtractTime(s, f.format)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case f.typ == urlType:
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return fmt.Errorf("invalid URL %q", s)
+		}
+		if d.base != nil {
+			u = d.base.ResolveReference(u)
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+
+	switch f.typ.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "true", "yes", "on", "1":
+			v.SetBool(true)
+		case "false", "no", "off", "0", "":
+			v.SetBool(false)
+		default:
+			return fmt.Errorf("cannot read %q as a bool", s)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.SplitN(extractNumber(s), ".", 2)[0], 10, f.typ.Bits())
+		if err != nil {
+			return fmt.Errorf("cannot read %q as %s", s, f.typ)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(strings.TrimPrefix(strings.SplitN(extractNumber(s), ".", 2)[0], "+"), 10, f.typ.Bits())
+		if err != nil {
+			return fmt.Errorf("cannot read %q as %s", s, f.typ)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(extractNumber(s), f.typ.Bits())
+		if err != nil {
+			return fmt.Errorf("cannot read %q as %s", s, f.typ)
+		}
+		v.SetFloat(n)
+	}
+	return nil
+}
+
+func extractNumber(s string) string {
+	return strings.ReplaceAll(numberRe.FindString(s), ",", "")
+}
+
+var extractTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"2 January 2006",
+	"02 Jan 2006",
+}
+
+func parseExtractTime(s, layout string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if layout != "" {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot read %q as a time in layout %q", s, layout)
+		}
+		return t, nil
+	}
+	for _, l := range extractTimeLayouts {
+		if t, err := time.Parse(l, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot read %q as a time; set a format layout", s)
+}
@@ -0,0 +1,157 @@
+package phantomjscloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type extractProduct struct {
+	Title   string    `pjsc:"css=h1"`
+	Price   float64   `pjsc:"css=meta[itemprop=price],attr=content"`
+	Stock   int       `pjsc:"css=.stock"`
+	OnSale  bool      `pjsc:"css=.sale,attr=data-active"`
+	Listed  time.Time `pjsc:"css=.listed,format=Jan 2, 2006"`
+	Image   *url.URL  `pjsc:"css=img.hero,attr=src"`
+	Summary *string   `pjsc:"css=.summary"`
+	Notes   string    `pjsc:"css=.notes, .remarks,optional"`
+	Reviews []struct {
+		Author string  `pjsc:"css=.author"`
+		Rating float32 `pjsc:"css=.rating"`
+		Link   url.URL `pjsc:"css=a,attr=href"`
+	} `pjsc:"css=.review"`
+	Tags []string `pjsc:"css=.tag"`
+}
+
+// extractNode refers to itself, which an extraction spec cannot express.
+type extractNode struct {
+	Name string        `pjsc:"css=span"`
+	Kids []extractNode `pjsc:"css=li"`
+}
+
+func TestNewExtractor_Script(t *testing.T) {
+	x, err := NewExtractor(&extractProduct{})
+	if err != nil {
+		t.Fatalf("NewExtractor: %v", err)
+	}
+	script := x.Script()
+	for _, want := range []string{
+		"window.__pjsc_result = await page.evaluate(",
+		`{"name":"Price","css":"meta[itemprop=price]","attr":"content"}`,
+		`{"name":"Notes","css":".notes, .remarks"}`,
+		`{"name":"Reviews","css":".review","list":true,"fields":[`,
+		"window.__pjsc_result;\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("script missing %q:\n%s", want, script)
+		}
+	}
+
+	for _, v := range []any{
+		"not a struct",
+		&struct{ A string }{},
+		&struct {
+			A map[string]string `pjsc:"css=a"`
+		}{},
+		&struct {
+			A []string `pjsc:"attr=href"`
+		}{},
+		&struct {
+			A string `pjsc:"selector=a"`
+		}{},
+		&extractNode{},
+	} {
+		if _, err := NewExtractor(v); err == nil {
+			t.Errorf("NewExtractor(%T) should fail", v)
+		}
+	}
+}
+
+func TestExtractor_Decode(t *testing.T) {
+	x, err := NewExtractor(extractProduct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result interface{}
+	_ = json.Unmarshal([]byte(`{"url":"https://shop.example/p/1","data":{
+		"Title":"Widget","Price":"$1,299.50","Stock":"12 in stock","OnSale":"yes",
+		"Listed":"Mar 4, 2024","Image":"/img/w.png","Summary":null,"Notes":null,
+		"Reviews":[{"Author":"Ann","Rating":"4.5 / 5","Link":"#r1"},{"Author":"Bo","Rating":"3","Link":"https://other.example/r"}],
+		"Tags":["new","sale"]}}`), &result)
+
+	var p extractProduct
+	if err := x.Decode(result, &p); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if p.Title != "Widget" || p.Price != 1299.5 || p.Stock != 12 || !p.OnSale {
+		t.Errorf("unexpected scalars %+v", p)
+	}
+	if !p.Listed.Equal(time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Listed = %v", p.Listed)
+	}
+	if p.Image == nil || p.Image.String() != "https://shop.example/img/w.png" {
+		t.Errorf("Image = %v", p.Image)
+	}
+	if p.Summary != nil {
+		t.Errorf("Summary should stay nil, got %q", *p.Summary)
+	}
+	if len(p.Reviews) != 2 || p.Reviews[0].Rating != 4.5 || p.Reviews[0].Link.String() != "https://shop.example/p/1#r1" || p.Reviews[1].Link.Host != "other.example" {
+		t.Errorf("unexpected reviews %+v", p.Reviews)
+	}
+	if strings.Join(p.Tags, ",") != "new,sale" {
+		t.Errorf("Tags = %v", p.Tags)
+	}
+}
+
+func TestExtractor_DecodeErrors(t *testing.T) {
+	x, err := NewExtractor(extractProduct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result interface{}
+	_ = json.Unmarshal([]byte(`{"url":"https://shop.example/","data":{
+		"Title":null,"Price":"free","Stock":"3","OnSale":"maybe","Listed":"2024-03-04",
+		"Reviews":[{"Author":"Ann","Rating":"5","Link":null}],"Tags":[]}}`), &result)
+
+	var p extractProduct
+	err = x.Decode(result, &p)
+	var xerr *ExtractionError
+	if !errors.As(err, &xerr) || !errors.Is(err, ErrMissingValue) {
+		t.Fatalf("expected an ExtractionError with missing values, got %v", err)
+	}
+	var fields []string
+	for _, fe := range xerr.Errors {
+		fields = append(fields, fe.Field)
+	}
+	if got := strings.Join(fields, ","); got != "Title,Price,OnSale,Listed,Reviews[0].Link" {
+		t.Errorf("failed fields = %s (%v)", got, err)
+	}
+	if p.Stock != 3 || len(p.Reviews) != 1 || p.Reviews[0].Author != "Ann" {
+		t.Errorf("fields that decoded should still be set: %+v", p)
+	}
+
+	if err := x.Decode(result, &struct{}{}); err == nil {
+		t.Error("Decode into the wrong type should fail")
+	}
+	if err := x.Decode(nil, &p); !errors.Is(err, ErrNoAutomationResult) {
+		t.Errorf("expected ErrNoAutomationResult, got %v", err)
+	}
+}
+
+func TestClient_Extract(t *testing.T) {
+	client := newRenderServer(t, "application/json", []byte(`{"pageResponses":[{"automationResult":{"url":"https://example.com/","data":{"Heading":"Example Domain","More":"https://www.iana.org/domains/example"}}}]}`))
+	var page struct {
+		Heading string  `pjsc:"css=h1"`
+		More    url.URL `pjsc:"css=a,attr=href"`
+	}
+	if err := client.Extract(context.Background(), "https://example.com", &page); err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if page.Heading != "Example Domain" || page.More.Host != "www.iana.org" {
+		t.Errorf("unexpected page %+v", page)
+	}
+}
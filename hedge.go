@@ -0,0 +1,288 @@
+package phantomjscloud
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultHedgeQuantile is the latency quantile after which a hedge is sent.
+const DefaultHedgeQuantile = 0.95
+
+// hedgeWindow is the number of recent latencies kept per render type.
+const hedgeWindow = 256
+
+// HedgeConfig configures hedged requests, installed with WithHedging.
+type HedgeConfig struct {
+	// Quantile of observed latency for the render type after which a duplicate
+	// request is sent. Defaults to DefaultHedgeQuantile.
+	Quantile float64
+	// MinSamples is the number of observed latencies needed before hedging a
+	// render type. Defaults to 20.
+	MinSamples int
+	// MinDelay is a lower bound on the hedge delay.
+	MinDelay time.Duration
+	// MaxExtraCredits caps the credits spent on duplicate requests over the
+	// client's lifetime. Hedging stops once it is reached. Zero means no cap.
+	MaxExtraCredits float64
+	// RenderTypes limits hedging to these render types, e.g. "png" and "jpeg".
+	// Empty means every render type.
+	RenderTypes []string
+}
+
+// WithHedging sends a duplicate of a slow attempt once it has run longer than
+// the configured quantile of recent latencies for its render type. The first
+// successful response wins and the other request is cancelled. The winner is
+// reported in ResponseMetadata.Hedged and HedgeWinner.
+//
+// A cancelled request may still be billed, so each hedge is charged to
+// MaxExtraCredits at the winner's cost. That cost is also added to the
+// credits of the client's Ledger for every sent request that returned no
+// response. No hedge is sent while a half-open circuit breaker has no probe
+// slot left for it.
+func WithHedging(cfg HedgeConfig) ClientOption {
+	return func(c *Client) {
+		if cfg.Quantile <= 0 || cfg.Quantile >= 1 {
+			cfg.Quantile = DefaultHedgeQuantile
+		}
+		if cfg.MinSamples < 1 {
+			cfg.MinSamples = 20
+		}
+		h := &hedger{cfg: cfg, samples: make(map[string]*latencyWindow)}
+		if len(cfg.RenderTypes) > 0 {
+			h.only = make(map[string]bool)
+			for _, rt := range cfg.RenderTypes {
+				h.only[ledgerRenderType(rt)] = true
+			}
+		}
+		c.hedger = h
+	}
+}
+
+// HedgeStats reports hedging activity.
+type HedgeStats struct {
+	// Hedges is the number of duplicate requests sent.
+	Hedges int
+	// HedgeWins is the number of calls answered first by the duplicate.
+	HedgeWins int
+	// ExtraCredits is the estimated spend on duplicates.
+	ExtraCredits float64
+}
+
+// HedgeStats returns a snapshot of the hedging counters. It returns the zero
+// value when WithHedging was not used.
+func (c *Client) HedgeStats() HedgeStats {
+	h := c.hedger
+	if h == nil {
+		return HedgeStats{}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.stats
+}
+
+type hedger struct {
+	cfg  HedgeConfig
+	only map[string]bool
+
+	mu      sync.Mutex
+	samples map[string]*latencyWindow
+	stats   HedgeStats
+}
+
+// latencyWindow is a ring buffer of recent latencies.
+type latencyWindow struct {
+	values []time.Duration
+	next   int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	if len(w.values) < hedgeWindow {
+		w.values = append(w.values, d)
+		return
+	}
+	w.values[w.next] = d
+	w.next = (w.next + 1) % hedgeWindow
+}
+
+func (w *latencyWindow) quantile(q float64) time.Duration {
+	sorted := append([]time.Duration(nil), w.values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	idx := int(q * float64(len(sorted)))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+func (h *hedger) observe(renderType string, d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w := h.samples[renderType]
+	if w == nil {
+		w = &latencyWindow{}
+		h.samples[renderType] = w
+	}
+	w.add(d)
+}
+
+// delay returns how long to wait before hedging a call of renderType, and
+// false when the call must not be hedged.
+func (h *hedger) delay(renderType string) (time.Duration, bool) {
+	if h.only != nil && !h.only[renderType] {
+		return 0, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cfg.MaxExtraCredits > 0 && h.stats.ExtraCredits >= h.cfg.MaxExtraCredits {
+		return 0, false
+	}
+	w := h.samples[renderType]
+	if w == nil || len(w.values) < h.cfg.MinSamples {
+		return 0, false
+	}
+	return max(w.quantile(h.cfg.Quantile), h.cfg.MinDelay), true
+}
+
+// reserve reports whether the extra-spend cap still allows a hedge, and
+// counts the hedge when it does.
+func (h *hedger) reserve() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cfg.MaxExtraCredits > 0 && h.stats.ExtraCredits >= h.cfg.MaxExtraCredits {
+		return false
+	}
+	h.stats.Hedges++
+	return true
+}
+
+// settle charges a hedged call's estimated duplicate cost and records its winner.
+func (h *hedger) settle(winner int, credits float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.stats.ExtraCredits += credits
+	if winner > 0 {
+		h.stats.HedgeWins++
+	}
+}
+
+// hedgeRenderType keys latency samples by the render type shared by every
+// page of req, or "mixed".
+func hedgeRenderType(req *UserRequest) string {
+	if len(req.Pages) == 0 {
+		return ""
+	}
+	rt := ledgerRenderType(req.Pages[0].RenderType)
+	for _, p := range req.Pages[1:] {
+		if ledgerRenderType(p.RenderType) != rt {
+			return "mixed"
+		}
+	}
+	return rt
+}
+
+type hedgeResult struct {
+	index int
+	res   *UserResponseWithMeta
+	err   error
+	took  time.Duration
+	sent  bool
+}
+
+// doAttempt runs one attempt, hedging it when WithHedging is configured.
+func (c *Client) doAttempt(ctx context.Context, req *UserRequest, attempt int) (*UserResponseWithMeta, error) {
+	h := c.hedger
+	if h == nil {
+		return c.doSingle(ctx, req, attempt, nil)
+	}
+	rt := hedgeRenderType(req)
+	delay, ok := h.delay(rt)
+	if !ok {
+		start := time.Now()
+		res, err := c.doSingle(ctx, req, attempt, nil)
+		if err == nil {
+			h.observe(rt, time.Since(start))
+		}
+		return res, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan hedgeResult, 2)
+	launch := func(index int) {
+		go func() {
+			var sent bool
+			start := time.Now()
+			res, err := c.doSingle(ctx, req, attempt, &sent)
+			results <- hedgeResult{index: index, res: res, err: err, took: time.Since(start), sent: sent}
+		}()
+	}
+	launch(0)
+	pending, hedged := 1, false
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var failed *hedgeResult
+	unbilled := 0
+	for {
+		select {
+		case <-timer.C:
+			// A breaker with no probe slot left would reject the hedge and
+			// count it as a failure.
+			if c.breakers.allows(req) && h.reserve() {
+				launch(1)
+				pending++
+				hedged = true
+			}
+		case r := <-results:
+			pending--
+			if r.err != nil {
+				if r.sent {
+					unbilled++
+				}
+				if failed == nil || r.index == 0 {
+					failed = &r
+				}
+				if pending > 0 {
+					continue
+				}
+				return nil, failed.err
+			}
+			h.observe(rt, r.took)
+			if hedged {
+				cost := creditCost(r.res)
+				h.settle(r.index, cost)
+				c.chargeHedgeLosers(ctx, req, results, pending, unbilled, cost)
+				r.res.Metadata.Hedged = true
+				r.res.Metadata.HedgeWinner = r.index
+			}
+			return r.res, nil
+		}
+	}
+}
+
+// chargeHedgeLosers charges the ledger for each request of a hedged call
+// that was sent but returned no response, estimating its cost as the
+// winner's. Only credits are added: the request and page counts cover
+// responses. Losers still in flight are cancelled by the caller and waited
+// for in the background. Losers that succeed were already recorded by doSingle.
+func (c *Client) chargeHedgeLosers(ctx context.Context, req *UserRequest, results <-chan hedgeResult, pending, unbilled int, cost float64) {
+	if c.ledger == nil {
+		return
+	}
+	// ctx is cancelled once the winner returns; keep only its values, such as
+	// the ledger tags.
+	ctx = context.WithoutCancel(ctx)
+	c.ledger.RecordCredits(ctx, req, float64(unbilled)*cost)
+	if pending == 0 {
+		return
+	}
+	go func() {
+		for i := 0; i < pending; i++ {
+			if r := <-results; r.err != nil && r.sent {
+				c.ledger.RecordCredits(ctx, req, cost)
+			}
+		}
+	}()
+}
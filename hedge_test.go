@@ -0,0 +1,181 @@
+package phantomjscloud
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowFirstServer stalls every odd-numbered request until the client gives up
+// and answers the others immediately.
+func slowFirstServer(t *testing.T, hits *int32, canceled chan<- struct{}) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		if atomic.AddInt32(hits, 1)%2 == 1 {
+			select {
+			case <-r.Context().Done():
+				if canceled != nil {
+					canceled <- struct{}{}
+				}
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Pjsc-Billing-Cost-Credits", "1")
+		_, _ = w.Write([]byte(`{"pageResponses":[{"statusCode":200}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func seedHedger(c *Client, rt string, n int, d time.Duration) {
+	for i := 0; i < n; i++ {
+		c.hedger.observe(rt, d)
+	}
+}
+
+func TestHedging_DuplicateWinsAndLoserIsCanceled(t *testing.T) {
+	var hits int32
+	canceled := make(chan struct{}, 1)
+	server := slowFirstServer(t, &hits, canceled)
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithHedging(HedgeConfig{MinSamples: 5}))
+	seedHedger(client, "png", 5, 20*time.Millisecond)
+
+	res, err := client.DoPage(&PageRequest{URL: "https://example.com", RenderType: "png"})
+	if err != nil {
+		t.Fatalf("DoPage: %v", err)
+	}
+	if !res.Metadata.Hedged || res.Metadata.HedgeWinner != 1 {
+		t.Fatalf("expected hedge to win, got %+v", res.Metadata)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the slow request to be canceled")
+	}
+
+	stats := client.HedgeStats()
+	if stats.Hedges != 1 || stats.HedgeWins != 1 || stats.ExtraCredits != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestHedging_RespectsSpendCap(t *testing.T) {
+	var hits int32
+	server := slowFirstServer(t, &hits, nil)
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"),
+		WithTimeout(200*time.Millisecond),
+		WithHedging(HedgeConfig{MinSamples: 5, MaxExtraCredits: 1}))
+	seedHedger(client, "html", 5, 20*time.Millisecond)
+
+	if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); err != nil {
+		t.Fatalf("first DoPage: %v", err)
+	}
+	// The cap is spent, so the next slow request is not hedged and times out.
+	if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); err == nil {
+		t.Fatal("expected the unhedged slow request to time out")
+	}
+	if got := client.HedgeStats().Hedges; got != 1 {
+		t.Fatalf("expected one hedge, got %d", got)
+	}
+}
+
+func TestHedging_FastResponsesAndOtherRenderTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"pageResponses":[{"statusCode":200}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"),
+		WithHedging(HedgeConfig{MinSamples: 5, RenderTypes: []string{"png"}}))
+	seedHedger(client, "png", 5, time.Second)
+
+	res, err := client.DoPage(&PageRequest{URL: "https://example.com", RenderType: "png"})
+	if err != nil {
+		t.Fatalf("DoPage: %v", err)
+	}
+	if res.Metadata.Hedged {
+		t.Fatal("fast response must not be hedged")
+	}
+	if _, ok := client.hedger.delay("html"); ok {
+		t.Fatal("html is not in RenderTypes and must not be hedged")
+	}
+	if client.HedgeStats().Hedges != 0 {
+		t.Fatalf("unexpected stats %+v", client.HedgeStats())
+	}
+}
+
+func TestHedging_ChargesCanceledLoserToLedger(t *testing.T) {
+	var hits int32
+	canceled := make(chan struct{}, 1)
+	server := slowFirstServer(t, &hits, canceled)
+
+	ledger := NewLedger()
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithLedger(ledger),
+		WithHedging(HedgeConfig{MinSamples: 5}))
+	seedHedger(client, "png", 5, 20*time.Millisecond)
+
+	if _, err := client.DoPage(&PageRequest{URL: "https://example.com", RenderType: "png"}); err != nil {
+		t.Fatalf("DoPage: %v", err)
+	}
+	<-canceled
+	deadline := time.Now().Add(2 * time.Second)
+	for ledger.Snapshot().Total.Credits < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	// The cancelled request adds credits but is not counted as a response.
+	snap := ledger.Snapshot()
+	if snap.Total.Requests != 1 || snap.Total.Pages != 1 || snap.Total.Credits != 2 {
+		t.Fatalf("expected both sent requests to be charged, got %+v", snap.Total)
+	}
+	if got := snap.ByHost["example.com"]; got.Requests != 1 || got.Credits != 2 {
+		t.Fatalf("unexpected host totals %+v", got)
+	}
+}
+
+func TestHedging_SkipsHalfOpenBreakerWithOneProbe(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"pageResponses":[{"statusCode":200}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"),
+		WithCircuitBreaker(BreakerConfig{Endpoint: BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenProbes: 1}}),
+		WithHedging(HedgeConfig{MinSamples: 5}))
+	now := time.Now()
+	client.breakers.now = func() time.Time { return now }
+	seedHedger(client, "html", 5, 10*time.Millisecond)
+
+	page := &PageRequest{URL: "https://example.com"}
+	if _, err := client.DoPage(page); err == nil {
+		t.Fatal("expected 500 error")
+	}
+	now = now.Add(time.Minute)
+
+	// The probe is slow, but its breaker has no slot for a hedge.
+	res, err := client.DoPage(page)
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if res.Metadata.Hedged || client.HedgeStats().Hedges != 0 || atomic.LoadInt32(&hits) != 2 {
+		t.Fatalf("expected no hedge, got %+v after %d requests", client.HedgeStats(), atomic.LoadInt32(&hits))
+	}
+	if client.BreakerStats().Endpoint != BreakerClosed {
+		t.Fatalf("expected the probe to close the breaker, got %v", client.BreakerStats().Endpoint)
+	}
+}
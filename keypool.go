@@ -0,0 +1,252 @@
+package phantomjscloud
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoAvailableKeys is returned when every key in a KeyPool is quarantined.
+var ErrNoAvailableKeys = errors.New("phantomjscloud: no API key available in pool")
+
+// DefaultKeyQuarantine is how long a key that ran out of credits is skipped.
+const DefaultKeyQuarantine = time.Hour
+
+// KeyStrategy selects which pooled key serves the next attempt.
+type KeyStrategy int
+
+const (
+	// KeyRoundRobin rotates through the available keys.
+	KeyRoundRobin KeyStrategy = iota
+	// KeyLeastSpent picks the available key with the lowest recorded spend.
+	KeyLeastSpent
+	// KeyPriority picks the available key with the lowest Priority value.
+	KeyPriority
+)
+
+// PoolKey is one API key in a KeyPool.
+type PoolKey struct {
+	Key string
+	// ID identifies the key in stats and ResponseMetadata.KeyID. It defaults to
+	// a masked form of Key so the key itself is never reported. An ID already
+	// used by an earlier key gets its pool index appended.
+	ID string
+	// Priority orders keys for KeyPriority; lower values are used first.
+	Priority int
+}
+
+// KeyStats reports usage for one pooled key.
+type KeyStats struct {
+	ID          string
+	Priority    int
+	Requests    int
+	Credits     float64
+	Quarantined bool
+	// QuarantinedUntil is zero for keys quarantined until Release, such as invalid keys.
+	QuarantinedUntil time.Time
+	LastError        string
+}
+
+// KeyPool spreads requests over several API keys. A key that answers with
+// ErrInvalidAPIKey is quarantined until Release; one that answers with
+// ErrOutOfCredits is quarantined for the quarantine duration. Either way the
+// attempt fails over to the next available key. KeyPool is safe for concurrent use.
+type KeyPool struct {
+	mu         sync.Mutex
+	strategy   KeyStrategy
+	keys       []*pooledKey
+	next       int
+	quarantine time.Duration
+	now        func() time.Time
+}
+
+type pooledKey struct {
+	PoolKey
+	requests    int
+	credits     float64
+	quarantined bool
+	until       time.Time
+	lastErr     string
+}
+
+// NewKeyPool creates a pool selecting keys with strategy. Keys with an empty Key are ignored.
+func NewKeyPool(strategy KeyStrategy, keys ...PoolKey) *KeyPool {
+	p := &KeyPool{strategy: strategy, quarantine: DefaultKeyQuarantine, now: time.Now}
+	for _, k := range keys {
+		if k.Key == "" {
+			continue
+		}
+		if k.ID == "" {
+			k.ID = maskKey(k.Key)
+		}
+		if k.ID == "" {
+			k.ID = fmt.Sprintf("key-%d", len(p.keys))
+		}
+		// Keys sharing their last characters, or given the same ID, must
+		// still be told apart in stats and by Release.
+		for p.hasIDLocked(k.ID) {
+			k.ID = fmt.Sprintf("%s-%d", k.ID, len(p.keys))
+		}
+		p.keys = append(p.keys, &pooledKey{PoolKey: k})
+	}
+	return p
+}
+
+// WithQuarantine sets how long a key that ran out of credits is skipped.
+// Values of zero or less use DefaultKeyQuarantine.
+func (p *KeyPool) WithQuarantine(d time.Duration) *KeyPool {
+	if d <= 0 {
+		d = DefaultKeyQuarantine
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.quarantine = d
+	return p
+}
+
+// WithKeyPool sends requests with keys from p instead of the client's own key,
+// and reports the key used in ResponseMetadata.KeyID.
+func WithKeyPool(p *KeyPool) ClientOption {
+	return func(c *Client) { c.keyPool = p }
+}
+
+// Release lifts the quarantine on the key with the given ID.
+// It reports whether the key was found.
+func (p *KeyPool) Release(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, k := range p.keys {
+		if k.ID == id {
+			k.quarantined = false
+			k.until = time.Time{}
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns per-key usage in pool order.
+func (p *KeyPool) Stats() []KeyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	out := make([]KeyStats, 0, len(p.keys))
+	for _, k := range p.keys {
+		out = append(out, KeyStats{
+			ID:               k.ID,
+			Priority:         k.Priority,
+			Requests:         k.requests,
+			Credits:          k.credits,
+			Quarantined:      !k.availableLocked(now),
+			QuarantinedUntil: k.until,
+			LastError:        k.lastErr,
+		})
+	}
+	return out
+}
+
+// pick returns the next available key according to the strategy, skipping
+// the keys in tried.
+func (p *KeyPool) pick(tried map[string]bool) (PoolKey, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+
+	var candidates []*pooledKey
+	for _, k := range p.keys {
+		if k.availableLocked(now) && !tried[k.Key] {
+			candidates = append(candidates, k)
+		}
+	}
+	if len(candidates) == 0 {
+		return PoolKey{}, false
+	}
+
+	var chosen *pooledKey
+	switch p.strategy {
+	case KeyLeastSpent:
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].credits < candidates[j].credits })
+		chosen = candidates[0]
+	case KeyPriority:
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Priority < candidates[j].Priority })
+		chosen = candidates[0]
+	default:
+		chosen = candidates[p.next%len(candidates)]
+		p.next++
+	}
+	return chosen.PoolKey, true
+}
+
+// record adds a successful response's cost to key.
+func (p *KeyPool) record(key string, credits float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if k := p.findLocked(key); k != nil {
+		k.requests++
+		k.credits += credits
+	}
+}
+
+// fail quarantines key when err shows it can no longer be used, and reports
+// whether the attempt should fail over to another key.
+func (p *KeyPool) fail(key string, err error) bool {
+	invalid := errors.Is(err, ErrInvalidAPIKey)
+	if !invalid && !errors.Is(err, ErrOutOfCredits) {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k := p.findLocked(key)
+	if k == nil {
+		return false
+	}
+	k.requests++
+	k.quarantined = true
+	k.lastErr = err.Error()
+	k.until = time.Time{}
+	if !invalid {
+		k.until = p.now().Add(p.quarantine)
+	}
+	return true
+}
+
+func (p *KeyPool) hasIDLocked(id string) bool {
+	for _, k := range p.keys {
+		if k.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *KeyPool) findLocked(key string) *pooledKey {
+	for _, k := range p.keys {
+		if k.Key == key {
+			return k
+		}
+	}
+	return nil
+}
+
+func (k *pooledKey) availableLocked(now time.Time) bool {
+	if !k.quarantined {
+		return true
+	}
+	if !k.until.IsZero() && !now.Before(k.until) {
+		k.quarantined = false
+		k.until = time.Time{}
+		return true
+	}
+	return false
+}
+
+// maskKey returns a short identifier for key that does not reveal it, or ""
+// when key is too short to show any of it safely.
+func maskKey(key string) string {
+	if len(key) <= 8 {
+		return ""
+	}
+	return "key-…" + key[len(key)-4:]
+}
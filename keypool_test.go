@@ -0,0 +1,213 @@
+package phantomjscloud
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// keyServer answers per API key: keys listed in status fail with that status,
+// all others succeed and bill cost credits via the response headers.
+type keyServer struct {
+	mu     sync.Mutex
+	status map[string]int
+	cost   string
+	seen   []string
+}
+
+func (s *keyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := strings.Trim(r.URL.Path, "/")
+	s.mu.Lock()
+	s.seen = append(s.seen, key)
+	status := s.status[key]
+	s.mu.Unlock()
+	if status != 0 {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"message":"rejected"}`))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Pjsc-Billing-Cost-Credits", s.cost)
+	_, _ = w.Write([]byte(`{"pageResponses":[{"statusCode":200}]}`))
+}
+
+func (s *keyServer) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.seen...)
+}
+
+func TestKeyPool_FailsOverAndQuarantines(t *testing.T) {
+	ks := &keyServer{status: map[string]int{"key-aaaaaaaa-1": 402, "key-bbbbbbbb-2": 401}, cost: "2"}
+	server := httptest.NewServer(ks)
+	defer server.Close()
+
+	pool := NewKeyPool(KeyPriority,
+		PoolKey{Key: "key-aaaaaaaa-1", ID: "first", Priority: 0},
+		PoolKey{Key: "key-bbbbbbbb-2", ID: "second", Priority: 1},
+		PoolKey{Key: "key-cccccccc-3", ID: "third", Priority: 2},
+	)
+	client := NewClient("", WithEndpoint(server.URL+"/"), WithKeyPool(pool))
+
+	res, err := client.DoPage(&PageRequest{URL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("DoPage: %v", err)
+	}
+	if res.Metadata.KeyID != "third" {
+		t.Fatalf("expected KeyID third, got %q", res.Metadata.KeyID)
+	}
+	if got := ks.keys(); len(got) != 3 {
+		t.Fatalf("expected three attempts, got %v", got)
+	}
+
+	// Quarantined keys are skipped on the next call.
+	if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); err != nil {
+		t.Fatalf("second DoPage: %v", err)
+	}
+	if got := ks.keys(); len(got) != 4 || got[3] != "key-cccccccc-3" {
+		t.Fatalf("expected quarantined keys to be skipped, got %v", got)
+	}
+
+	stats := pool.Stats()
+	if !stats[0].Quarantined || stats[0].QuarantinedUntil.IsZero() {
+		t.Fatalf("expected out-of-credits key to be quarantined with expiry, got %+v", stats[0])
+	}
+	if !stats[1].Quarantined || !stats[1].QuarantinedUntil.IsZero() {
+		t.Fatalf("expected invalid key to be quarantined until released, got %+v", stats[1])
+	}
+	if stats[2].Requests != 2 || stats[2].Credits != 4 {
+		t.Fatalf("expected spend 4 over 2 requests on third key, got %+v", stats[2])
+	}
+
+	if !pool.Release("second") || pool.Stats()[1].Quarantined {
+		t.Fatal("expected Release to lift quarantine")
+	}
+}
+
+func TestKeyPool_QuarantineExpires(t *testing.T) {
+	now := time.Now()
+	pool := NewKeyPool(KeyPriority, PoolKey{Key: "aaaaaaaaaa"}, PoolKey{Key: "bbbbbbbbbb"}).WithQuarantine(time.Minute)
+	pool.now = func() time.Time { return now }
+
+	pool.fail("aaaaaaaaaa", &APIError{StatusCode: 402})
+	if k, _ := pool.pick(nil); k.Key != "bbbbbbbbbb" {
+		t.Fatalf("expected quarantined key to be skipped, got %q", k.Key)
+	}
+	now = now.Add(time.Minute)
+	if k, _ := pool.pick(nil); k.Key != "aaaaaaaaaa" {
+		t.Fatalf("expected key back after quarantine, got %q", k.Key)
+	}
+}
+
+func TestKeyPool_AllQuarantined(t *testing.T) {
+	ks := &keyServer{status: map[string]int{"aaaaaaaaaa": 402, "bbbbbbbbbb": 402}}
+	server := httptest.NewServer(ks)
+	defer server.Close()
+
+	client := NewClient("", WithEndpoint(server.URL+"/"),
+		WithKeyPool(NewKeyPool(KeyRoundRobin, PoolKey{Key: "aaaaaaaaaa"}, PoolKey{Key: "bbbbbbbbbb"})))
+
+	_, err := client.DoPage(&PageRequest{URL: "https://example.com"})
+	if !errors.Is(err, ErrNoAvailableKeys) || !errors.Is(err, ErrOutOfCredits) {
+		t.Fatalf("expected ErrNoAvailableKeys wrapping ErrOutOfCredits, got %v", err)
+	}
+	if strings.Contains(err.Error(), "aaaaaaaaaa") || strings.Contains(err.Error(), "bbbbbbbbbb") {
+		t.Fatalf("error leaks API key: %v", err)
+	}
+}
+
+func TestKeyPool_ZeroQuarantineTriesEachKeyOnce(t *testing.T) {
+	ks := &keyServer{status: map[string]int{"aaaaaaaaaa": 402, "bbbbbbbbbb": 402}}
+	server := httptest.NewServer(ks)
+	defer server.Close()
+
+	pool := NewKeyPool(KeyRoundRobin, PoolKey{Key: "aaaaaaaaaa"}, PoolKey{Key: "bbbbbbbbbb"}).WithQuarantine(0)
+	client := NewClient("", WithEndpoint(server.URL+"/"), WithKeyPool(pool))
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.DoPage(&PageRequest{URL: "https://example.com"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrNoAvailableKeys) || !errors.Is(err, ErrOutOfCredits) {
+			t.Fatalf("expected ErrNoAvailableKeys wrapping ErrOutOfCredits, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("key failover did not stop")
+	}
+	if got := ks.keys(); len(got) != 2 {
+		t.Fatalf("expected each key to be tried once, got %v", got)
+	}
+	if stats := pool.Stats(); !stats[0].Quarantined || stats[0].QuarantinedUntil.Sub(time.Now()) < DefaultKeyQuarantine-time.Minute {
+		t.Fatalf("expected WithQuarantine(0) to use the default quarantine, got %+v", stats[0])
+	}
+}
+
+func TestKeyPool_Strategies(t *testing.T) {
+	keys := []PoolKey{{Key: "aaaaaaaaaa", Priority: 2}, {Key: "bbbbbbbbbb", Priority: 1}, {Key: "cccccccccc", Priority: 3}}
+
+	rr := NewKeyPool(KeyRoundRobin, keys...)
+	var order []string
+	for i := 0; i < 4; i++ {
+		k, _ := rr.pick(nil)
+		order = append(order, k.Key[:1])
+	}
+	if got := strings.Join(order, ""); got != "abca" {
+		t.Fatalf("round-robin order = %q", got)
+	}
+
+	prio := NewKeyPool(KeyPriority, keys...)
+	if k, _ := prio.pick(nil); k.Key != "bbbbbbbbbb" {
+		t.Fatalf("priority picked %q", k.Key)
+	}
+
+	least := NewKeyPool(KeyLeastSpent, keys...)
+	least.record("aaaaaaaaaa", 5)
+	least.record("bbbbbbbbbb", 1)
+	least.record("cccccccccc", 3)
+	if k, _ := least.pick(nil); k.Key != "bbbbbbbbbb" {
+		t.Fatalf("least-spent picked %q", k.Key)
+	}
+}
+
+func TestKeyPool_DefaultIDsMaskKey(t *testing.T) {
+	pool := NewKeyPool(KeyRoundRobin, PoolKey{Key: "ak-super-secret-123"}, PoolKey{Key: "short"})
+	stats := pool.Stats()
+	if stats[0].ID != "key-…-123" {
+		t.Fatalf("expected masked ID, got %q", stats[0].ID)
+	}
+	if stats[1].ID != "key-1" {
+		t.Fatalf("expected positional ID for short key, got %q", stats[1].ID)
+	}
+
+	pool = NewKeyPool(KeyRoundRobin, PoolKey{Key: "first-secret-1234"}, PoolKey{Key: "other-secret-1234"}, PoolKey{Key: "third-secret", ID: "key-…1234"})
+	var ids []string
+	for _, st := range pool.Stats() {
+		ids = append(ids, st.ID)
+	}
+	if got := strings.Join(ids, ","); got != "key-…1234,key-…1234-1,key-…1234-2" {
+		t.Fatalf("expected unique IDs, got %s", got)
+	}
+	if !pool.Release("key-…1234-1") {
+		t.Fatal("expected Release to find the renamed key")
+	}
+}
+
+func TestDoContext_ReportsSingleKeyID(t *testing.T) {
+	server := httptest.NewServer(&keyServer{cost: "1"})
+	defer server.Close()
+
+	res, err := NewClient(secretKey, WithEndpoint(server.URL+"/")).DoPage(&PageRequest{URL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("DoPage: %v", err)
+	}
+	if res.Metadata.KeyID != maskKey(secretKey) || strings.Contains(res.Metadata.KeyID, secretKey) {
+		t.Fatalf("unexpected KeyID %q", res.Metadata.KeyID)
+	}
+}
@@ -0,0 +1,366 @@
+package phantomjscloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/amafjarkasi/go-phantomjs/ext/proxy"
+)
+
+// ErrBudgetExceeded is returned by DoContext when a Ledger budget has been crossed.
+var ErrBudgetExceeded = errors.New("phantomjscloud: credit budget exceeded")
+
+// BudgetScope selects which ledger dimension a Budget applies to.
+type BudgetScope string
+
+const (
+	BudgetTotal      BudgetScope = "total"
+	BudgetHost       BudgetScope = "host"
+	BudgetRenderType BudgetScope = "renderType"
+	BudgetTag        BudgetScope = "tag"
+)
+
+// Budget caps credit spend for one ledger key.
+//
+// Once Soft is reached, DoContext refuses new work unless the context was
+// marked with WithBudgetOverride. Once Hard is reached, DoContext refuses all
+// new work. A zero limit is disabled. Limits are checked before each request,
+// so requests already in flight may overshoot them.
+type Budget struct {
+	Scope BudgetScope `json:"scope"`
+	// Key is the host, render type or tag the budget applies to. Ignored for BudgetTotal.
+	Key  string  `json:"key,omitempty"`
+	Soft float64 `json:"soft,omitempty"`
+	Hard float64 `json:"hard,omitempty"`
+}
+
+// LedgerTotals is the aggregated spend for one ledger key.
+type LedgerTotals struct {
+	Requests int     `json:"requests"`
+	Pages    int     `json:"pages"`
+	Credits  float64 `json:"credits"`
+}
+
+// BudgetStatus reports a budget alongside its current spend.
+type BudgetStatus struct {
+	Budget
+	Spent        float64 `json:"spent"`
+	SoftExceeded bool    `json:"softExceeded"`
+	HardExceeded bool    `json:"hardExceeded"`
+}
+
+// LedgerSnapshot is a point-in-time export of a Ledger, suitable for invoice reconciliation.
+type LedgerSnapshot struct {
+	GeneratedAt  time.Time               `json:"generatedAt"`
+	Total        LedgerTotals            `json:"total"`
+	ByHost       map[string]LedgerTotals `json:"byHost"`
+	ByRenderType map[string]LedgerTotals `json:"byRenderType"`
+	ByTag        map[string]LedgerTotals `json:"byTag"`
+	Budgets      []BudgetStatus          `json:"budgets,omitempty"`
+}
+
+// Ledger aggregates the credits spent by a Client, keyed by target host,
+// render type and caller tags attached with WithLedgerTags. Attach it with
+// WithLedger. Ledger is safe for concurrent use.
+type Ledger struct {
+	mu           sync.Mutex
+	total        LedgerTotals
+	byHost       map[string]*LedgerTotals
+	byRenderType map[string]*LedgerTotals
+	byTag        map[string]*LedgerTotals
+	budgets      []Budget
+	now          func() time.Time
+}
+
+// NewLedger creates an empty ledger.
+func NewLedger() *Ledger {
+	return &Ledger{
+		byHost:       make(map[string]*LedgerTotals),
+		byRenderType: make(map[string]*LedgerTotals),
+		byTag:        make(map[string]*LedgerTotals),
+		now:          time.Now,
+	}
+}
+
+// WithLedger records the credit cost of every response into l and enforces its budgets.
+func WithLedger(l *Ledger) ClientOption {
+	return func(c *Client) { c.ledger = l }
+}
+
+// SetBudget adds or replaces the budget for b.Scope and b.Key.
+func (l *Ledger) SetBudget(b Budget) *Ledger {
+	switch b.Scope {
+	case BudgetTotal:
+		b.Key = ""
+	case BudgetHost:
+		b.Key = proxy.ExtractHost(b.Key)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.budgets {
+		if l.budgets[i].Scope == b.Scope && l.budgets[i].Key == b.Key {
+			l.budgets[i] = b
+			return l
+		}
+	}
+	l.budgets = append(l.budgets, b)
+	return l
+}
+
+// Record adds the cost of resp for req to the ledger. The client calls it
+// after every response; call it directly only for responses obtained elsewhere.
+// A multi-page request's cost is split evenly across its pages.
+func (l *Ledger) Record(ctx context.Context, req *UserRequest, resp *UserResponseWithMeta) {
+	if req == nil || resp == nil {
+		return
+	}
+	l.add(ctx, req, creditCost(resp), true)
+}
+
+// RecordCredits adds credits spent on req without counting a request or its
+// pages, e.g. the estimated cost of a request that was cancelled in flight.
+func (l *Ledger) RecordCredits(ctx context.Context, req *UserRequest, credits float64) {
+	if req == nil || credits <= 0 {
+		return
+	}
+	l.add(ctx, req, credits, false)
+}
+
+// add records cost for req. counted is false for spend that is not a
+// response of its own, so request and page counts stay as they are.
+func (l *Ledger) add(ctx context.Context, req *UserRequest, cost float64, counted bool) {
+	n := boolToInt(counted)
+	pages := len(req.Pages)
+	tags := LedgerTags(ctx)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.total.Requests += n
+	l.total.Pages += n * pages
+	l.total.Credits += cost
+
+	for _, tag := range tags {
+		addTotals(l.byTag, tag, n, n*pages, cost)
+	}
+	if pages == 0 {
+		return
+	}
+	perPage := cost / float64(pages)
+	seenHost := make(map[string]bool, pages)
+	seenRender := make(map[string]bool, pages)
+	for _, p := range req.Pages {
+		host := proxy.ExtractHost(p.URL)
+		rt := ledgerRenderType(p.RenderType)
+		addTotals(l.byHost, host, n*boolToInt(!seenHost[host]), n, perPage)
+		addTotals(l.byRenderType, rt, n*boolToInt(!seenRender[rt]), n, perPage)
+		seenHost[host] = true
+		seenRender[rt] = true
+	}
+}
+
+// Check returns an error wrapping ErrBudgetExceeded if req may not be sent
+// under the ledger's budgets.
+func (l *Ledger) Check(ctx context.Context, req *UserRequest) error {
+	override := budgetOverride(ctx)
+	tags := LedgerTags(ctx)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, b := range l.budgets {
+		for _, key := range l.keysFor(b.Scope, req, tags) {
+			if key != b.Key {
+				continue
+			}
+			spent := l.spentLocked(b.Scope, key)
+			if b.Hard > 0 && spent >= b.Hard {
+				return fmt.Errorf("%w: hard limit for %s %q: spent %.4g of %.4g credits", ErrBudgetExceeded, b.Scope, b.Key, spent, b.Hard)
+			}
+			if !override && b.Soft > 0 && spent >= b.Soft {
+				return fmt.Errorf("%w: soft limit for %s %q: spent %.4g of %.4g credits", ErrBudgetExceeded, b.Scope, b.Key, spent, b.Soft)
+			}
+		}
+	}
+	return nil
+}
+
+// Snapshot returns a copy of the ledger's current totals and budget status.
+func (l *Ledger) Snapshot() LedgerSnapshot {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	snap := LedgerSnapshot{
+		GeneratedAt:  l.now().UTC(),
+		Total:        l.total,
+		ByHost:       copyTotals(l.byHost),
+		ByRenderType: copyTotals(l.byRenderType),
+		ByTag:        copyTotals(l.byTag),
+	}
+	for _, b := range l.budgets {
+		spent := l.spentLocked(b.Scope, b.Key)
+		snap.Budgets = append(snap.Budgets, BudgetStatus{
+			Budget:       b,
+			Spent:        spent,
+			SoftExceeded: b.Soft > 0 && spent >= b.Soft,
+			HardExceeded: b.Hard > 0 && spent >= b.Hard,
+		})
+	}
+	sort.SliceStable(snap.Budgets, func(i, j int) bool {
+		if snap.Budgets[i].Scope != snap.Budgets[j].Scope {
+			return snap.Budgets[i].Scope < snap.Budgets[j].Scope
+		}
+		return snap.Budgets[i].Key < snap.Budgets[j].Key
+	})
+	return snap
+}
+
+// WriteJSON writes the current snapshot to w as indented JSON.
+func (l *Ledger) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l.Snapshot())
+}
+
+// Reset clears all recorded spend. Budgets are kept.
+func (l *Ledger) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total = LedgerTotals{}
+	l.byHost = make(map[string]*LedgerTotals)
+	l.byRenderType = make(map[string]*LedgerTotals)
+	l.byTag = make(map[string]*LedgerTotals)
+}
+
+func (l *Ledger) keysFor(scope BudgetScope, req *UserRequest, tags []string) []string {
+	switch scope {
+	case BudgetTotal:
+		return []string{""}
+	case BudgetTag:
+		return tags
+	case BudgetHost, BudgetRenderType:
+		if req == nil {
+			return nil
+		}
+		keys := make([]string, 0, len(req.Pages))
+		for _, p := range req.Pages {
+			if scope == BudgetHost {
+				keys = append(keys, proxy.ExtractHost(p.URL))
+			} else {
+				keys = append(keys, ledgerRenderType(p.RenderType))
+			}
+		}
+		return keys
+	}
+	return nil
+}
+
+func (l *Ledger) spentLocked(scope BudgetScope, key string) float64 {
+	var m map[string]*LedgerTotals
+	switch scope {
+	case BudgetTotal:
+		return l.total.Credits
+	case BudgetHost:
+		m = l.byHost
+	case BudgetRenderType:
+		m = l.byRenderType
+	case BudgetTag:
+		m = l.byTag
+	}
+	if t, ok := m[key]; ok {
+		return t.Credits
+	}
+	return 0
+}
+
+// creditCost picks the billed cost of a response: the JSON billing block when
+// present, otherwise the pjsc billing headers.
+func creditCost(resp *UserResponseWithMeta) float64 {
+	switch {
+	case resp.Billing.CreditCost != 0:
+		return resp.Billing.CreditCost
+	case resp.Metadata.BillingCreditCost != 0:
+		return resp.Metadata.BillingCreditCost
+	default:
+		return resp.Metadata.BillingCostCredits
+	}
+}
+
+func addTotals(m map[string]*LedgerTotals, key string, requests, pages int, credits float64) {
+	t, ok := m[key]
+	if !ok {
+		t = &LedgerTotals{}
+		m[key] = t
+	}
+	t.Requests += requests
+	t.Pages += pages
+	t.Credits += credits
+}
+
+func copyTotals(m map[string]*LedgerTotals) map[string]LedgerTotals {
+	out := make(map[string]LedgerTotals, len(m))
+	for k, v := range m {
+		out[k] = *v
+	}
+	return out
+}
+
+func ledgerRenderType(rt string) string {
+	if rt == "" {
+		return "html"
+	}
+	return rt
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type ledgerTagsKey struct{}
+type budgetOverrideKey struct{}
+
+// WithLedgerTags returns a context whose requests are attributed to tags in
+// the client's Ledger, in addition to any tags already on ctx. A tag given
+// more than once is counted once.
+func WithLedgerTags(ctx context.Context, tags ...string) context.Context {
+	all := append([]string(nil), LedgerTags(ctx)...)
+	for _, tag := range tags {
+		if !slices.Contains(all, tag) {
+			all = append(all, tag)
+		}
+	}
+	return context.WithValue(ctx, ledgerTagsKey{}, all)
+}
+
+// LedgerTags returns the ledger tags attached to ctx.
+func LedgerTags(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	tags, _ := ctx.Value(ledgerTagsKey{}).([]string)
+	return tags
+}
+
+// WithBudgetOverride returns a context whose requests may exceed soft budgets.
+// Hard budgets still apply.
+func WithBudgetOverride(ctx context.Context) context.Context {
+	return context.WithValue(ctx, budgetOverrideKey{}, true)
+}
+
+func budgetOverride(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	v, _ := ctx.Value(budgetOverrideKey{}).(bool)
+	return v
+}
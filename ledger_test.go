@@ -0,0 +1,135 @@
+package phantomjscloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func newBillingServer(t *testing.T, cost string) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("pjsc-billing-credit-cost", cost)
+		w.Write([]byte(`{"status":"success","pageResponses":[{"statusCode":200},{"statusCode":200}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestLedger_RecordsByHostRenderTypeAndTag(t *testing.T) {
+	server, _ := newBillingServer(t, "2")
+	ledger := NewLedger()
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithLedger(ledger))
+
+	// Repeated tags are counted once.
+	ctx := WithLedgerTags(WithLedgerTags(context.Background(), "team-a", "nightly", "team-a"), "nightly")
+	_, err := client.DoContext(ctx, &UserRequest{Pages: []PageRequest{
+		{URL: "https://www.example.com/1"},
+		{URL: "https://shop.test/2", RenderType: "png"},
+	}})
+	if err != nil {
+		t.Fatalf("DoContext failed: %v", err)
+	}
+
+	snap := ledger.Snapshot()
+	if snap.Total.Credits != 2 || snap.Total.Requests != 1 || snap.Total.Pages != 2 {
+		t.Fatalf("unexpected totals: %+v", snap.Total)
+	}
+	if got := snap.ByHost["example.com"].Credits; got != 1 {
+		t.Fatalf("expected 1 credit for example.com, got %v", got)
+	}
+	if got := snap.ByRenderType["png"].Credits; got != 1 {
+		t.Fatalf("expected 1 credit for png, got %v", got)
+	}
+	if got := snap.ByRenderType["html"].Pages; got != 1 {
+		t.Fatalf("expected 1 html page, got %v", got)
+	}
+	if got := snap.ByTag["team-a"].Credits; got != 2 {
+		t.Fatalf("expected 2 credits for tag team-a, got %v", got)
+	}
+	if got := snap.ByTag["nightly"].Requests; got != 1 {
+		t.Fatalf("expected 1 request for tag nightly, got %v", got)
+	}
+}
+
+func TestLedger_HardBudgetRefusesWork(t *testing.T) {
+	server, calls := newBillingServer(t, "3")
+	ledger := NewLedger().SetBudget(Budget{Scope: BudgetHost, Key: "www.example.com", Hard: 5})
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithLedger(ledger))
+	req := &PageRequest{URL: "https://example.com"}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.DoPage(req); err != nil {
+			t.Fatalf("call %d failed: %v", i+1, err)
+		}
+	}
+	_, err := client.DoPageContext(WithBudgetOverride(context.Background()), req)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Fatalf("expected refused call not to reach the API, got %d calls", got)
+	}
+
+	// Other hosts are unaffected.
+	if _, err := client.DoPage(&PageRequest{URL: "https://other.test"}); err != nil {
+		t.Fatalf("expected other host to be allowed, got %v", err)
+	}
+}
+
+func TestLedger_SoftBudgetCanBeOverridden(t *testing.T) {
+	server, _ := newBillingServer(t, "1")
+	ledger := NewLedger().SetBudget(Budget{Scope: BudgetTag, Key: "team-a", Soft: 1})
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithLedger(ledger))
+	ctx := WithLedgerTags(context.Background(), "team-a")
+	req := &PageRequest{URL: "https://example.com"}
+
+	if _, err := client.DoPageContext(ctx, req); err != nil {
+		t.Fatalf("first call failed: %v", err)
+	}
+	if _, err := client.DoPageContext(ctx, req); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected soft budget refusal, got %v", err)
+	}
+	if _, err := client.DoPageContext(WithBudgetOverride(ctx), req); err != nil {
+		t.Fatalf("expected override to bypass soft budget, got %v", err)
+	}
+	if _, err := client.DoPage(req); err != nil {
+		t.Fatalf("expected untagged request to be allowed, got %v", err)
+	}
+}
+
+func TestLedger_WriteJSON(t *testing.T) {
+	ledger := NewLedger().SetBudget(Budget{Scope: BudgetTotal, Soft: 1, Hard: 2})
+	ledger.Record(context.Background(),
+		&UserRequest{Pages: []PageRequest{{URL: "https://example.com"}}},
+		&UserResponseWithMeta{UserResponse: UserResponse{Billing: Billing{CreditCost: 1.5}}},
+	)
+
+	var buf bytes.Buffer
+	if err := ledger.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var snap LedgerSnapshot
+	if err := json.Unmarshal(buf.Bytes(), &snap); err != nil {
+		t.Fatalf("invalid JSON export: %v", err)
+	}
+	if snap.Total.Credits != 1.5 {
+		t.Fatalf("unexpected exported total: %+v", snap.Total)
+	}
+	if len(snap.Budgets) != 1 || !snap.Budgets[0].SoftExceeded || snap.Budgets[0].HardExceeded {
+		t.Fatalf("unexpected budget status: %+v", snap.Budgets)
+	}
+
+	ledger.Reset()
+	if got := ledger.Snapshot().Total.Credits; got != 0 {
+		t.Fatalf("expected reset ledger, got %v credits", got)
+	}
+}
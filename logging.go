@@ -0,0 +1,292 @@
+package phantomjscloud
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// redacted replaces the API key wherever it would otherwise be printed.
+const redacted = "REDACTED"
+
+// WithLogger logs every request, attempt, retry, cache hit and response to l
+// with structured attributes: page URLs, render type, status, credits and
+// duration. The API key is never logged.
+//
+// Requests and responses are logged at Debug and Info, retries at Warn and
+// failed calls at Error.
+func WithLogger(l *slog.Logger) ClientOption {
+	return func(c *Client) {
+		if l == nil {
+			return
+		}
+		WithObserver(&logObserver{logger: l})(c)
+	}
+}
+
+// WithAPIKeyHeader sends the API key in the named request header instead of
+// the URL path, so it never appears in proxy logs or URL errors. PhantomJsCloud
+// documents the key as a path segment; only use this with an endpoint that
+// accepts header authentication, such as a gateway that maps the header onto
+// the path.
+func WithAPIKeyHeader(name string) ClientOption {
+	return func(c *Client) { c.apiKeyHeader = name }
+}
+
+// String describes the client without revealing its API key.
+func (c *Client) String() string {
+	return "phantomjscloud.Client{endpoint: " + c.endpoint + ", apiKey: " + redacted + "}"
+}
+
+// GoString is like String, so %#v does not reveal the API key either.
+func (c *Client) GoString() string { return c.String() }
+
+// redactError returns err with every occurrence of key replaced. Errors in
+// the chain are exposed to errors.As only in redacted form: *url.Error and
+// *APIError values are copied with the key replaced in their fields.
+func redactError(err error, key string) error {
+	if err == nil || key == "" || !chainContainsKey(err, key) {
+		return err
+	}
+	return &redactedError{err: err, key: key}
+}
+
+// chainContainsKey reports whether key appears in err's message or in the
+// fields of an *APIError or *url.Error anywhere in its chain.
+func chainContainsKey(err error, key string) bool {
+	if strings.Contains(err.Error(), key) {
+		return true
+	}
+	var ae *APIError
+	if errors.As(err, &ae) && strings.Contains(ae.Code+ae.Message+ae.Body, key) {
+		return true
+	}
+	var ue *url.Error
+	return errors.As(err, &ue) && strings.Contains(ue.URL, key)
+}
+
+// redactedError hides the API key in one layer of an error chain. Unwrap
+// returns the next layers wrapped the same way, so errors.Is and errors.As
+// still walk the whole chain without reaching an unredacted value.
+type redactedError struct {
+	err error
+	key string
+}
+
+func (e *redactedError) Error() string {
+	return strings.ReplaceAll(e.err.Error(), e.key, redacted)
+}
+
+func (e *redactedError) Unwrap() []error {
+	var next []error
+	switch u := e.err.(type) {
+	case interface{ Unwrap() error }:
+		if err := u.Unwrap(); err != nil {
+			next = []error{err}
+		}
+	case interface{ Unwrap() []error }:
+		next = u.Unwrap()
+	}
+	out := make([]error, 0, len(next))
+	for _, err := range next {
+		if err != nil {
+			out = append(out, &redactedError{err: err, key: e.key})
+		}
+	}
+	return out
+}
+
+// Is matches the wrapped layer itself, which the Unwrap chain skips.
+func (e *redactedError) Is(target error) bool {
+	if x, ok := e.err.(interface{ Is(error) bool }); ok && x.Is(target) {
+		return true
+	}
+	return reflect.TypeOf(e.err).Comparable() && e.err == target
+}
+
+// As matches the wrapped layer itself, in its redacted form.
+func (e *redactedError) As(target any) bool {
+	layer := e.layer()
+	if x, ok := layer.(interface{ As(any) bool }); ok && x.As(target) {
+		return true
+	}
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return false
+	}
+	if reflect.TypeOf(layer).AssignableTo(v.Type().Elem()) {
+		v.Elem().Set(reflect.ValueOf(layer))
+		return true
+	}
+	return false
+}
+
+// layer returns the wrapped error, copying the types that carry the key with
+// it replaced.
+func (e *redactedError) layer() error {
+	switch err := e.err.(type) {
+	case *url.Error:
+		c := *err
+		c.URL = strings.ReplaceAll(c.URL, e.key, redacted)
+		if c.Err != nil && strings.Contains(c.Err.Error(), e.key) {
+			c.Err = &redactedError{err: c.Err, key: e.key}
+		}
+		return &c
+	case *APIError:
+		c := *err
+		c.Code = strings.ReplaceAll(c.Code, e.key, redacted)
+		c.Message = strings.ReplaceAll(c.Message, e.key, redacted)
+		c.Body = strings.ReplaceAll(c.Body, e.key, redacted)
+		return &c
+	}
+	return e.err
+}
+
+// logObserver adapts Observer events to structured slog records.
+type logObserver struct {
+	logger *slog.Logger
+}
+
+func (o *logObserver) OnRequestStart(ctx context.Context, e RequestStartEvent) {
+	o.logger.LogAttrs(ctx, slog.LevelDebug, "phantomjscloud request started", requestAttrs(e.Request)...)
+}
+
+func (o *logObserver) OnRequestEnd(ctx context.Context, e RequestEndEvent) {
+	attrs := append(requestAttrs(e.Request), slog.Duration("duration", e.Duration))
+	if e.Err != nil {
+		attrs = append(attrs, errorAttrs(e.Err)...)
+		o.logger.LogAttrs(ctx, slog.LevelError, "phantomjscloud request failed", attrs...)
+		return
+	}
+	if e.Response != nil {
+		attrs = append(attrs,
+			slog.Bool("cached", e.Response.Metadata.Cached),
+			slog.Bool("coalesced", e.Response.Metadata.Coalesced),
+		)
+	}
+	o.logger.LogAttrs(ctx, slog.LevelInfo, "phantomjscloud request finished", attrs...)
+}
+
+func (o *logObserver) OnAttemptStart(ctx context.Context, e AttemptStartEvent) {
+	attrs := append(requestAttrs(e.Request), slog.Int("attempt", e.Attempt))
+	o.logger.LogAttrs(ctx, slog.LevelDebug, "phantomjscloud attempt started", attrs...)
+}
+
+func (o *logObserver) OnAttemptEnd(ctx context.Context, e AttemptEndEvent) {
+	attrs := append(requestAttrs(e.Request),
+		slog.Int("attempt", e.Attempt),
+		slog.Duration("duration", e.Duration),
+	)
+	if e.Err != nil {
+		attrs = append(attrs, errorAttrs(e.Err)...)
+	}
+	o.logger.LogAttrs(ctx, slog.LevelDebug, "phantomjscloud attempt finished", attrs...)
+}
+
+func (o *logObserver) OnRetryScheduled(ctx context.Context, e RetryScheduledEvent) {
+	attrs := append(requestAttrs(e.Request),
+		slog.Int("attempt", e.Attempt),
+		slog.String("class", string(e.Class)),
+		slog.Duration("delay", e.Delay),
+	)
+	attrs = append(attrs, errorAttrs(e.Err)...)
+	o.logger.LogAttrs(ctx, slog.LevelWarn, "phantomjscloud retry scheduled", attrs...)
+}
+
+func (o *logObserver) OnResponse(ctx context.Context, e ResponseEvent) {
+	attrs := append(requestAttrs(e.Request),
+		slog.Int("attempt", e.Attempt),
+		slog.Float64("credits", e.Credits),
+		slog.Bool("blocked", e.Blocked),
+	)
+	if e.Response != nil {
+		statuses := make([]int, len(e.Response.PageResponses))
+		for i, p := range e.Response.PageResponses {
+			statuses[i] = p.StatusCode
+		}
+		attrs = append(attrs, slog.Any("status", statuses))
+		if e.Response.Metadata.Status != "" {
+			attrs = append(attrs, slog.String("responseStatus", e.Response.Metadata.Status))
+		}
+	}
+	o.logger.LogAttrs(ctx, slog.LevelInfo, "phantomjscloud response", attrs...)
+}
+
+func (o *logObserver) OnCacheHit(ctx context.Context, e CacheHitEvent) {
+	attrs := append(requestAttrs(e.Request), slog.Time("storedAt", e.StoredAt))
+	o.logger.LogAttrs(ctx, slog.LevelDebug, "phantomjscloud cache hit", attrs...)
+}
+
+func (o *logObserver) OnOrchestrationAttempt(ctx context.Context, e OrchestrationAttemptEvent) {
+	attrs := []slog.Attr{
+		slog.String("orchestrator", e.Orchestrator),
+		slog.Int("attempt", e.Attempt),
+		slog.String("url", e.URL),
+		slog.String("level", e.Level),
+		slog.String("proxy", ProxyLabel(e.Proxy)),
+		slog.Bool("blocked", e.Blocked),
+	}
+	if e.Persona != "" {
+		attrs = append(attrs, slog.String("persona", e.Persona))
+	}
+	if e.Err != nil {
+		attrs = append(attrs, errorAttrs(e.Err)...)
+	}
+	o.logger.LogAttrs(ctx, slog.LevelInfo, "phantomjscloud orchestration attempt", attrs...)
+}
+
+func (o *logObserver) OnBatch(ctx context.Context, e BatchEvent) {
+	attrs := []slog.Attr{
+		slog.Int("pages", e.Pages),
+		slog.Int("failed", e.Failed),
+		slog.Duration("duration", e.Duration),
+	}
+	level := slog.LevelInfo
+	if e.Err != nil {
+		level = slog.LevelError
+		attrs = append(attrs, errorAttrs(e.Err)...)
+	}
+	o.logger.LogAttrs(ctx, level, "phantomjscloud batch", attrs...)
+}
+
+// requestAttrs describes the pages of req: their URLs and render types.
+func requestAttrs(req *UserRequest) []slog.Attr {
+	if req == nil {
+		return nil
+	}
+	if len(req.Pages) == 1 {
+		p := req.Pages[0]
+		return []slog.Attr{
+			slog.String("url", p.URL),
+			slog.String("renderType", ledgerRenderType(p.RenderType)),
+		}
+	}
+	urls := make([]string, len(req.Pages))
+	renderTypes := make([]string, len(req.Pages))
+	for i, p := range req.Pages {
+		urls[i] = p.URL
+		renderTypes[i] = ledgerRenderType(p.RenderType)
+	}
+	return []slog.Attr{
+		slog.Any("urls", urls),
+		slog.Any("renderTypes", renderTypes),
+	}
+}
+
+func errorAttrs(err error) []slog.Attr {
+	if err == nil {
+		return nil
+	}
+	attrs := []slog.Attr{slog.String("error", err.Error())}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		attrs = append(attrs, slog.Int("status", apiErr.StatusCode))
+		if apiErr.Code != "" {
+			attrs = append(attrs, slog.String("code", apiErr.Code))
+		}
+	}
+	return attrs
+}
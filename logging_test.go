@@ -0,0 +1,145 @@
+package phantomjscloud
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const secretKey = "ak-super-secret-123"
+
+func TestDoContext_RedactsAPIKeyFromTransportErrors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	endpoint := server.URL + "/"
+	server.Close() // connections are now refused
+
+	var buf bytes.Buffer
+	client := NewClient(secretKey,
+		WithEndpoint(endpoint),
+		WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	_, err := client.DoPage(&PageRequest{URL: "https://example.com"})
+	if err == nil {
+		t.Fatal("expected transport error")
+	}
+	if strings.Contains(err.Error(), secretKey) {
+		t.Fatalf("error leaks API key: %v", err)
+	}
+	if !strings.Contains(err.Error(), redacted) {
+		t.Fatalf("expected redaction marker in %q", err.Error())
+	}
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || strings.Contains(urlErr.URL, secretKey) {
+		t.Fatalf("expected redacted *url.Error in chain, got %#v", urlErr)
+	}
+	if classifyError(err) != RetryClassNetwork {
+		t.Fatalf("expected redacted error to still classify as network, got %q", classifyError(err))
+	}
+	if strings.Contains(buf.String(), secretKey) {
+		t.Fatalf("logs leak API key:\n%s", buf.String())
+	}
+}
+
+func TestDoContext_RedactsAPIKeyFromAPIErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusPaymentRequired)
+		fmt.Fprintf(w, `{"name":"OutOfCredits","message":"key %s has no credits left","url":"%s"}`, secretKey, r.URL.Path)
+	}))
+	defer server.Close()
+
+	client := NewClient(secretKey, WithEndpoint(server.URL+"/"))
+	_, err := client.DoPage(&PageRequest{URL: "https://example.com"})
+	if err == nil {
+		t.Fatal("expected API error")
+	}
+	if strings.Contains(err.Error(), secretKey) {
+		t.Fatalf("error leaks API key: %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError in chain, got %v", err)
+	}
+	if strings.Contains(fmt.Sprintf("%+v", *apiErr), secretKey) {
+		t.Fatalf("errors.As exposes API key: %+v", *apiErr)
+	}
+	if !errors.Is(err, ErrOutOfCredits) || apiErr.StatusCode != http.StatusPaymentRequired {
+		t.Fatalf("expected redacted error to keep its identity, got %v", err)
+	}
+}
+
+func TestWithLogger_LogsStructuredResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","billing":{"creditCost":1.5},"pageResponses":[{"statusCode":201}]}`))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := NewClient(secretKey,
+		WithEndpoint(server.URL+"/"),
+		WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+	)
+	if _, err := client.DoPage(&PageRequest{URL: "https://example.com/a", RenderType: "png"}); err != nil {
+		t.Fatalf("DoPage failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		`"msg":"phantomjscloud response"`,
+		`"url":"https://example.com/a"`,
+		`"renderType":"png"`,
+		`"credits":1.5`,
+		`"status":[201]`,
+		`"msg":"phantomjscloud request finished"`,
+		`"duration":`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in logs:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "attempt started") {
+		t.Error("expected debug records to be filtered at the default level")
+	}
+	if strings.Contains(out, secretKey) {
+		t.Fatalf("logs leak API key:\n%s", out)
+	}
+}
+
+func TestWithAPIKeyHeader_MovesKeyOutOfPath(t *testing.T) {
+	var gotPath, gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotHeader = r.Header.Get("X-Api-Key")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","pageResponses":[{"statusCode":200}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(secretKey, WithEndpoint(server.URL+"/api/"), WithAPIKeyHeader("X-Api-Key"))
+	if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); err != nil {
+		t.Fatalf("DoPage failed: %v", err)
+	}
+	if gotPath != "/api/" {
+		t.Fatalf("expected key-free path, got %q", gotPath)
+	}
+	if gotHeader != secretKey {
+		t.Fatalf("expected key in header, got %q", gotHeader)
+	}
+}
+
+func TestClient_StringRedactsAPIKey(t *testing.T) {
+	client := NewClient(secretKey, WithTimeout(time.Second))
+	for _, s := range []string{fmt.Sprint(client), fmt.Sprintf("%+v", client), fmt.Sprintf("%#v", client)} {
+		if strings.Contains(s, secretKey) {
+			t.Fatalf("formatted client leaks API key: %s", s)
+		}
+	}
+}
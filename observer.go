@@ -0,0 +1,211 @@
+package phantomjscloud
+
+import (
+	"context"
+	"time"
+)
+
+// Observer receives typed lifecycle events for every call made through a
+// Client. Unlike an Interceptor it sees decoded responses, retry decisions and
+// cache hits, so tracing and logging don't need to parse bodies again.
+//
+// Methods are called synchronously on the calling goroutine and must be safe
+// for concurrent use. Embed NopObserver to implement only some of them.
+type Observer interface {
+	// OnRequestStart is called when DoContext is entered.
+	OnRequestStart(ctx context.Context, e RequestStartEvent)
+	// OnRequestEnd is called when DoContext returns.
+	OnRequestEnd(ctx context.Context, e RequestEndEvent)
+	// OnAttemptStart is called before each HTTP attempt, including retries.
+	OnAttemptStart(ctx context.Context, e AttemptStartEvent)
+	// OnAttemptEnd is called after each HTTP attempt.
+	OnAttemptEnd(ctx context.Context, e AttemptEndEvent)
+	// OnRetryScheduled is called when a failed attempt will be retried.
+	OnRetryScheduled(ctx context.Context, e RetryScheduledEvent)
+	// OnResponse is called for every successfully decoded API response.
+	OnResponse(ctx context.Context, e ResponseEvent)
+	// OnCacheHit is called when a response is served from the client's Cache.
+	OnCacheHit(ctx context.Context, e CacheHitEvent)
+	// OnOrchestrationAttempt is called by ext/scraper orchestrators after each attempt.
+	OnOrchestrationAttempt(ctx context.Context, e OrchestrationAttemptEvent)
+	// OnBatch is called by ext/scraper's BatchProcessor after each batch call.
+	OnBatch(ctx context.Context, e BatchEvent)
+}
+
+// RequestStartEvent is emitted when a call enters DoContext.
+type RequestStartEvent struct {
+	Request *UserRequest
+	Time    time.Time
+}
+
+// RequestEndEvent is emitted when DoContext returns.
+type RequestEndEvent struct {
+	Request  *UserRequest
+	Response *UserResponseWithMeta
+	Err      error
+	Duration time.Duration
+}
+
+// AttemptStartEvent is emitted before each HTTP attempt.
+type AttemptStartEvent struct {
+	Request *UserRequest
+	// Attempt is the zero-based attempt index; values above zero are retries.
+	Attempt int
+	Time    time.Time
+}
+
+// AttemptEndEvent is emitted after each HTTP attempt.
+type AttemptEndEvent struct {
+	Request  *UserRequest
+	Attempt  int
+	Response *UserResponseWithMeta
+	Err      error
+	Duration time.Duration
+}
+
+// RetryScheduledEvent is emitted when an attempt failed and another will follow after Delay.
+type RetryScheduledEvent struct {
+	Request *UserRequest
+	Attempt int
+	Class   RetryClass
+	Delay   time.Duration
+	Err     error
+}
+
+// ResponseEvent is emitted for every decoded API response.
+type ResponseEvent struct {
+	Request  *UserRequest
+	Attempt  int
+	Response *UserResponseWithMeta
+	// Credits is the billed cost reported by the API.
+	Credits float64
+	// Blocked is the verdict of RetryConfig.IsBlocked, or false when it is not configured.
+	Blocked bool
+}
+
+// CacheHitEvent is emitted when a response is served from the client's Cache.
+type CacheHitEvent struct {
+	Request  *UserRequest
+	Key      string
+	StoredAt time.Time
+}
+
+// OrchestrationAttemptEvent is emitted by the ext/scraper orchestrators after each attempt.
+type OrchestrationAttemptEvent struct {
+	// Orchestrator names the helper, e.g. "adaptive", "routing" or "challenge".
+	Orchestrator string
+	Attempt      int
+	URL          string
+	Level        string
+	Persona      string
+	Proxy        interface{}
+	Blocked      bool
+	Response     *UserResponseWithMeta
+	Err          error
+}
+
+// BatchEvent is emitted by ext/scraper's BatchProcessor after each batch call.
+type BatchEvent struct {
+	Pages int
+	// Failed counts pages that returned an error or an HTTP status of 400 or above.
+	Failed   int
+	Duration time.Duration
+	Err      error
+}
+
+// NopObserver implements Observer with no-op methods. Embed it to observe a subset of events.
+type NopObserver struct{}
+
+func (NopObserver) OnRequestStart(context.Context, RequestStartEvent)                 {}
+func (NopObserver) OnRequestEnd(context.Context, RequestEndEvent)                     {}
+func (NopObserver) OnAttemptStart(context.Context, AttemptStartEvent)                 {}
+func (NopObserver) OnAttemptEnd(context.Context, AttemptEndEvent)                     {}
+func (NopObserver) OnRetryScheduled(context.Context, RetryScheduledEvent)             {}
+func (NopObserver) OnResponse(context.Context, ResponseEvent)                         {}
+func (NopObserver) OnCacheHit(context.Context, CacheHitEvent)                         {}
+func (NopObserver) OnOrchestrationAttempt(context.Context, OrchestrationAttemptEvent) {}
+func (NopObserver) OnBatch(context.Context, BatchEvent)                               {}
+
+// WithObserver registers o to receive lifecycle events. It may be used more
+// than once; observers are called in registration order.
+func WithObserver(o Observer) ClientOption {
+	return func(c *Client) {
+		if o == nil {
+			return
+		}
+		switch existing := c.observer.(type) {
+		case nil:
+			c.observer = o
+		case multiObserver:
+			c.observer = append(existing, o)
+		default:
+			c.observer = multiObserver{existing, o}
+		}
+	}
+}
+
+// Observer returns the client's registered observers as one Observer, or a
+// NopObserver when none are registered. Helpers built on top of the Client,
+// such as the ext/scraper orchestrators, use it to emit their own events.
+func (c *Client) Observer() Observer {
+	if c.observer == nil {
+		return NopObserver{}
+	}
+	return c.observer
+}
+
+type multiObserver []Observer
+
+func (m multiObserver) OnRequestStart(ctx context.Context, e RequestStartEvent) {
+	for _, o := range m {
+		o.OnRequestStart(ctx, e)
+	}
+}
+
+func (m multiObserver) OnRequestEnd(ctx context.Context, e RequestEndEvent) {
+	for _, o := range m {
+		o.OnRequestEnd(ctx, e)
+	}
+}
+
+func (m multiObserver) OnAttemptStart(ctx context.Context, e AttemptStartEvent) {
+	for _, o := range m {
+		o.OnAttemptStart(ctx, e)
+	}
+}
+
+func (m multiObserver) OnAttemptEnd(ctx context.Context, e AttemptEndEvent) {
+	for _, o := range m {
+		o.OnAttemptEnd(ctx, e)
+	}
+}
+
+func (m multiObserver) OnRetryScheduled(ctx context.Context, e RetryScheduledEvent) {
+	for _, o := range m {
+		o.OnRetryScheduled(ctx, e)
+	}
+}
+
+func (m multiObserver) OnResponse(ctx context.Context, e ResponseEvent) {
+	for _, o := range m {
+		o.OnResponse(ctx, e)
+	}
+}
+
+func (m multiObserver) OnCacheHit(ctx context.Context, e CacheHitEvent) {
+	for _, o := range m {
+		o.OnCacheHit(ctx, e)
+	}
+}
+
+func (m multiObserver) OnOrchestrationAttempt(ctx context.Context, e OrchestrationAttemptEvent) {
+	for _, o := range m {
+		o.OnOrchestrationAttempt(ctx, e)
+	}
+}
+
+func (m multiObserver) OnBatch(ctx context.Context, e BatchEvent) {
+	for _, o := range m {
+		o.OnBatch(ctx, e)
+	}
+}
@@ -0,0 +1,110 @@
+package phantomjscloud
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingObserver struct {
+	NopObserver
+	mu     sync.Mutex
+	events []string
+	last   ResponseEvent
+}
+
+func (r *recordingObserver) add(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, s)
+}
+
+func (r *recordingObserver) OnRequestStart(context.Context, RequestStartEvent) {
+	r.add("request-start")
+}
+func (r *recordingObserver) OnRequestEnd(_ context.Context, e RequestEndEvent) {
+	if e.Err != nil {
+		r.add("request-end:error")
+		return
+	}
+	r.add("request-end")
+}
+func (r *recordingObserver) OnAttemptStart(_ context.Context, e AttemptStartEvent) {
+	r.add("attempt-start:" + strconv.Itoa(e.Attempt))
+}
+func (r *recordingObserver) OnAttemptEnd(_ context.Context, e AttemptEndEvent) {
+	r.add("attempt-end:" + strconv.Itoa(e.Attempt))
+}
+func (r *recordingObserver) OnRetryScheduled(_ context.Context, e RetryScheduledEvent) {
+	r.add("retry:" + string(e.Class))
+}
+func (r *recordingObserver) OnResponse(_ context.Context, e ResponseEvent) {
+	r.mu.Lock()
+	r.last = e
+	r.mu.Unlock()
+	r.add("response")
+}
+func (r *recordingObserver) OnCacheHit(context.Context, CacheHitEvent) { r.add("cache-hit") }
+
+func (r *recordingObserver) trace() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.events, ",")
+}
+
+func TestObserver_SeesRetriesAndDecodedResponse(t *testing.T) {
+	server, _ := newSequenceServer(t, 503, 200)
+	obs := &recordingObserver{}
+
+	cfg := fastRetry()
+	cfg.IsBlocked = func(r *UserResponseWithMeta) bool { return r.PageResponses[0].Content == "ok" }
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRetry(cfg), WithObserver(obs))
+	if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); err != nil {
+		t.Fatalf("DoPage failed: %v", err)
+	}
+
+	want := "request-start,attempt-start:0,attempt-end:0,retry:serverError,attempt-start:1,attempt-end:1,response,request-end"
+	if got := obs.trace(); got != want {
+		t.Fatalf("unexpected event trace:\n got %s\nwant %s", got, want)
+	}
+	if obs.last.Attempt != 1 || !obs.last.Blocked {
+		t.Fatalf("unexpected response event: %+v", obs.last)
+	}
+}
+
+func TestObserver_SeesCacheHitsAndFansOut(t *testing.T) {
+	server, _ := newCountingServer(t)
+	first, second := &recordingObserver{}, &recordingObserver{}
+	client := NewClient("test-key",
+		WithEndpoint(server.URL+"/"),
+		WithCache(CacheConfig{Backend: NewMemoryCache(0), TTL: time.Minute}),
+		WithObserver(first),
+		WithObserver(second),
+	)
+	req := &PageRequest{URL: "https://example.com"}
+	for i := 0; i < 2; i++ {
+		if _, err := client.DoPage(req); err != nil {
+			t.Fatalf("DoPage failed: %v", err)
+		}
+	}
+
+	want := "request-start,attempt-start:0,attempt-end:0,response,request-end,request-start,cache-hit,request-end"
+	for _, obs := range []*recordingObserver{first, second} {
+		if got := obs.trace(); got != want {
+			t.Fatalf("unexpected event trace:\n got %s\nwant %s", got, want)
+		}
+	}
+	if first.last.Credits != 1 {
+		t.Fatalf("expected billed credits on response event, got %v", first.last.Credits)
+	}
+}
+
+func TestClientObserver_DefaultsToNop(t *testing.T) {
+	client := NewClient("test-key")
+	if _, ok := client.Observer().(NopObserver); !ok {
+		t.Fatalf("expected NopObserver, got %T", client.Observer())
+	}
+}
@@ -0,0 +1,340 @@
+package phantomjscloud
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PaperSize is a named paper format or a custom width and height.
+type PaperSize struct {
+	// Format is the Chromium paper format name, empty for custom sizes.
+	Format string
+	// Width and Height are CSS lengths, set for custom sizes.
+	Width  string
+	Height string
+}
+
+// Paper sizes supported by Chromium's PDF printer.
+var (
+	PaperLetter  = PaperSize{Format: "Letter"}
+	PaperLegal   = PaperSize{Format: "Legal"}
+	PaperTabloid = PaperSize{Format: "Tabloid"}
+	PaperLedger  = PaperSize{Format: "Ledger"}
+	PaperA3      = PaperSize{Format: "A3"}
+	PaperA4      = PaperSize{Format: "A4"}
+	PaperA5      = PaperSize{Format: "A5"}
+	PaperA6      = PaperSize{Format: "A6"}
+)
+
+// CustomPaperSize returns a paper size from CSS lengths such as "210mm" or "8.5in".
+func CustomPaperSize(width, height string) PaperSize {
+	return PaperSize{Width: width, Height: height}
+}
+
+// paperInches holds the portrait width and height of each named format.
+var paperInches = map[string][2]float64{
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+	"ledger":  {17, 11},
+	"a0":      {33.1, 46.8},
+	"a1":      {23.4, 33.1},
+	"a2":      {16.54, 23.4},
+	"a3":      {11.7, 16.54},
+	"a4":      {8.27, 11.7},
+	"a5":      {5.83, 8.27},
+	"a6":      {4.13, 5.83},
+}
+
+// PdfOrientation is the page orientation of a PDF.
+type PdfOrientation int
+
+const (
+	PdfPortrait PdfOrientation = iota
+	PdfLandscape
+)
+
+// PdfOptionsBuilder constructs PdfOptions with typed paper sizes and checked
+// margins. Build validates the combination.
+//
+//	opts, err := phantomjscloud.NewPdfOptionsBuilder().
+//	    WithPaper(phantomjscloud.PaperA4).
+//	    WithMargins("20mm", "15mm", "20mm", "15mm").
+//	    WithFooter(phantomjscloud.NewPdfHeaderFooter().Text("Page ").PageNumber().Text(" of ").TotalPages()).
+//	    Build()
+type PdfOptionsBuilder struct {
+	opts PdfOptions
+}
+
+// NewPdfOptionsBuilder returns a builder that prints backgrounds and
+// otherwise uses the API defaults.
+func NewPdfOptionsBuilder() *PdfOptionsBuilder {
+	return &PdfOptionsBuilder{opts: PdfOptions{PrintBackground: true}}
+}
+
+// WithPaper sets a named or custom paper size.
+func (b *PdfOptionsBuilder) WithPaper(p PaperSize) *PdfOptionsBuilder {
+	b.opts.Format, b.opts.Width, b.opts.Height = p.Format, p.Width, p.Height
+	return b
+}
+
+// WithOrientation sets portrait or landscape pages.
+func (b *PdfOptionsBuilder) WithOrientation(o PdfOrientation) *PdfOptionsBuilder {
+	b.opts.Landscape = o == PdfLandscape
+	return b
+}
+
+// WithMargins sets the page margins as CSS lengths with a px, in, cm or mm unit.
+func (b *PdfOptionsBuilder) WithMargins(top, right, bottom, left string) *PdfOptionsBuilder {
+	b.opts.Margin = &Margin{Top: top, Right: right, Bottom: bottom, Left: left}
+	return b
+}
+
+// WithMargin sets the same margin on every side.
+func (b *PdfOptionsBuilder) WithMargin(all string) *PdfOptionsBuilder {
+	return b.WithMargins(all, all, all, all)
+}
+
+// WithScale sets the rendering scale, between 0.1 and 2.
+func (b *PdfOptionsBuilder) WithScale(scale float64) *PdfOptionsBuilder {
+	b.opts.Scale = scale
+	return b
+}
+
+// WithPrintBackground sets whether background colors and images are printed.
+func (b *PdfOptionsBuilder) WithPrintBackground(v bool) *PdfOptionsBuilder {
+	b.opts.PrintBackground = v
+	return b
+}
+
+// WithPageRanges limits the pages printed, e.g. "1-5, 8, 11-13".
+func (b *PdfOptionsBuilder) WithPageRanges(ranges string) *PdfOptionsBuilder {
+	b.opts.PageRanges = ranges
+	return b
+}
+
+// WithPreferCSSPageSize lets an @page size in the document override the paper size.
+func (b *PdfOptionsBuilder) WithPreferCSSPageSize(v bool) *PdfOptionsBuilder {
+	b.opts.PreferCSSPageSize = v
+	return b
+}
+
+// WithHeader sets the header template and enables headers and footers.
+func (b *PdfOptionsBuilder) WithHeader(h *PdfHeaderFooter) *PdfOptionsBuilder {
+	b.opts.HeaderTemplate = h.Build()
+	b.opts.DisplayHeaderFooter = true
+	return b
+}
+
+// WithFooter sets the footer template and enables headers and footers.
+func (b *PdfOptionsBuilder) WithFooter(f *PdfHeaderFooter) *PdfOptionsBuilder {
+	b.opts.FooterTemplate = f.Build()
+	b.opts.DisplayHeaderFooter = true
+	return b
+}
+
+// Build validates the options and returns a copy. It returns a
+// *ValidationError listing every problem.
+func (b *PdfOptionsBuilder) Build() (*PdfOptions, error) {
+	opts := b.opts
+	if opts.Margin != nil {
+		m := *opts.Margin
+		opts.Margin = &m
+	}
+	if opts.DisplayHeaderFooter {
+		// Chromium prints a missing header or footer as the page title and URL.
+		if opts.HeaderTemplate == "" {
+			opts.HeaderTemplate = emptyPdfTemplate
+		}
+		if opts.FooterTemplate == "" {
+			opts.FooterTemplate = emptyPdfTemplate
+		}
+	}
+	v := &validator{}
+	v.pdfOptions("", &opts)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+	return &opts, nil
+}
+
+const emptyPdfTemplate = "<span></span>"
+
+var pageRangesRe = regexp.MustCompile(`^\s*\d+(\s*-\s*\d*)?(\s*,\s*\d+(\s*-\s*\d*)?)*\s*$`)
+
+// pdfOptions checks units, formats and ranges, and that the margins leave
+// room for content and for any header or footer.
+func (v *validator) pdfOptions(prefix string, o *PdfOptions) {
+	var width, height float64
+	switch {
+	case o.Format != "" && (o.Width != "" || o.Height != ""):
+		v.add(prefix+"format", "set either format or width and height, not both")
+	case o.Format != "":
+		size, ok := paperInches[strings.ToLower(o.Format)]
+		if !ok {
+			v.add(prefix+"format", fmt.Sprintf("unknown paper format %q", o.Format))
+		}
+		width, height = size[0], size[1]
+	case o.Width != "" || o.Height != "":
+		width = v.pdfLength(prefix+"width", o.Width, true)
+		height = v.pdfLength(prefix+"height", o.Height, true)
+	}
+	if o.Landscape {
+		width, height = height, width
+	}
+
+	if o.Scale != 0 && (o.Scale < 0.1 || o.Scale > 2) {
+		v.add(prefix+"scale", fmt.Sprintf("%g is outside 0.1 to 2", o.Scale))
+	}
+	if o.PageRanges != "" && !pageRangesRe.MatchString(o.PageRanges) {
+		v.add(prefix+"pageRanges", fmt.Sprintf("%q is not a list of pages and ranges such as \"1-5, 8\"", o.PageRanges))
+	}
+
+	var m Margin
+	if o.Margin != nil {
+		m = *o.Margin
+	}
+	top := v.pdfLength(prefix+"margin.top", m.Top, false)
+	right := v.pdfLength(prefix+"margin.right", m.Right, false)
+	bottom := v.pdfLength(prefix+"margin.bottom", m.Bottom, false)
+	left := v.pdfLength(prefix+"margin.left", m.Left, false)
+	if width > 0 && left+right >= width {
+		v.add(prefix+"margin", "left and right margins leave no room on the page")
+	}
+	if height > 0 && top+bottom >= height {
+		v.add(prefix+"margin", "top and bottom margins leave no room on the page")
+	}
+
+	if o.DisplayHeaderFooter {
+		// Headers and footers are drawn inside the page margins.
+		if strings.TrimSpace(o.HeaderTemplate) != "" && o.HeaderTemplate != emptyPdfTemplate && top <= 0 {
+			v.add(prefix+"margin.top", "a header needs a top margin to be visible")
+		}
+		if strings.TrimSpace(o.FooterTemplate) != "" && o.FooterTemplate != emptyPdfTemplate && bottom <= 0 {
+			v.add(prefix+"margin.bottom", "a footer needs a bottom margin to be visible")
+		}
+	} else if o.HeaderTemplate != "" || o.FooterTemplate != "" {
+		v.add(prefix+"displayHeaderFooter", "must be true for the header and footer templates to print")
+	}
+}
+
+// pdfLength converts a CSS length to inches, recording an error for unknown
+// units. Empty values are zero unless required.
+func (v *validator) pdfLength(field, s string, required bool) float64 {
+	if s == "" {
+		if required {
+			v.add(field, "required for a custom paper size")
+		}
+		return 0
+	}
+	inches, err := parsePdfLength(s)
+	if err != nil {
+		v.add(field, err.Error())
+		return 0
+	}
+	if required && inches <= 0 {
+		v.add(field, "must be positive")
+	}
+	return inches
+}
+
+// parsePdfLength converts a length with a px, in, cm or mm unit to inches.
+// Unitless numbers are pixels, as in Chromium.
+func parsePdfLength(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	num, unit := s, "px"
+	for _, u := range []string{"px", "in", "cm", "mm"} {
+		if strings.HasSuffix(s, u) {
+			num, unit = strings.TrimSpace(strings.TrimSuffix(s, u)), u
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a length in px, in, cm or mm", s)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("%q must not be negative", s)
+	}
+	switch unit {
+	case "in":
+		return n, nil
+	case "cm":
+		return n / 2.54, nil
+	case "mm":
+		return n / 25.4, nil
+	}
+	return n / 96, nil
+}
+
+// PdfHeaderFooter builds a Chromium header or footer template. Chromium
+// fills elements with the pageNumber, totalPages, date, title and url
+// classes, and renders templates with no inherited styles, so a font size is
+// always set.
+type PdfHeaderFooter struct {
+	parts    []string
+	fontSize string
+	align    string
+	style    string
+}
+
+// NewPdfHeaderFooter returns an empty, centered template with a 10px font.
+func NewPdfHeaderFooter() *PdfHeaderFooter {
+	return &PdfHeaderFooter{fontSize: "10px", align: "center"}
+}
+
+// Text appends HTML-escaped text.
+func (h *PdfHeaderFooter) Text(s string) *PdfHeaderFooter {
+	h.parts = append(h.parts, html.EscapeString(s))
+	return h
+}
+
+// PageNumber appends the current page number.
+func (h *PdfHeaderFooter) PageNumber() *PdfHeaderFooter { return h.class("pageNumber") }
+
+// TotalPages appends the number of pages.
+func (h *PdfHeaderFooter) TotalPages() *PdfHeaderFooter { return h.class("totalPages") }
+
+// Date appends the print date.
+func (h *PdfHeaderFooter) Date() *PdfHeaderFooter { return h.class("date") }
+
+// Title appends the document title.
+func (h *PdfHeaderFooter) Title() *PdfHeaderFooter { return h.class("title") }
+
+// URL appends the document URL.
+func (h *PdfHeaderFooter) URL() *PdfHeaderFooter { return h.class("url") }
+
+func (h *PdfHeaderFooter) class(name string) *PdfHeaderFooter {
+	h.parts = append(h.parts, `<span class="`+name+`"></span>`)
+	return h
+}
+
+// WithFontSize sets the font size as a CSS length, e.g. "9px".
+func (h *PdfHeaderFooter) WithFontSize(size string) *PdfHeaderFooter {
+	h.fontSize = size
+	return h
+}
+
+// WithAlign sets the text alignment: "left", "center" or "right".
+func (h *PdfHeaderFooter) WithAlign(align string) *PdfHeaderFooter {
+	h.align = align
+	return h
+}
+
+// WithStyle appends CSS declarations to the template's container.
+func (h *PdfHeaderFooter) WithStyle(css string) *PdfHeaderFooter {
+	h.style = css
+	return h
+}
+
+// Build returns the template markup.
+func (h *PdfHeaderFooter) Build() string {
+	style := "width:100%;margin:0 0.4in;font-size:" + h.fontSize + ";text-align:" + h.align + ";"
+	if h.style != "" {
+		style += strings.TrimSuffix(h.style, ";") + ";"
+	}
+	return `<div style="` + html.EscapeString(style) + `">` + strings.Join(h.parts, "") + `</div>`
+}
@@ -0,0 +1,89 @@
+package phantomjscloud
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPdfOptionsBuilder_Build(t *testing.T) {
+	opts, err := NewPdfOptionsBuilder().
+		WithPaper(PaperA4).
+		WithOrientation(PdfLandscape).
+		WithMargins("20mm", "0.5in", "2cm", "48px").
+		WithPageRanges("1-3, 5").
+		WithHeader(NewPdfHeaderFooter().Title().WithAlign("left")).
+		WithFooter(NewPdfHeaderFooter().Text("Page ").PageNumber().Text(" of ").TotalPages().Text(" <draft>")).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if opts.Format != "A4" || !opts.Landscape || !opts.DisplayHeaderFooter || !opts.PrintBackground {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+	if opts.Margin.Top != "20mm" || opts.Margin.Left != "48px" {
+		t.Errorf("unexpected margin %+v", opts.Margin)
+	}
+	if !strings.Contains(opts.HeaderTemplate, `<span class="title"></span>`) || !strings.Contains(opts.HeaderTemplate, "text-align:left") {
+		t.Errorf("unexpected header %q", opts.HeaderTemplate)
+	}
+	want := `Page <span class="pageNumber"></span> of <span class="totalPages"></span> &lt;draft&gt;</div>`
+	if !strings.HasSuffix(opts.FooterTemplate, want) || !strings.Contains(opts.FooterTemplate, "font-size:10px") {
+		t.Errorf("unexpected footer %q", opts.FooterTemplate)
+	}
+}
+
+func TestPdfOptionsBuilder_FooterOnlyBlanksHeader(t *testing.T) {
+	opts, err := NewPdfOptionsBuilder().
+		WithPaper(CustomPaperSize("100mm", "150mm")).
+		WithMargins("0", "0", "1cm", "0").
+		WithFooter(NewPdfHeaderFooter().Date()).
+		Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if opts.HeaderTemplate != emptyPdfTemplate || opts.Width != "100mm" || opts.Format != "" {
+		t.Errorf("unexpected options %+v", opts)
+	}
+}
+
+func TestPdfOptionsBuilder_Validation(t *testing.T) {
+	_, err := NewPdfOptionsBuilder().
+		WithPaper(PaperSize{Format: "B5"}).
+		WithMargins("1em", "-1mm", "0", "1cm").
+		WithScale(3).
+		WithPageRanges("first").
+		WithFooter(NewPdfHeaderFooter().PageNumber()).
+		Build()
+	var ve *ValidationError
+	if !errors.As(err, &ve) || !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected a *ValidationError, got %v", err)
+	}
+	fields := map[string]bool{}
+	for _, fe := range ve.Errors {
+		fields[fe.Field] = true
+	}
+	for _, f := range []string{"format", "scale", "pageRanges", "margin.top", "margin.right", "margin.bottom"} {
+		if !fields[f] {
+			t.Errorf("expected an error for %s, got %v", f, ve)
+		}
+	}
+
+	_, err = NewPdfOptionsBuilder().WithPaper(PaperA6).WithOrientation(PdfLandscape).WithMargins("0", "3in", "0", "3in").Build()
+	if err == nil || !strings.Contains(err.Error(), "left and right margins leave no room") {
+		t.Errorf("expected landscape A6 margins to overflow, got %v", err)
+	}
+	if _, err := NewPdfOptionsBuilder().WithPaper(CustomPaperSize("10cm", "")).Build(); err == nil {
+		t.Error("expected custom paper without a height to fail")
+	}
+}
+
+func TestValidate_PdfOptions(t *testing.T) {
+	req := &PageRequest{URL: "https://example.com", RenderType: "pdf", RenderSettings: RenderSettings{
+		PdfOptions: &PdfOptions{Format: "A4", FooterTemplate: "<span class=\"pageNumber\"></span>"},
+	}}
+	err := req.Validate()
+	if err == nil || !strings.Contains(err.Error(), "renderSettings.pdfOptions.displayHeaderFooter") {
+		t.Fatalf("expected displayHeaderFooter error, got %v", err)
+	}
+}
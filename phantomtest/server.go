@@ -0,0 +1,438 @@
+// Package phantomtest provides an offline fake of the PhantomJsCloud browser API
+// for use in tests. It plays the same role for this module that net/http/httptest
+// plays for net/http: start a Server, point a Client at it with WithEndpoint,
+// and script canned responses per URL, render type and attempt number.
+//
+//	srv := phantomtest.NewServer()
+//	defer srv.Close()
+//
+//	srv.Handle(phantomtest.Match{URL: "https://example.com"}, phantomtest.HTML("<h1>ok</h1>"))
+//	srv.Handle(phantomtest.Match{URL: "https://example.com", RenderType: "png"}, phantomtest.PNG(img))
+//
+//	client := phantomjscloud.NewClient("test-key", phantomjscloud.WithEndpoint(srv.Endpoint()))
+package phantomtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+)
+
+// apiPath is the path prefix of the PhantomJsCloud browser endpoint.
+// The API key is the next path segment: /api/browser/v2/{key}/.
+const apiPath = "/api/browser/v2/"
+
+// Match selects which page requests a canned Response applies to.
+// Zero-valued fields act as wildcards.
+type Match struct {
+	// URL matches PageRequest.URL exactly.
+	URL string
+	// RenderType matches PageRequest.RenderType. An empty request render type
+	// is treated as "html", the PhantomJsCloud default.
+	RenderType string
+	// Attempt matches the 1-based number of times a page with the same URL and
+	// render type has been received by the server, including this one.
+	Attempt int
+}
+
+// Response is a canned reply for one page request.
+type Response struct {
+	// StatusCode is the HTTP status of the API call. Defaults to 200.
+	StatusCode int
+	// ContentType of a raw Body. Ignored when Body is nil.
+	ContentType string
+	// Body is written verbatim instead of a JSON envelope, as the real API does
+	// for png, jpeg, pdf and plainText renders and for error payloads.
+	Body []byte
+	// Page is the page-level result placed in the JSON UserResponse envelope.
+	Page phantomjscloud.PageResponse
+	// Metadata is emitted as the pjsc-* response headers read by the client.
+	Metadata phantomjscloud.ResponseMetadata
+	// Header holds any additional response headers.
+	Header http.Header
+	// Delay holds the response back, honouring request cancellation.
+	Delay time.Duration
+}
+
+// WithMetadata returns a copy of r that emits meta as pjsc-* headers.
+func (r Response) WithMetadata(meta phantomjscloud.ResponseMetadata) Response {
+	r.Metadata = meta
+	return r
+}
+
+// WithHeader returns a copy of r with an additional response header.
+func (r Response) WithHeader(key, value string) Response {
+	h := r.Header.Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	h.Add(key, value)
+	r.Header = h
+	return r
+}
+
+// WithDelay returns a copy of r that is held back for d before being written.
+func (r Response) WithDelay(d time.Duration) Response {
+	r.Delay = d
+	return r
+}
+
+// JSON returns a response that places page in the JSON UserResponse envelope.
+func JSON(page phantomjscloud.PageResponse) Response {
+	return Response{Page: page}
+}
+
+// HTML returns a successful JSON response whose page content is html.
+func HTML(html string) Response {
+	return JSON(phantomjscloud.PageResponse{
+		Content:    html,
+		StatusCode: http.StatusOK,
+		StatusText: http.StatusText(http.StatusOK),
+	})
+}
+
+// PlainText returns a raw text/plain response, as sent for plainText renders.
+func PlainText(text string) Response {
+	return Response{ContentType: "text/plain; charset=utf-8", Body: []byte(text)}
+}
+
+// PNG returns a raw image/png response.
+func PNG(data []byte) Response {
+	return Response{ContentType: "image/png", Body: data}
+}
+
+// JPEG returns a raw image/jpeg response.
+func JPEG(data []byte) Response {
+	return Response{ContentType: "image/jpeg", Body: data}
+}
+
+// PDF returns a raw application/pdf response.
+func PDF(data []byte) Response {
+	return Response{ContentType: "application/pdf", Body: data}
+}
+
+// Error returns a failed API response with the given HTTP status and raw body.
+func Error(statusCode int, body string) Response {
+	return Response{
+		StatusCode:  statusCode,
+		ContentType: "text/plain; charset=utf-8",
+		Body:        []byte(body),
+	}
+}
+
+// APIError returns a failed API response carrying a PhantomJsCloud-style error
+// JSON body, which the client decodes into a *phantomjscloud.APIError.
+func APIError(statusCode int, name, message string) Response {
+	body, _ := json.Marshal(map[string]interface{}{
+		"name":       name,
+		"statusCode": statusCode,
+		"message":    message,
+	})
+	return Response{
+		StatusCode:  statusCode,
+		ContentType: "application/json",
+		Body:        body,
+	}
+}
+
+// RecordedRequest is one API call received by the Server.
+type RecordedRequest struct {
+	APIKey  string
+	Header  http.Header
+	Request phantomjscloud.UserRequest
+}
+
+type route struct {
+	match Match
+	resp  Response
+}
+
+// Server is an httptest-backed fake of the /api/browser/v2/{key}/ endpoint.
+// It decodes each UserRequest, picks a canned Response per page and replies
+// the way PhantomJsCloud does. Pages with no matching route get an empty
+// successful HTML response. Server is safe for concurrent use.
+type Server struct {
+	// URL is the base URL of the form http://ipaddr:port with no trailing slash.
+	URL string
+
+	srv      *httptest.Server
+	mu       sync.Mutex
+	routes   []route
+	attempts map[string]int
+	requests []RecordedRequest
+}
+
+// NewServer starts and returns a new Server. The caller should call Close
+// when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		attempts: make(map[string]int),
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server and blocks until all outstanding requests have completed.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Endpoint returns the value to pass to phantomjscloud.WithEndpoint.
+func (s *Server) Endpoint() string {
+	return s.URL + apiPath
+}
+
+// Client returns a phantomjscloud.Client already pointed at the server.
+// Additional options are applied after the endpoint override.
+func (s *Server) Client(apiKey string, opts ...phantomjscloud.ClientOption) *phantomjscloud.Client {
+	all := append([]phantomjscloud.ClientOption{phantomjscloud.WithEndpoint(s.Endpoint())}, opts...)
+	return phantomjscloud.NewClient(apiKey, all...)
+}
+
+// Handle registers resp for page requests selected by m. When several routes
+// match a page, the most specific one wins; ties go to the latest registration.
+func (s *Server) Handle(m Match, resp Response) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = append(s.routes, route{match: m, resp: resp})
+	return s
+}
+
+// HandleSequence registers one response per attempt for the given URL and
+// render type: resps[0] answers attempt 1, resps[1] attempt 2, and so on.
+// The last response also answers every later attempt.
+func (s *Server) HandleSequence(url, renderType string, resps ...Response) *Server {
+	for i, r := range resps {
+		s.Handle(Match{URL: url, RenderType: renderType, Attempt: i + 1}, r)
+	}
+	if len(resps) > 0 {
+		s.Handle(Match{URL: url, RenderType: renderType}, resps[len(resps)-1])
+	}
+	return s
+}
+
+// Requests returns a copy of every API call received so far, in arrival order.
+func (s *Server) Requests() []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RecordedRequest(nil), s.requests...)
+}
+
+// Attempts returns how many times a page with url and renderType has been received.
+func (s *Server) Attempts(url, renderType string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[attemptKey(url, renderType)]
+}
+
+// Reset clears all routes, recorded requests and attempt counters.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = nil
+	s.requests = nil
+	s.attempts = make(map[string]int)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.URL.Path, apiPath) {
+		http.NotFound(w, r)
+		return
+	}
+	apiKey := strings.Trim(strings.TrimPrefix(r.URL.Path, apiPath), "/")
+
+	var ureq phantomjscloud.UserRequest
+	if err := json.NewDecoder(r.Body).Decode(&ureq); err != nil {
+		http.Error(w, "phantomtest: failed to decode UserRequest: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resps := s.record(apiKey, r.Header, ureq)
+
+	var delay time.Duration
+	for _, resp := range resps {
+		if resp.Delay > delay {
+			delay = resp.Delay
+		}
+	}
+	if delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+
+	// A failed page fails the whole call, as it does upstream.
+	for _, resp := range resps {
+		if resp.StatusCode >= 400 {
+			writeRaw(w, resp)
+			return
+		}
+	}
+	if len(resps) == 1 && resps[0].Body != nil {
+		writeRaw(w, resps[0])
+		return
+	}
+	writeEnvelope(w, resps)
+}
+
+// record stores the request, bumps attempt counters and resolves one response per page.
+func (s *Server) record(apiKey string, header http.Header, ureq phantomjscloud.UserRequest) []Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, RecordedRequest{
+		APIKey:  apiKey,
+		Header:  header.Clone(),
+		Request: ureq,
+	})
+
+	resps := make([]Response, len(ureq.Pages))
+	for i, page := range ureq.Pages {
+		key := attemptKey(page.URL, page.RenderType)
+		s.attempts[key]++
+		resps[i] = s.lookup(page, s.attempts[key])
+	}
+	return resps
+}
+
+func (s *Server) lookup(page phantomjscloud.PageRequest, attempt int) Response {
+	renderType := normalizeRenderType(page.RenderType)
+	best, bestScore := -1, -1
+	for i, rt := range s.routes {
+		m := rt.match
+		if m.URL != "" && m.URL != page.URL {
+			continue
+		}
+		if m.RenderType != "" && normalizeRenderType(m.RenderType) != renderType {
+			continue
+		}
+		if m.Attempt != 0 && m.Attempt != attempt {
+			continue
+		}
+		score := 0
+		if m.URL != "" {
+			score++
+		}
+		if m.RenderType != "" {
+			score++
+		}
+		if m.Attempt != 0 {
+			score++
+		}
+		if score >= bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return HTML("")
+	}
+	return s.routes[best].resp
+}
+
+func writeRaw(w http.ResponseWriter, resp Response) {
+	writeHeaders(w, resp)
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	w.WriteHeader(statusOrOK(resp.StatusCode))
+	w.Write(resp.Body)
+}
+
+func writeEnvelope(w http.ResponseWriter, resps []Response) {
+	out := phantomjscloud.UserResponse{
+		Status:        "success",
+		PageResponses: make([]phantomjscloud.PageResponse, len(resps)),
+	}
+	// The API sends one set of headers per call, so the pages' metadata is
+	// combined: costs add up and the other fields come from the first page
+	// that sets them.
+	var total Response
+	for i, resp := range resps {
+		out.PageResponses[i] = resp.Page
+		total.Metadata = mergeMetadata(total.Metadata, resp.Metadata)
+		for k, vs := range resp.Header {
+			if total.Header == nil {
+				total.Header = make(http.Header)
+			}
+			total.Header[k] = append(total.Header[k], vs...)
+		}
+	}
+	writeHeaders(w, total)
+	out.Billing.CreditCost = total.Metadata.BillingCreditCost
+	body, err := json.Marshal(out)
+	if err != nil {
+		http.Error(w, "phantomtest: failed to encode UserResponse: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func mergeMetadata(total, page phantomjscloud.ResponseMetadata) phantomjscloud.ResponseMetadata {
+	total.BillingCostCredits += page.BillingCostCredits
+	total.BillingCreditCost += page.BillingCreditCost
+	if total.Status == "" {
+		total.Status = page.Status
+	}
+	if total.ContentStatusCode == 0 {
+		total.ContentStatusCode = page.ContentStatusCode
+	}
+	if total.ContentDoneWhen == "" {
+		total.ContentDoneWhen = page.ContentDoneWhen
+	}
+	return total
+}
+
+// writeHeaders emits the pjsc-* headers that parseMetadata reads, plus any extras.
+func writeHeaders(w http.ResponseWriter, resp Response) {
+	h := w.Header()
+	meta := resp.Metadata
+	if meta.Status != "" {
+		h.Set("Pjsc-Response-Status", meta.Status)
+	}
+	if meta.BillingCostCredits != 0 {
+		h.Set("Pjsc-Billing-Cost-Credits", strconv.FormatFloat(meta.BillingCostCredits, 'f', -1, 64))
+	}
+	if meta.BillingCreditCost != 0 {
+		h.Set("Pjsc-Billing-Credit-Cost", strconv.FormatFloat(meta.BillingCreditCost, 'f', -1, 64))
+	}
+	if meta.ContentStatusCode != 0 {
+		h.Set("Pjsc-Content-Status-Code", strconv.Itoa(meta.ContentStatusCode))
+	}
+	if meta.ContentDoneWhen != "" {
+		h.Set("Pjsc-Content-Done-When", meta.ContentDoneWhen)
+	}
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			h.Add(k, v)
+		}
+	}
+}
+
+func statusOrOK(code int) int {
+	if code == 0 {
+		return http.StatusOK
+	}
+	return code
+}
+
+func normalizeRenderType(rt string) string {
+	if rt == "" {
+		return "html"
+	}
+	return rt
+}
+
+func attemptKey(url, renderType string) string {
+	return normalizeRenderType(renderType) + " " + url
+}
@@ -0,0 +1,167 @@
+package phantomtest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	phantomjscloud "github.com/amafjarkasi/go-phantomjs"
+	"github.com/amafjarkasi/go-phantomjs/phantomtest"
+)
+
+func TestServer_DefaultResponse(t *testing.T) {
+	srv := phantomtest.NewServer()
+	defer srv.Close()
+
+	resp, err := srv.Client("test-key").DoPage(&phantomjscloud.PageRequest{URL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("DoPage failed: %v", err)
+	}
+	if len(resp.PageResponses) != 1 || resp.PageResponses[0].StatusCode != 200 {
+		t.Fatalf("unexpected default response: %#v", resp.PageResponses)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 recorded request, got %d", len(reqs))
+	}
+	if reqs[0].APIKey != "test-key" {
+		t.Fatalf("expected api key test-key, got %q", reqs[0].APIKey)
+	}
+	if reqs[0].Request.Pages[0].URL != "https://example.com" {
+		t.Fatalf("unexpected recorded page: %#v", reqs[0].Request.Pages[0])
+	}
+}
+
+func TestServer_RawBodiesAndMetadata(t *testing.T) {
+	srv := phantomtest.NewServer()
+	defer srv.Close()
+
+	png := []byte("\x89PNG\r\n\x1a\nfake")
+	srv.Handle(phantomtest.Match{URL: "https://example.com", RenderType: "png"},
+		phantomtest.PNG(png).WithMetadata(phantomjscloud.ResponseMetadata{
+			BillingCreditCost: 0.5,
+			ContentStatusCode: 200,
+			ContentDoneWhen:   "load",
+		}))
+	srv.Handle(phantomtest.Match{URL: "https://example.com", RenderType: "plainText"},
+		phantomtest.PlainText("hello world"))
+
+	client := srv.Client("test-key")
+
+	got, err := client.FetchScreenshot("https://example.com", "png", nil)
+	if err != nil {
+		t.Fatalf("FetchScreenshot failed: %v", err)
+	}
+	if !bytes.Equal(got, png) {
+		t.Fatalf("unexpected png bytes: %q", got)
+	}
+
+	res, err := client.DoPage(&phantomjscloud.PageRequest{URL: "https://example.com", RenderType: "png"})
+	if err != nil {
+		t.Fatalf("DoPage failed: %v", err)
+	}
+	if res.Metadata.BillingCreditCost != 0.5 || res.Metadata.ContentStatusCode != 200 || res.Metadata.ContentDoneWhen != "load" {
+		t.Fatalf("unexpected metadata: %#v", res.Metadata)
+	}
+
+	text, err := client.FetchPlainText("https://example.com")
+	if err != nil {
+		t.Fatalf("FetchPlainText failed: %v", err)
+	}
+	if text != "hello world" {
+		t.Fatalf("unexpected text: %q", text)
+	}
+}
+
+func TestServer_HandleSequenceByAttempt(t *testing.T) {
+	srv := phantomtest.NewServer()
+	defer srv.Close()
+
+	srv.HandleSequence("https://example.com", "",
+		phantomtest.Error(503, "busy"),
+		phantomtest.HTML("second"),
+	)
+
+	client := srv.Client("test-key")
+	req := &phantomjscloud.PageRequest{URL: "https://example.com"}
+
+	if _, err := client.DoPage(req); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("expected 503 error on first attempt, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		res, err := client.DoPage(req)
+		if err != nil {
+			t.Fatalf("attempt %d failed: %v", i+2, err)
+		}
+		if res.PageResponses[0].Content != "second" {
+			t.Fatalf("attempt %d: unexpected content %q", i+2, res.PageResponses[0].Content)
+		}
+	}
+	if got := srv.Attempts("https://example.com", "html"); got != 3 {
+		t.Fatalf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestServer_MultiPageEnvelope(t *testing.T) {
+	srv := phantomtest.NewServer()
+	defer srv.Close()
+
+	srv.Handle(phantomtest.Match{URL: "https://example.com/1"}, phantomtest.HTML("one").
+		WithMetadata(phantomjscloud.ResponseMetadata{BillingCreditCost: 1.5, BillingCostCredits: 1.5, ContentStatusCode: 200}))
+	srv.Handle(phantomtest.Match{URL: "https://example.com/2"}, phantomtest.HTML("two").
+		WithMetadata(phantomjscloud.ResponseMetadata{BillingCreditCost: 2, BillingCostCredits: 2, ContentStatusCode: 404}))
+
+	res, err := srv.Client("test-key").Do(&phantomjscloud.UserRequest{Pages: []phantomjscloud.PageRequest{
+		{URL: "https://example.com/1"},
+		{URL: "https://example.com/2"},
+	}})
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if len(res.PageResponses) != 2 || res.PageResponses[0].Content != "one" || res.PageResponses[1].Content != "two" {
+		t.Fatalf("unexpected page responses: %#v", res.PageResponses)
+	}
+	// Headers describe the whole call, like the body's billing.
+	if res.Metadata.BillingCreditCost != 3.5 || res.Metadata.BillingCostCredits != 3.5 || res.Billing.CreditCost != 3.5 || res.Metadata.ContentStatusCode != 200 {
+		t.Fatalf("unexpected metadata %+v, billing %+v", res.Metadata, res.Billing)
+	}
+}
+
+func TestServer_DelayHonoursCancellation(t *testing.T) {
+	srv := phantomtest.NewServer()
+	defer srv.Close()
+
+	srv.Handle(phantomtest.Match{}, phantomtest.HTML("slow").WithDelay(5*time.Second))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := srv.Client("test-key").DoPageContext(ctx, &phantomjscloud.PageRequest{URL: "https://example.com"})
+	if err == nil {
+		t.Fatal("expected deadline error, got nil")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("request was not cancelled promptly")
+	}
+}
+
+func TestServer_APIErrorDecodesToTypedError(t *testing.T) {
+	srv := phantomtest.NewServer()
+	defer srv.Close()
+
+	srv.Handle(phantomtest.Match{}, phantomtest.APIError(402, "OutOfCreditsException", "account is out of credits"))
+
+	_, err := srv.Client("test-key").DoPage(&phantomjscloud.PageRequest{URL: "https://example.com"})
+	if !errors.Is(err, phantomjscloud.ErrOutOfCredits) {
+		t.Fatalf("expected ErrOutOfCredits, got %v", err)
+	}
+	var apiErr *phantomjscloud.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != "OutOfCreditsException" {
+		t.Fatalf("expected APIError with code, got %#v", err)
+	}
+}
@@ -21,6 +21,24 @@ func normalizeUserRequestForAPI(req *UserRequest) *UserRequest {
 	return &out
 }
 
+// ProxyLabel returns the API string form of a page proxy value, such as
+// "anon-us", with custom proxy credentials removed. Use it for logs and metric labels.
+func ProxyLabel(v interface{}) string {
+	s, ok := normalizePageProxyForAPI(v).(string)
+	if !ok {
+		if v == nil {
+			return ""
+		}
+		return "other"
+	}
+	if strings.HasPrefix(s, "custom-") {
+		if i := strings.IndexByte(s, ':'); i >= 0 {
+			s = s[:i]
+		}
+	}
+	return s
+}
+
 func normalizePageProxyForAPI(v interface{}) interface{} {
 	switch p := v.(type) {
 	case ProxyBuiltin:
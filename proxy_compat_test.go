@@ -0,0 +1,23 @@
+package phantomjscloud
+
+import "testing"
+
+func TestProxyLabel(t *testing.T) {
+	cases := []struct {
+		in   interface{}
+		want string
+	}{
+		{nil, ""},
+		{ProxyAnonUS, "anon-us"},
+		{&ProxyBuiltin{Location: "de", Type: "geo"}, "geo-de"},
+		{ProxyOptions{Geolocation: "fr"}, "geo-fr"},
+		{ProxyOptions{Custom: &ProxyCustom{Host: "1.2.3.4:8080", Auth: "user:pass"}}, "custom-1.2.3.4"},
+		{"custom-host.example:user:pass", "custom-host.example"},
+		{42, "other"},
+	}
+	for _, tc := range cases {
+		if got := ProxyLabel(tc.in); got != tc.want {
+			t.Errorf("ProxyLabel(%#v) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package phantomjscloud
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WithRateLimit caps the rate of API calls made by the Client with a token
+// bucket refilled at perSecond tokens per second and holding at most burst
+// tokens. Every HTTP attempt through DoContext, including retries, takes a
+// token, so helpers in ext/scraper and ad-hoc DoPage calls share one limit.
+func WithRateLimit(perSecond float64, burst int) ClientOption {
+	return func(c *Client) {
+		l := c.ensureLimiter()
+		if burst < 1 {
+			burst = 1
+		}
+		l.rate = perSecond
+		l.burst = float64(burst)
+		l.tokens = float64(burst)
+		l.last = l.now()
+	}
+}
+
+// WithMaxConcurrency caps the number of in-flight API calls across the Client.
+func WithMaxConcurrency(n int) ClientOption {
+	return func(c *Client) {
+		if n < 1 {
+			n = 1
+		}
+		c.ensureLimiter().slots = make(chan struct{}, n)
+	}
+}
+
+// LimiterStats reports how often calls had to wait for the client-wide
+// rate limiter or concurrency cap.
+type LimiterStats struct {
+	// Waits is the number of calls that had to wait before being sent.
+	Waits int64
+	// WaitTime is the total time spent waiting.
+	WaitTime time.Duration
+	// InFlight is the number of calls currently holding a concurrency slot.
+	InFlight int
+}
+
+// LimiterStats returns a snapshot of the client-wide limiter counters.
+// It returns the zero value when neither WithRateLimit nor WithMaxConcurrency was used.
+func (c *Client) LimiterStats() LimiterStats {
+	l := c.limiter
+	if l == nil {
+		return LimiterStats{}
+	}
+	return LimiterStats{
+		Waits:    atomic.LoadInt64(&l.waits),
+		WaitTime: time.Duration(atomic.LoadInt64(&l.waitNanos)),
+		InFlight: len(l.slots),
+	}
+}
+
+func (c *Client) ensureLimiter() *limiter {
+	if c.limiter == nil {
+		c.limiter = &limiter{now: time.Now}
+	}
+	return c.limiter
+}
+
+// limiter combines a token bucket with an in-flight semaphore.
+// A zero rate disables the bucket; a nil slots channel disables the cap.
+type limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time // refills the bucket; replaced in tests
+
+	slots chan struct{}
+
+	waits     int64
+	waitNanos int64
+}
+
+// acquire blocks until a concurrency slot and a rate token are available or ctx is done.
+// The returned release func must be called once the call has finished.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	start := time.Now()
+	waited := false
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			waited = true
+			select {
+			case l.slots <- struct{}{}:
+			case <-ctx.Done():
+				l.recordWait(start)
+				return nil, ctx.Err()
+			}
+		}
+	}
+	release := func() {
+		if l.slots != nil {
+			<-l.slots
+		}
+	}
+
+	if d := l.reserve(); d > 0 {
+		waited = true
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			l.unreserve()
+			release()
+			l.recordWait(start)
+			return nil, ctx.Err()
+		}
+	}
+
+	if waited {
+		l.recordWait(start)
+	}
+	return release, nil
+}
+
+// reserve takes a token, possibly going into debt, and returns how long the
+// caller must wait before the token is actually available.
+func (l *limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return 0
+	}
+
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// unreserve returns a token whose wait was abandoned.
+func (l *limiter) unreserve() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate > 0 {
+		l.tokens++
+	}
+}
+
+func (l *limiter) recordWait(start time.Time) {
+	atomic.AddInt64(&l.waits, 1)
+	atomic.AddInt64(&l.waitNanos, int64(time.Since(start)))
+}
@@ -0,0 +1,133 @@
+package phantomjscloud
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithMaxConcurrency_CapsInFlightCalls(t *testing.T) {
+	var current, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&current, -1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","pageResponses":[{"statusCode":200}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithMaxConcurrency(2))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); err != nil {
+				t.Errorf("DoPage failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&peak); got > 2 {
+		t.Fatalf("expected at most 2 in-flight calls, saw %d", got)
+	}
+	stats := client.LimiterStats()
+	if stats.Waits == 0 || stats.WaitTime <= 0 {
+		t.Fatalf("expected recorded waits, got %+v", stats)
+	}
+	if stats.InFlight != 0 {
+		t.Fatalf("expected no in-flight calls after completion, got %d", stats.InFlight)
+	}
+}
+
+func TestWithRateLimit_SpacesCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","pageResponses":[{"statusCode":200}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRateLimit(100, 1))
+	// A stopped clock never refills the bucket, however slow the calls are.
+	now := client.limiter.last
+	client.limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); err != nil {
+			t.Fatalf("DoPage failed: %v", err)
+		}
+	}
+	if got := client.LimiterStats().Waits; got != 2 {
+		t.Fatalf("expected 2 waits, got %d", got)
+	}
+}
+
+func TestLimiter_ReserveRefillsFromClock(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := &limiter{now: func() time.Time { return now }, rate: 20, burst: 2, tokens: 2, last: now}
+
+	// The burst is free, then each token is 50ms further out.
+	for i, want := range []time.Duration{0, 0, 50 * time.Millisecond, 100 * time.Millisecond} {
+		if got := l.reserve(); got != want {
+			t.Fatalf("reserve %d = %v, want %v", i, got, want)
+		}
+	}
+	now = now.Add(time.Second)
+	if got := l.reserve(); got != 0 {
+		t.Fatalf("expected the bucket to refill, got a %v wait", got)
+	}
+	now = now.Add(time.Hour)
+	l.reserve()
+	l.reserve()
+	if got := l.reserve(); got != 50*time.Millisecond {
+		t.Fatalf("expected the refill to stop at the burst, got a %v wait", got)
+	}
+}
+
+func TestLimiter_WaitRespectsContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","pageResponses":[{"statusCode":200}]}`))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithMaxConcurrency(1))
+
+	go client.DoPage(&PageRequest{URL: "https://example.com/slow"}) //nolint:errcheck
+	for client.LimiterStats().InFlight == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err := client.DoPageContext(ctx, &PageRequest{URL: "https://example.com"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded while waiting for a slot, got %v", err)
+	}
+}
+
+func TestLimiter_NilIsNoop(t *testing.T) {
+	var l *limiter
+	release, err := l.acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	release()
+}
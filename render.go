@@ -0,0 +1,110 @@
+package phantomjscloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register the decoder for RenderResult.Image
+	_ "image/png"  // register the decoder for RenderResult.Image
+)
+
+var (
+	// ErrNoPageResponse is returned when the API answered without a page response.
+	ErrNoPageResponse = errors.New("phantomjscloud: no page response returned")
+	// ErrNoAutomationResult is returned when an automation render has no automationResult.
+	ErrNoAutomationResult = errors.New("phantomjscloud: automation result was omitted or empty in response")
+)
+
+// RenderResult is one rendered page together with the render type it was requested as.
+type RenderResult struct {
+	// RenderType is the requested render type, with "" reported as "html".
+	RenderType string
+	// Page is the raw page response.
+	Page PageResponse
+	// Response is the full API response, including metadata.
+	Response *UserResponseWithMeta
+}
+
+// Render renders a single page and returns a typed result. It honours ctx and
+// every client option, like DoPageContext.
+//
+//	res, err := client.Render(ctx, &phantomjscloud.PageRequest{URL: u, RenderType: "png"})
+//	img, err := res.Image()
+func (c *Client) Render(ctx context.Context, req *PageRequest) (*RenderResult, error) {
+	res, err := c.DoPageContext(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.PageResponses) == 0 {
+		return nil, ErrNoPageResponse
+	}
+	return &RenderResult{
+		RenderType: ledgerRenderType(req.RenderType),
+		Page:       res.PageResponses[0],
+		Response:   res,
+	}, nil
+}
+
+// IsBinary reports whether the render type returns base64-encoded content:
+// png, jpeg, jpg and pdf.
+func (r *RenderResult) IsBinary() bool {
+	switch r.RenderType {
+	case "png", "jpeg", "jpg", "pdf":
+		return true
+	}
+	return false
+}
+
+// Bytes returns the page content, base64-decoded for binary render types.
+func (r *RenderResult) Bytes() ([]byte, error) {
+	if !r.IsBinary() {
+		return []byte(r.Page.Content), nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(r.Page.Content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64 content: %w", err)
+	}
+	return decoded, nil
+}
+
+// Text returns the page content of a textual render type such as html or plainText.
+func (r *RenderResult) Text() (string, error) {
+	if r.IsBinary() {
+		return "", fmt.Errorf("phantomjscloud: %s render has no text content", r.RenderType)
+	}
+	return r.Page.Content, nil
+}
+
+// Image decodes a png, jpeg or jpg render.
+func (r *RenderResult) Image() (image.Image, error) {
+	if r.RenderType == "pdf" || !r.IsBinary() {
+		return nil, fmt.Errorf("phantomjscloud: %s render is not an image", r.RenderType)
+	}
+	b, err := r.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// DecodeAutomation unmarshals the page's automationResult into the value
+// pointed to by into. It reads the bytes the API sent, as
+// DecodeAutomationResult does, so large integers keep their precision.
+func (r *RenderResult) DecodeAutomation(into any) error {
+	raw, err := DecodeAutomationResult[json.RawMessage](&r.Page)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, into); err != nil {
+		return fmt.Errorf("failed to decode automation result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package phantomjscloud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newRenderServer(t *testing.T, contentType string, body []byte) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return NewClient("test-key", WithEndpoint(server.URL+"/"))
+}
+
+func TestRender_Image(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(1, 1, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	client := newRenderServer(t, "image/png", buf.Bytes())
+
+	res, err := client.Render(context.Background(), &PageRequest{URL: "https://example.com", RenderType: "png"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	b, err := res.Bytes()
+	if err != nil || !bytes.Equal(b, buf.Bytes()) {
+		t.Fatalf("Bytes() = %d bytes, %v", len(b), err)
+	}
+	img, err := res.Image()
+	if err != nil {
+		t.Fatalf("Image: %v", err)
+	}
+	if img.Bounds().Dx() != 3 || img.Bounds().Dy() != 2 {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	if r, _, _, _ := img.At(1, 1).RGBA(); r != 0xffff {
+		t.Fatalf("unexpected pixel %v", img.At(1, 1))
+	}
+	if _, err := res.Text(); err == nil {
+		t.Fatal("expected Text to fail for a png render")
+	}
+}
+
+func TestRender_TextAndPDF(t *testing.T) {
+	client := newRenderServer(t, "application/json", []byte(`{"pageResponses":[{"content":"<p>hi</p>","statusCode":200}]}`))
+	res, err := client.Render(context.Background(), &PageRequest{URL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if res.RenderType != "html" || res.Page.StatusCode != 200 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if text, err := res.Text(); err != nil || text != "<p>hi</p>" {
+		t.Fatalf("Text() = %q, %v", text, err)
+	}
+	if _, err := res.Image(); err == nil {
+		t.Fatal("expected Image to fail for an html render")
+	}
+
+	pdf := newRenderServer(t, "application/pdf", []byte("%PDF-1.4"))
+	b, err := pdf.FetchPDF("https://example.com", nil)
+	if err != nil || string(b) != "%PDF-1.4" {
+		t.Fatalf("FetchPDF = %q, %v", b, err)
+	}
+}
+
+func TestRender_DecodeAutomation(t *testing.T) {
+	client := newRenderServer(t, "application/json", []byte(`{"pageResponses":[{"automationResult":{"title":"Example","count":2}}]}`))
+	res, err := client.Render(context.Background(), &PageRequest{URL: "https://example.com", RenderType: "automation"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	var out struct {
+		Title string `json:"title"`
+		Count int    `json:"count"`
+	}
+	if err := res.DecodeAutomation(&out); err != nil {
+		t.Fatalf("DecodeAutomation: %v", err)
+	}
+	if out.Title != "Example" || out.Count != 2 {
+		t.Fatalf("unexpected result %+v", out)
+	}
+
+	client = newRenderServer(t, "application/json", []byte(`{"pageResponses":[{"automationResult":{"id":9007199254740993}}]}`))
+	if res, err = client.Render(context.Background(), &PageRequest{URL: "https://example.com", RenderType: "automation"}); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	var big struct {
+		ID int64 `json:"id"`
+	}
+	if err := res.DecodeAutomation(&big); err != nil || big.ID != 9007199254740993 {
+		t.Fatalf("large integer = %d, %v", big.ID, err)
+	}
+
+	res.Page.AutomationResult = nil
+	if err := res.DecodeAutomation(&out); !errors.Is(err, ErrNoAutomationResult) {
+		t.Fatalf("expected ErrNoAutomationResult, got %v", err)
+	}
+}
+
+func TestRender_NoPageResponse(t *testing.T) {
+	client := newRenderServer(t, "application/json", []byte(`{"pageResponses":[]}`))
+	if _, err := client.Render(context.Background(), &PageRequest{URL: "https://example.com"}); !errors.Is(err, ErrNoPageResponse) {
+		t.Fatalf("expected ErrNoPageResponse, got %v", err)
+	}
+	if _, err := client.FetchPlainText("https://example.com"); !errors.Is(err, ErrNoPageResponse) {
+		t.Fatalf("expected FetchPlainText to return ErrNoPageResponse, got %v", err)
+	}
+}
+
+func TestRender_HonoursContext(t *testing.T) {
+	client := newRenderServer(t, "application/json", []byte(`{"pageResponses":[{}]}`))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Render(ctx, &PageRequest{URL: "https://example.com"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+package phantomjscloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resource is one network request made while rendering a page, decoded from
+// PageResponse.Resources.
+type Resource struct {
+	ID string
+	// State is the resource's final state as reported by the API, e.g.
+	// "complete", "failed", "aborted" or "late".
+	State    string
+	Request  ResourceRequest
+	Response *ResourceResponse
+	Timing   ResourceTiming
+	// Size is the number of bytes received, or -1 when unknown.
+	Size int64
+	// FailureReason describes why the request failed, when it did.
+	FailureReason string
+}
+
+// Failed reports whether the request failed or never received a response.
+func (r *Resource) Failed() bool {
+	return r.FailureReason != "" || r.State == "failed" || r.State == "aborted" || r.Response == nil
+}
+
+// ResourceRequest is the outgoing side of a Resource.
+type ResourceRequest struct {
+	URL      string
+	Method   string
+	Headers  []Header
+	PostData string
+}
+
+// ResourceResponse is the incoming side of a Resource.
+type ResourceResponse struct {
+	Status      int
+	StatusText  string
+	Headers     []Header
+	ContentType string
+	// Body is the recorded response body, present when RequestSettings.RecordResourceBody matched.
+	Body string
+	// BodyEncoding is "base64" when Body is base64-encoded.
+	BodyEncoding string
+}
+
+// ResourceTiming holds when a Resource started and finished.
+type ResourceTiming struct {
+	Start    time.Time
+	End      time.Time
+	Duration time.Duration
+}
+
+// Header is one HTTP header; headers keep their order and may repeat.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// NetworkResources decodes Resources into typed values, in start-time order.
+// Unknown fields are ignored, and missing sizes are reported as -1.
+func (p *PageResponse) NetworkResources() ([]Resource, error) {
+	out := make([]Resource, 0, len(p.Resources))
+	for i, raw := range p.Resources {
+		b, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("resources[%d]: %w", i, err)
+		}
+		var rr rawResource
+		if err := json.Unmarshal(b, &rr); err != nil {
+			return nil, fmt.Errorf("resources[%d]: %w", i, err)
+		}
+		res := rr.resource()
+		if res.ID == "" {
+			res.ID = strconv.Itoa(i)
+		}
+		out = append(out, res)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Timing.Start.Before(out[j].Timing.Start) })
+	return out, nil
+}
+
+// rawResource accepts the field spellings seen in PhantomJsCloud resource
+// entries: request and response details either nested or flat, headers as
+// objects or name/value lists, and times as RFC 3339 strings or epoch
+// milliseconds.
+type rawResource struct {
+	ID            flexString      `json:"id"`
+	State         string          `json:"state"`
+	URL           string          `json:"url"`
+	Method        string          `json:"method"`
+	StatusCode    int             `json:"statusCode"`
+	Headers       json.RawMessage `json:"headers"`
+	PostData      string          `json:"postData"`
+	Request       *rawRequest     `json:"request"`
+	Response      *rawResponse    `json:"response"`
+	StartTime     flexTime        `json:"startTime"`
+	EndTime       flexTime        `json:"endTime"`
+	Duration      *float64        `json:"duration"`
+	Size          *int64          `json:"size"`
+	BodySize      *int64          `json:"bodySize"`
+	BodyBase64    string          `json:"bodyBase64"`
+	ErrorString   string          `json:"errorString"`
+	ErrorText     string          `json:"errorText"`
+	FailureReason string          `json:"failureReason"`
+	Error         json.RawMessage `json:"error"`
+}
+
+type rawRequest struct {
+	URL      string          `json:"url"`
+	Method   string          `json:"method"`
+	Headers  json.RawMessage `json:"headers"`
+	PostData string          `json:"postData"`
+	Time     flexTime        `json:"time"`
+}
+
+type rawResponse struct {
+	Status       int             `json:"status"`
+	StatusCode   int             `json:"statusCode"`
+	StatusText   string          `json:"statusText"`
+	Headers      json.RawMessage `json:"headers"`
+	ContentType  string          `json:"contentType"`
+	MimeType     string          `json:"mimeType"`
+	BodySize     *int64          `json:"bodySize"`
+	Body         string          `json:"body"`
+	BodyBase64   string          `json:"bodyBase64"`
+	BodyEncoding string          `json:"bodyEncoding"`
+	Time         flexTime        `json:"time"`
+}
+
+func (rr *rawResource) resource() Resource {
+	res := Resource{ID: string(rr.ID), State: rr.State, Size: -1}
+
+	res.Request = ResourceRequest{URL: rr.URL, Method: rr.Method, Headers: parseHeaders(rr.Headers), PostData: rr.PostData}
+	start := time.Time(rr.StartTime)
+	if req := rr.Request; req != nil {
+		res.Request = ResourceRequest{
+			URL:      firstNonEmpty(req.URL, rr.URL),
+			Method:   firstNonEmpty(req.Method, rr.Method),
+			Headers:  parseHeaders(req.Headers),
+			PostData: firstNonEmpty(req.PostData, rr.PostData),
+		}
+		if start.IsZero() {
+			start = time.Time(req.Time)
+		}
+	}
+	if res.Request.Method == "" {
+		res.Request.Method = "GET"
+	}
+
+	end := time.Time(rr.EndTime)
+	if resp := rr.Response; resp != nil {
+		r := &ResourceResponse{
+			Status:       resp.Status,
+			StatusText:   resp.StatusText,
+			Headers:      parseHeaders(resp.Headers),
+			ContentType:  firstNonEmpty(resp.ContentType, resp.MimeType),
+			Body:         resp.Body,
+			BodyEncoding: resp.BodyEncoding,
+		}
+		if r.Status == 0 {
+			r.Status = resp.StatusCode
+		}
+		if resp.BodyBase64 != "" {
+			r.Body, r.BodyEncoding = resp.BodyBase64, "base64"
+		}
+		if resp.BodySize != nil {
+			res.Size = *resp.BodySize
+		}
+		if end.IsZero() {
+			end = time.Time(resp.Time)
+		}
+		res.Response = r
+	} else if rr.StatusCode != 0 {
+		res.Response = &ResourceResponse{Status: rr.StatusCode}
+	}
+	if res.Response != nil {
+		if res.Response.ContentType == "" {
+			res.Response.ContentType = headerValue(res.Response.Headers, "Content-Type")
+		}
+		if rr.BodyBase64 != "" && res.Response.Body == "" {
+			res.Response.Body, res.Response.BodyEncoding = rr.BodyBase64, "base64"
+		}
+	}
+
+	switch {
+	case rr.BodySize != nil:
+		res.Size = *rr.BodySize
+	case rr.Size != nil:
+		res.Size = *rr.Size
+	}
+
+	res.Timing = ResourceTiming{Start: start, End: end}
+	switch {
+	case rr.Duration != nil:
+		res.Timing.Duration = time.Duration(*rr.Duration * float64(time.Millisecond))
+	case !start.IsZero() && !end.IsZero() && end.After(start):
+		res.Timing.Duration = end.Sub(start)
+	}
+	if res.Timing.End.IsZero() && !start.IsZero() && res.Timing.Duration > 0 {
+		res.Timing.End = start.Add(res.Timing.Duration)
+	}
+
+	res.FailureReason = firstNonEmpty(rr.FailureReason, rr.ErrorString, rr.ErrorText, rawErrorText(rr.Error))
+	return res
+}
+
+// parseHeaders accepts {"Name": "value"} objects and [{"name": ..., "value": ...}] lists.
+func parseHeaders(raw json.RawMessage) []Header {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var list []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &list); err == nil {
+		out := make([]Header, 0, len(list))
+		for _, h := range list {
+			out = append(out, Header{Name: h.Name, Value: h.Value})
+		}
+		return out
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil
+	}
+	out := make([]Header, 0, len(obj))
+	for name, v := range obj {
+		out = append(out, Header{Name: name, Value: fmt.Sprint(v)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func headerValue(headers []Header, name string) string {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// rawErrorText reads an error given as a string or as an object with a message.
+func rawErrorText(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var obj struct {
+		Message     string `json:"message"`
+		ErrorString string `json:"errorString"`
+	}
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		return firstNonEmpty(obj.Message, obj.ErrorString)
+	}
+	return string(raw)
+}
+
+// flexString decodes JSON strings and numbers.
+type flexString string
+
+func (s *flexString) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		*s = flexString(str)
+		return nil
+	}
+	*s = flexString(strings.TrimSpace(string(b)))
+	return nil
+}
+
+// flexTime decodes RFC 3339 strings and epoch milliseconds. Unparseable
+// values are left zero.
+type flexTime time.Time
+
+func (t *flexTime) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		if parsed, err := time.Parse(time.RFC3339Nano, str); err == nil {
+			*t = flexTime(parsed)
+		}
+		return nil
+	}
+	var ms float64
+	if err := json.Unmarshal(b, &ms); err == nil && ms > 0 {
+		*t = flexTime(time.UnixMilli(int64(ms)).UTC())
+	}
+	return nil
+}
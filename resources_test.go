@@ -0,0 +1,65 @@
+package phantomjscloud
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNetworkResources_DecodesNestedAndFlatEntries(t *testing.T) {
+	var page PageResponse
+	err := json.Unmarshal([]byte(`{"resources":[
+		{"id":2,"state":"failed","url":"https://cdn.example.com/app.js","method":"GET",
+		 "startTime":1700000000500,"errorString":"net::ERR_BLOCKED_BY_CLIENT"},
+		{"id":"1","state":"complete",
+		 "request":{"url":"https://example.com/?q=1","method":"POST","headers":{"Content-Type":"application/x-www-form-urlencoded"},"postData":"a=b"},
+		 "response":{"status":200,"statusText":"OK","headers":[{"name":"Content-Type","value":"text/html"}],"bodySize":512,"body":"PGh0bWw+","bodyEncoding":"base64"},
+		 "startTime":"2023-11-14T22:13:20Z","endTime":"2023-11-14T22:13:20.250Z"}
+	]}`), &page)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	resources, err := page.NetworkResources()
+	if err != nil {
+		t.Fatalf("NetworkResources: %v", err)
+	}
+	if len(resources) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(resources))
+	}
+
+	doc := resources[0]
+	if doc.ID != "1" || doc.Request.Method != "POST" || doc.Request.PostData != "a=b" {
+		t.Fatalf("unexpected request %+v", doc)
+	}
+	if doc.Response == nil || doc.Response.Status != 200 || doc.Response.ContentType != "text/html" {
+		t.Fatalf("unexpected response %+v", doc.Response)
+	}
+	if doc.Response.Body != "PGh0bWw+" || doc.Response.BodyEncoding != "base64" || doc.Size != 512 {
+		t.Fatalf("unexpected body %+v size %d", doc.Response, doc.Size)
+	}
+	if doc.Timing.Duration != 250*time.Millisecond {
+		t.Fatalf("unexpected timing %+v", doc.Timing)
+	}
+	if doc.Failed() {
+		t.Fatal("completed resource reported as failed")
+	}
+
+	js := resources[1]
+	if js.ID != "2" || js.Request.URL != "https://cdn.example.com/app.js" || js.Size != -1 {
+		t.Fatalf("unexpected flat resource %+v", js)
+	}
+	if !js.Failed() || js.FailureReason != "net::ERR_BLOCKED_BY_CLIENT" {
+		t.Fatalf("expected failure reason, got %+v", js)
+	}
+	if want := time.UnixMilli(1700000000500).UTC(); !js.Timing.Start.Equal(want) {
+		t.Fatalf("start = %v, want %v", js.Timing.Start, want)
+	}
+}
+
+func TestNetworkResources_Empty(t *testing.T) {
+	resources, err := (&PageResponse{}).NetworkResources()
+	if err != nil || len(resources) != 0 {
+		t.Fatalf("expected no resources, got %v, %v", resources, err)
+	}
+}
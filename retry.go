@@ -0,0 +1,363 @@
+package phantomjscloud
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryConfig defines the strategy for automatic retries on transient errors.
+//
+// The top-level schedule applies to every retryable failure class unless a
+// per-class RetryPolicy overrides it. Blocked responses are only retried when
+// both Blocked and IsBlocked are set.
+type RetryConfig struct {
+	MaxRetries      int
+	InitialInterval time.Duration
+	Multiplier      float64
+	MaxInterval     time.Duration
+
+	// Jitter randomizes backoff intervals so concurrent workers don't retry in lockstep.
+	Jitter JitterStrategy
+	// IgnoreRetryAfter disables waiting for the server's Retry-After header on 429/503.
+	IgnoreRetryAfter bool
+	// MaxRetryAfter is the longest Retry-After the client will wait. A longer
+	// one ends the call with the error instead of stalling it. Zero means
+	// DefaultMaxRetryAfter.
+	MaxRetryAfter time.Duration
+
+	// Network overrides the schedule for transport failures (timeouts, refused or reset connections).
+	Network *RetryPolicy
+	// RateLimited overrides the schedule for HTTP 429 responses.
+	RateLimited *RetryPolicy
+	// ServerError overrides the schedule for 502/503/504 responses and render timeouts.
+	ServerError *RetryPolicy
+	// Blocked enables retries of successful responses that IsBlocked reports as blocked.
+	Blocked *RetryPolicy
+	// IsBlocked classifies a response as blocked content, e.g. blockpolicy.LooksBlocked.
+	IsBlocked func(*UserResponseWithMeta) bool
+
+	// Budget caps retries across every request made by the Client. Nil means unlimited.
+	Budget *RetryBudget
+
+	// OnAttempt is called after every attempt with its outcome.
+	OnAttempt func(RetryAttempt)
+}
+
+// RetryPolicy is the backoff schedule for a single failure class.
+type RetryPolicy struct {
+	MaxRetries      int
+	InitialInterval time.Duration
+	Multiplier      float64
+	MaxInterval     time.Duration
+}
+
+// JitterStrategy selects how backoff intervals are randomized.
+type JitterStrategy int
+
+const (
+	// JitterNone uses the plain exponential schedule.
+	JitterNone JitterStrategy = iota
+	// JitterFull sleeps a random duration between zero and the exponential interval.
+	JitterFull
+	// JitterDecorrelated sleeps a random duration between InitialInterval and three
+	// times the previous sleep, capped at MaxInterval.
+	JitterDecorrelated
+)
+
+// RetryClass identifies why an attempt failed, and so which RetryPolicy applies.
+type RetryClass string
+
+const (
+	RetryClassNone        RetryClass = ""
+	RetryClassNetwork     RetryClass = "network"
+	RetryClassRateLimited RetryClass = "rateLimited"
+	RetryClassServerError RetryClass = "serverError"
+	RetryClassBlocked     RetryClass = "blocked"
+)
+
+// RetryAttempt describes the outcome of one attempt made by DoContext.
+type RetryAttempt struct {
+	// Attempt is the zero-based attempt index.
+	Attempt int
+	// Class is the failure class, or RetryClassNone on success or a non-retryable error.
+	Class RetryClass
+	// Err is the attempt error, if any.
+	Err error
+	// Response is the decoded response, if any.
+	Response *UserResponseWithMeta
+	// Duration is how long the attempt took.
+	Duration time.Duration
+	// WillRetry reports whether another attempt follows.
+	WillRetry bool
+	// Delay is the wait before the next attempt when WillRetry is true.
+	Delay time.Duration
+	// BudgetExhausted reports that a retry was denied by the client's RetryBudget.
+	BudgetExhausted bool
+}
+
+// RetryBudget limits retries as a fraction of the requests a Client sends, so
+// a burst of failures can't multiply credit spend. Each request deposits Ratio
+// into the budget and each retry withdraws one; the balance starts at Burst and
+// never exceeds it.
+type RetryBudget struct {
+	// Ratio is the retry allowance earned per request; 0.1 permits about one retry per ten requests.
+	Ratio float64
+	// Burst is the starting balance and the cap on saved-up retries.
+	Burst int
+}
+
+// DefaultMaxRetryAfter is the longest Retry-After honoured when
+// RetryConfig.MaxRetryAfter is unset.
+const DefaultMaxRetryAfter = time.Minute
+
+// DefaultRetryConfig provides a sensible default for most use cases.
+var DefaultRetryConfig = RetryConfig{
+	MaxRetries:      3,
+	InitialInterval: 1 * time.Second,
+	Multiplier:      2.0,
+	MaxInterval:     10 * time.Second,
+	Jitter:          JitterFull,
+}
+
+// retryBudget is the per-Client runtime state of a RetryBudget.
+type retryBudget struct {
+	mu      sync.Mutex
+	ratio   float64
+	burst   float64
+	balance float64
+}
+
+func newRetryBudget(b *RetryBudget) *retryBudget {
+	if b == nil {
+		return nil
+	}
+	return &retryBudget{
+		ratio:   b.Ratio,
+		burst:   float64(b.Burst),
+		balance: float64(b.Burst),
+	}
+}
+
+func (b *retryBudget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.balance = math.Min(b.burst, b.balance+b.ratio)
+}
+
+func (b *retryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.balance < 1 {
+		return false
+	}
+	b.balance--
+	return true
+}
+
+// policyFor returns the schedule for class, or false when class is not retried.
+func (cfg *RetryConfig) policyFor(class RetryClass) (RetryPolicy, bool) {
+	base := RetryPolicy{
+		MaxRetries:      cfg.MaxRetries,
+		InitialInterval: cfg.InitialInterval,
+		Multiplier:      cfg.Multiplier,
+		MaxInterval:     cfg.MaxInterval,
+	}
+	var override *RetryPolicy
+	switch class {
+	case RetryClassNetwork:
+		override = cfg.Network
+	case RetryClassRateLimited:
+		override = cfg.RateLimited
+	case RetryClassServerError:
+		override = cfg.ServerError
+	case RetryClassBlocked:
+		if cfg.Blocked == nil || cfg.IsBlocked == nil {
+			return RetryPolicy{}, false
+		}
+		override = cfg.Blocked
+	default:
+		return RetryPolicy{}, false
+	}
+	if override != nil {
+		return *override, true
+	}
+	return base, true
+}
+
+// classify maps an attempt outcome onto a RetryClass.
+func (cfg *RetryConfig) classify(res *UserResponseWithMeta, err error) RetryClass {
+	if err == nil {
+		if cfg.IsBlocked != nil && res != nil && cfg.IsBlocked(res) {
+			return RetryClassBlocked
+		}
+		return RetryClassNone
+	}
+	return classifyError(err)
+}
+
+func classifyError(err error) RetryClass {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case errors.Is(apiErr, ErrRateLimited):
+			return RetryClassRateLimited
+		case errors.Is(apiErr, ErrRenderTimeout), apiErr.StatusCode == 502, apiErr.StatusCode == 503:
+			return RetryClassServerError
+		}
+		return RetryClassNone
+	}
+	if isNetworkError(err) {
+		return RetryClassNetwork
+	}
+	return RetryClassNone
+}
+
+// retryState tracks the backoff progression of one failure class within a call.
+type retryState struct {
+	retries   int
+	prevSleep time.Duration
+}
+
+func (p RetryPolicy) delay(st *retryState, jitter JitterStrategy) time.Duration {
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = 1
+	}
+	backoff := time.Duration(float64(p.InitialInterval) * math.Pow(mult, float64(st.retries)))
+	if p.MaxInterval > 0 && (backoff > p.MaxInterval || backoff < 0) {
+		backoff = p.MaxInterval
+	}
+
+	var d time.Duration
+	switch jitter {
+	case JitterFull:
+		d = randDuration(0, backoff)
+	case JitterDecorrelated:
+		prev := st.prevSleep
+		if prev < p.InitialInterval {
+			prev = p.InitialInterval
+		}
+		d = randDuration(p.InitialInterval, 3*prev)
+		if p.MaxInterval > 0 && d > p.MaxInterval {
+			d = p.MaxInterval
+		}
+	default:
+		d = backoff
+	}
+	st.prevSleep = d
+	return d
+}
+
+// randDuration returns a random duration in [lo, hi].
+func randDuration(lo, hi time.Duration) time.Duration {
+	if hi <= lo {
+		return lo
+	}
+	return lo + time.Duration(rand.Int63n(int64(hi-lo)+1))
+}
+
+// parseRetryAfter reads a Retry-After header given either as seconds or an HTTP date.
+func parseRetryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// doWithRetry runs doSingle under the client's RetryConfig.
+func (c *Client) doWithRetry(ctx context.Context, req *UserRequest) (*UserResponseWithMeta, error) {
+	cfg := c.retryConfig
+	c.retryBudget.deposit()
+
+	states := make(map[RetryClass]*retryState)
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		res, err := c.doAttempt(ctx, req, attempt)
+		outcome := RetryAttempt{
+			Attempt:  attempt,
+			Class:    cfg.classify(res, err),
+			Err:      err,
+			Response: res,
+			Duration: time.Since(start),
+		}
+
+		policy, retryable := cfg.policyFor(outcome.Class)
+		st := states[outcome.Class]
+		if st == nil {
+			st = &retryState{}
+			states[outcome.Class] = st
+		}
+		var retryAfter time.Duration
+		if !cfg.IgnoreRetryAfter {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				retryAfter = apiErr.RetryAfter
+			}
+		}
+		maxRetryAfter := cfg.MaxRetryAfter
+		if maxRetryAfter <= 0 {
+			maxRetryAfter = DefaultMaxRetryAfter
+		}
+		if retryable && retryAfter > maxRetryAfter {
+			// Waiting that long would stall the call; fail fast instead.
+			retryable = false
+		}
+		if retryable && st.retries < policy.MaxRetries && ctx.Err() == nil {
+			if c.retryBudget.withdraw() {
+				outcome.WillRetry = true
+				outcome.Delay = max(policy.delay(st, cfg.Jitter), retryAfter)
+				st.retries++
+			} else {
+				outcome.BudgetExhausted = true
+			}
+		}
+
+		if cfg.OnAttempt != nil {
+			cfg.OnAttempt(outcome)
+		}
+		if outcome.WillRetry {
+			c.Observer().OnRetryScheduled(ctx, RetryScheduledEvent{
+				Request: req,
+				Attempt: attempt,
+				Class:   outcome.Class,
+				Delay:   outcome.Delay,
+				Err:     err,
+			})
+		}
+		if !outcome.WillRetry {
+			return res, err
+		}
+
+		timer := time.NewTimer(outcome.Delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
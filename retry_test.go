@@ -0,0 +1,244 @@
+package phantomjscloud
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newSequenceServer(t *testing.T, statuses ...int) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1)) - 1
+		status := statuses[len(statuses)-1]
+		if n < len(statuses) {
+			status = statuses[n]
+		}
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "1")
+		}
+		if status >= 400 {
+			w.WriteHeader(status)
+			w.Write([]byte(`{"message":"failure"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","pageResponses":[{"statusCode":200,"content":"ok"}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func fastRetry() RetryConfig {
+	return RetryConfig{
+		MaxRetries:      3,
+		InitialInterval: time.Millisecond,
+		Multiplier:      2,
+		MaxInterval:     5 * time.Millisecond,
+	}
+}
+
+func TestDoContext_RetriesServerErrorThenSucceeds(t *testing.T) {
+	server, calls := newSequenceServer(t, 503, 502, 200)
+
+	var outcomes []RetryAttempt
+	cfg := fastRetry()
+	cfg.OnAttempt = func(a RetryAttempt) { outcomes = append(outcomes, a) }
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRetry(cfg))
+	res, err := client.DoPage(&PageRequest{URL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("expected success after retries, got %v", err)
+	}
+	if res.PageResponses[0].Content != "ok" {
+		t.Fatalf("unexpected content %q", res.PageResponses[0].Content)
+	}
+	if got := atomic.LoadInt32(calls); got != 3 {
+		t.Fatalf("expected 3 calls, got %d", got)
+	}
+	if len(outcomes) != 3 {
+		t.Fatalf("expected 3 attempt callbacks, got %d", len(outcomes))
+	}
+	if outcomes[0].Class != RetryClassServerError || !outcomes[0].WillRetry {
+		t.Fatalf("unexpected first outcome: %+v", outcomes[0])
+	}
+	if outcomes[2].Class != RetryClassNone || outcomes[2].WillRetry || outcomes[2].Err != nil {
+		t.Fatalf("unexpected final outcome: %+v", outcomes[2])
+	}
+}
+
+func TestDoContext_HonoursRetryAfter(t *testing.T) {
+	server, _ := newSequenceServer(t, 429, 200)
+
+	var firstDelay time.Duration
+	cfg := fastRetry()
+	cfg.OnAttempt = func(a RetryAttempt) {
+		if a.Attempt == 0 {
+			firstDelay = a.Delay
+		}
+	}
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRetry(cfg))
+	start := time.Now()
+	if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if firstDelay != time.Second {
+		t.Fatalf("expected Retry-After delay of 1s, got %v", firstDelay)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("expected to wait for Retry-After, returned after %v", elapsed)
+	}
+}
+
+func TestDoContext_FailsFastOnLongRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	cfg := fastRetry()
+	cfg.MaxRetryAfter = 30 * time.Second
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRetry(cfg))
+	start := time.Now()
+	_, err := client.DoPage(&PageRequest{URL: "https://example.com"})
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected no retry past MaxRetryAfter, got %d calls", got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected to fail fast, took %v", elapsed)
+	}
+}
+
+func TestDoContext_PerClassPolicyDisablesRateLimitRetries(t *testing.T) {
+	server, calls := newSequenceServer(t, 429, 200)
+
+	cfg := fastRetry()
+	cfg.RateLimited = &RetryPolicy{MaxRetries: 0}
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRetry(cfg))
+	if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); err == nil {
+		t.Fatal("expected 429 error without retry")
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Fatalf("expected a single call, got %d", got)
+	}
+}
+
+func TestDoContext_RetriesBlockedContent(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content := "ok"
+		if atomic.AddInt32(&calls, 1) == 1 {
+			content = "captcha"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","pageResponses":[{"statusCode":200,"content":"` + content + `"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := fastRetry()
+	cfg.Blocked = &RetryPolicy{MaxRetries: 2, InitialInterval: time.Millisecond}
+	cfg.IsBlocked = func(r *UserResponseWithMeta) bool { return r.PageResponses[0].Content == "captcha" }
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRetry(cfg))
+	res, err := client.DoPage(&PageRequest{URL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.PageResponses[0].Content != "ok" {
+		t.Fatalf("expected unblocked content, got %q", res.PageResponses[0].Content)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected 2 calls, got %d", got)
+	}
+}
+
+func TestDoContext_RetryBudgetIsSharedAcrossRequests(t *testing.T) {
+	server, calls := newSequenceServer(t, 503)
+
+	exhausted := 0
+	cfg := fastRetry()
+	cfg.Budget = &RetryBudget{Ratio: 0, Burst: 1}
+	cfg.OnAttempt = func(a RetryAttempt) {
+		if a.BudgetExhausted {
+			exhausted++
+		}
+	}
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRetry(cfg))
+	for i := 0; i < 2; i++ {
+		if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	// First request: initial call + one budgeted retry. Second request: no retries left.
+	if got := atomic.LoadInt32(calls); got != 3 {
+		t.Fatalf("expected 3 calls, got %d", got)
+	}
+	if exhausted != 2 {
+		t.Fatalf("expected 2 budget-exhausted outcomes, got %d", exhausted)
+	}
+}
+
+func TestDoContext_RetryWaitRespectsContext(t *testing.T) {
+	server, _ := newSequenceServer(t, 503)
+
+	cfg := fastRetry()
+	cfg.InitialInterval = time.Hour
+	cfg.MaxInterval = time.Hour
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRetry(cfg))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := client.DoPageContext(ctx, &PageRequest{URL: "https://example.com"}); err != context.DeadlineExceeded {
+		t.Fatalf("expected context deadline, got %v", err)
+	}
+}
+
+func TestRetryPolicy_DelayJitterBounds(t *testing.T) {
+	p := RetryPolicy{InitialInterval: 100 * time.Millisecond, Multiplier: 2, MaxInterval: time.Second}
+
+	st := &retryState{}
+	if d := p.delay(st, JitterNone); d != 100*time.Millisecond {
+		t.Fatalf("expected plain backoff 100ms, got %v", d)
+	}
+
+	for i := 0; i < 50; i++ {
+		st := &retryState{retries: 3}
+		if d := p.delay(st, JitterFull); d < 0 || d > 800*time.Millisecond {
+			t.Fatalf("full jitter out of range: %v", d)
+		}
+		st = &retryState{prevSleep: 500 * time.Millisecond}
+		if d := p.delay(st, JitterDecorrelated); d < 100*time.Millisecond || d > time.Second {
+			t.Fatalf("decorrelated jitter out of range: %v", d)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	h := http.Header{}
+	if d := parseRetryAfter(h); d != 0 {
+		t.Fatalf("expected 0 for missing header, got %v", d)
+	}
+	h.Set("Retry-After", "7")
+	if d := parseRetryAfter(h); d != 7*time.Second {
+		t.Fatalf("expected 7s, got %v", d)
+	}
+	h.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+	if d := parseRetryAfter(h); d < 50*time.Second || d > time.Minute {
+		t.Fatalf("expected about a minute, got %v", d)
+	}
+}
@@ -0,0 +1,120 @@
+package phantomjscloud
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EventKind classifies a PageEvent.
+type EventKind string
+
+const (
+	EventNavigation       EventKind = "navigation"
+	EventDOMReady         EventKind = "domReady"
+	EventLoad             EventKind = "load"
+	EventResourceRequest  EventKind = "resourceRequest"
+	EventResourceResponse EventKind = "resourceResponse"
+	EventConsole          EventKind = "console"
+	EventError            EventKind = "error"
+	EventDoneWhen         EventKind = "doneWhen"
+	EventOther            EventKind = "other"
+)
+
+// TimelineEvent is a PageEvent with its kind and common fields decoded.
+type TimelineEvent struct {
+	Kind EventKind
+	Key  string
+	// Elapsed is the time since the render started.
+	Elapsed time.Duration
+	// Time is the wall-clock time of the event, zero when not reported.
+	Time time.Time
+	// URL is set on navigation and resource events.
+	URL string
+	// Status is the HTTP status of resource responses.
+	Status int
+	// Message is the text of console, error and doneWhen events.
+	Message string
+	Value   interface{}
+}
+
+// Timeline classifies Events in elapsed-time order. Event keys are matched
+// case-insensitively, so both "resourceReceived" and "ResourceResponse"
+// map to EventResourceResponse; unrecognized keys map to EventOther.
+func (p *PageResponse) Timeline() []TimelineEvent {
+	out := make([]TimelineEvent, 0, len(p.Events))
+	for _, e := range p.Events {
+		ev := TimelineEvent{
+			Kind:    eventKind(e.Key),
+			Key:     e.Key,
+			Elapsed: time.Duration(e.ElapsedMs) * time.Millisecond,
+			Value:   e.Value,
+		}
+		if t, err := time.Parse(time.RFC3339Nano, e.Time); err == nil {
+			ev.Time = t
+		} else if e.TimeMs > 0 {
+			ev.Time = time.UnixMilli(int64(e.TimeMs)).UTC()
+		}
+
+		switch v := e.Value.(type) {
+		case string:
+			if ev.Kind == EventNavigation || ev.Kind == EventResourceRequest || ev.Kind == EventResourceResponse {
+				ev.URL = v
+			} else {
+				ev.Message = v
+			}
+		case map[string]interface{}:
+			ev.URL = valueString(v, "url", "targetUrl")
+			ev.Message = valueString(v, "message", "msg", "text", "errorString", "event", "selector")
+			ev.Status = valueInt(v, "status", "statusCode")
+		case nil:
+		default:
+			ev.Message = fmt.Sprint(v)
+		}
+		out = append(out, ev)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Elapsed < out[j].Elapsed })
+	return out
+}
+
+func eventKind(key string) EventKind {
+	k := strings.ToLower(key)
+	switch {
+	case strings.Contains(k, "donewhen"):
+		return EventDoneWhen
+	case strings.Contains(k, "resource") && (strings.Contains(k, "request") || strings.Contains(k, "start")):
+		return EventResourceRequest
+	case strings.Contains(k, "resource") && (strings.Contains(k, "receiv") || strings.Contains(k, "response") || strings.Contains(k, "finish")):
+		return EventResourceResponse
+	case strings.Contains(k, "console"):
+		return EventConsole
+	case strings.Contains(k, "error"):
+		return EventError
+	case strings.Contains(k, "domready") || strings.Contains(k, "domcontentloaded"):
+		return EventDOMReady
+	case strings.Contains(k, "loadfinish") || k == "load" || k == "onload":
+		return EventLoad
+	case strings.Contains(k, "navigat") || strings.Contains(k, "urlchange") || strings.Contains(k, "loadstart"):
+		return EventNavigation
+	}
+	return EventOther
+}
+
+func valueString(m map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := m[k].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func valueInt(m map[string]interface{}, keys ...string) int {
+	for _, k := range keys {
+		if f, ok := m[k].(float64); ok {
+			return int(f)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,46 @@
+package phantomjscloud
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimeline_ClassifiesEvents(t *testing.T) {
+	var page PageResponse
+	err := json.Unmarshal([]byte(`{"events":[
+		{"key":"consoleMessage","value":{"message":"hello"},"elapsedMs":40},
+		{"key":"pageNavigationRequested","value":{"url":"https://example.com/"},"elapsedMs":0,"time":"2024-01-02T03:04:05Z"},
+		{"key":"resourceRequested","value":{"url":"https://example.com/app.js"},"elapsedMs":10},
+		{"key":"resourceReceived","value":{"url":"https://example.com/app.js","status":200},"elapsedMs":30},
+		{"key":"domReady","elapsedMs":50},
+		{"key":"pageError","value":"ReferenceError: x is not defined","elapsedMs":60},
+		{"key":"pageLoadFinished","elapsedMs":80},
+		{"key":"doneWhen","value":0,"elapsedMs":90},
+		{"key":"somethingElse","elapsedMs":95}
+	]}`), &page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := page.Timeline()
+	want := []EventKind{EventNavigation, EventResourceRequest, EventResourceResponse, EventConsole,
+		EventDOMReady, EventError, EventLoad, EventDoneWhen, EventOther}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(events))
+	}
+	for i, kind := range want {
+		if events[i].Kind != kind {
+			t.Errorf("event %d (%s): kind = %s, want %s", i, events[i].Key, events[i].Kind, kind)
+		}
+	}
+	if events[0].URL != "https://example.com/" || events[0].Time.IsZero() {
+		t.Errorf("unexpected navigation event %+v", events[0])
+	}
+	if events[2].Status != 200 || events[2].Elapsed != 30*time.Millisecond {
+		t.Errorf("unexpected response event %+v", events[2])
+	}
+	if events[3].Message != "hello" || events[5].Message != "ReferenceError: x is not defined" {
+		t.Errorf("unexpected messages %q, %q", events[3].Message, events[5].Message)
+	}
+}
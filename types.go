@@ -2,7 +2,9 @@ package phantomjscloud
 
 import (
 	"encoding/json"
+	"reflect"
 	"strings"
+	"time"
 )
 
 // UserRequest represents the root POST payload (IUserRequest).
@@ -239,6 +241,11 @@ type PageResponse struct {
 	ScriptOutput     map[string]interface{} `json:"scriptOutput,omitempty"`
 	EventPhase       string                 `json:"eventPhase,omitempty"`
 	Resources        []interface{}          `json:"resources,omitempty"`
+
+	// automationRaw and scriptOutputRaw keep the API's bytes so typed decoding
+	// does not lose integer precision to float64.
+	automationRaw   json.RawMessage
+	scriptOutputRaw json.RawMessage
 }
 
 // PageEvent represents a single timeline/event entry in an outputAsJson response.
@@ -264,8 +271,8 @@ func (p *PageResponse) UnmarshalJSON(data []byte) error {
 		Cookies          []Cookie              `json:"cookies,omitempty"`
 		Errors           []string              `json:"errors,omitempty"`
 		ContentErrors    json.RawMessage       `json:"contentErrors,omitempty"`
-		AutomationResult interface{}           `json:"automationResult,omitempty"`
-		ScriptOutput     map[string]interface{} `json:"scriptOutput,omitempty"`
+		AutomationResult json.RawMessage       `json:"automationResult,omitempty"`
+		ScriptOutput     json.RawMessage       `json:"scriptOutput,omitempty"`
 		EventPhase       string                `json:"eventPhase,omitempty"`
 		Resources        []interface{}         `json:"resources,omitempty"`
 	}
@@ -290,8 +297,6 @@ func (p *PageResponse) UnmarshalJSON(data []byte) error {
 		Cookies:          raw.Cookies,
 		Errors:           raw.Errors,
 		ContentErrors:    contentErrors,
-		AutomationResult: raw.AutomationResult,
-		ScriptOutput:     raw.ScriptOutput,
 		EventPhase:       raw.EventPhase,
 		Resources:        raw.Resources,
 	}
@@ -317,9 +322,67 @@ func (p *PageResponse) UnmarshalJSON(data []byte) error {
 		}
 	}
 
+	if len(raw.AutomationResult) > 0 && string(raw.AutomationResult) != "null" {
+		if err := json.Unmarshal(raw.AutomationResult, &p.AutomationResult); err != nil {
+			return err
+		}
+		p.automationRaw = raw.AutomationResult
+	}
+	if len(raw.ScriptOutput) > 0 && string(raw.ScriptOutput) != "null" {
+		if err := json.Unmarshal(raw.ScriptOutput, &p.ScriptOutput); err != nil {
+			return err
+		}
+		p.scriptOutputRaw = raw.ScriptOutput
+	}
+
 	return nil
 }
 
+// MarshalJSON writes automationResult and scriptOutput from the bytes the API
+// sent while the fields still hold what was decoded from them, so cached and
+// cloned responses keep full precision. Fields changed since are marshalled
+// as they are.
+func (p PageResponse) MarshalJSON() ([]byte, error) {
+	type plain PageResponse
+	out := struct {
+		plain
+		AutomationResult json.RawMessage `json:"automationResult,omitempty"`
+		ScriptOutput     json.RawMessage `json:"scriptOutput,omitempty"`
+	}{plain: plain(p)}
+	out.AutomationResult, out.ScriptOutput = p.rawResults()
+
+	var err error
+	if out.AutomationResult == nil && p.AutomationResult != nil {
+		if out.AutomationResult, err = json.Marshal(p.AutomationResult); err != nil {
+			return nil, err
+		}
+	}
+	if out.ScriptOutput == nil && len(p.ScriptOutput) > 0 {
+		if out.ScriptOutput, err = json.Marshal(p.ScriptOutput); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(out)
+}
+
+// rawResults returns the API's bytes for AutomationResult and ScriptOutput,
+// or nil for a field that no longer matches them.
+func (p *PageResponse) rawResults() (automation, scriptOutput json.RawMessage) {
+	return currentRaw(p.automationRaw, p.AutomationResult), currentRaw(p.scriptOutputRaw, p.ScriptOutput)
+}
+
+// currentRaw returns raw if it still decodes to value.
+func currentRaw[T any](raw json.RawMessage, value T) json.RawMessage {
+	if raw == nil {
+		return nil
+	}
+	var decoded T
+	if err := json.Unmarshal(raw, &decoded); err != nil || !reflect.DeepEqual(decoded, value) {
+		return nil
+	}
+	return raw
+}
+
 func parseContentErrors(raw json.RawMessage) ([]string, error) {
 	if len(raw) == 0 || string(raw) == "null" {
 		return nil, nil
@@ -417,4 +480,19 @@ type ResponseMetadata struct {
 	BillingCreditCost  float64
 	ContentStatusCode  int
 	ContentDoneWhen    string
+	// Cached is true when the response was served from the client's Cache
+	// and no credits were spent. CachedAt is when it was originally stored.
+	Cached   bool
+	CachedAt time.Time
+	// Coalesced is true when the response was shared with a concurrent
+	// identical call (see WithRequestCoalescing) instead of being requested.
+	Coalesced bool
+	// KeyID identifies the API key that served the request: the PoolKey.ID
+	// when a KeyPool is used, otherwise a masked form of the client's key.
+	KeyID string
+	// Hedged reports that a duplicate request was sent because the first was slow.
+	Hedged bool
+	// HedgeWinner is the request that answered first when Hedged is set:
+	// 0 for the original, 1 for the duplicate.
+	HedgeWinner int
 }
@@ -0,0 +1,281 @@
+package phantomjscloud
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxPagesPerRequest is the number of pages PhantomJsCloud accepts in one UserRequest.
+const MaxPagesPerRequest = 100
+
+// ErrInvalidRequest is matched through errors.Is by every *ValidationError.
+var ErrInvalidRequest = errors.New("phantomjscloud: invalid request")
+
+// RenderTypes lists the render types PhantomJsCloud accepts.
+var RenderTypes = []string{"html", "plainText", "jpeg", "jpg", "png", "pdf", "json", "script", "automation"}
+
+// FieldError is one problem found by Validate, located by the JSON path of
+// the offending field, e.g. "pages[0].renderSettings.clipRectangle".
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string { return e.Field + ": " + e.Message }
+
+// ValidationError lists every problem found in a request. errors.As can
+// extract the individual *FieldError values.
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		msgs[i] = fe.Error()
+	}
+	return "phantomjscloud: invalid request: " + strings.Join(msgs, "; ")
+}
+
+// Is reports whether target is ErrInvalidRequest.
+func (e *ValidationError) Is(target error) bool { return target == ErrInvalidRequest }
+
+// Unwrap returns the individual field errors.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, fe := range e.Errors {
+		errs[i] = fe
+	}
+	return errs
+}
+
+// WithRequestValidation makes DoContext call UserRequest.Validate and return
+// its error before any request is sent.
+func WithRequestValidation() ClientOption {
+	return func(c *Client) { c.validate = true }
+}
+
+// Validate checks the request for mistakes PhantomJsCloud would only report
+// after a billed round-trip, or not at all. It returns nil or a *ValidationError.
+func (r *UserRequest) Validate() error {
+	v := &validator{}
+	switch {
+	case len(r.Pages) == 0:
+		v.add("pages", "at least one page is required")
+	case len(r.Pages) > MaxPagesPerRequest:
+		v.add("pages", fmt.Sprintf("%d pages exceed the limit of %d per request", len(r.Pages), MaxPagesPerRequest))
+	}
+	v.proxy("proxy", r.Proxy)
+	for i := range r.Pages {
+		v.page(fmt.Sprintf("pages[%d].", i), &r.Pages[i])
+	}
+	return v.err()
+}
+
+// Validate checks a single page like UserRequest.Validate.
+func (p *PageRequest) Validate() error {
+	v := &validator{}
+	v.page("", p)
+	return v.err()
+}
+
+type validator struct {
+	errs []*FieldError
+}
+
+func (v *validator) add(field, msg string) {
+	v.errs = append(v.errs, &FieldError{Field: field, Message: msg})
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+func (v *validator) page(prefix string, p *PageRequest) {
+	if p.URL == "" && p.Content == "" {
+		v.add(prefix+"url", "required unless content is set")
+	}
+
+	rt := p.RenderType
+	if rt != "" && !isRenderType(rt) {
+		v.add(prefix+"renderType", fmt.Sprintf("unknown render type %q; want one of %s", rt, strings.Join(RenderTypes, ", ")))
+	}
+	rt = ledgerRenderType(rt)
+
+	if p.OverseerScript != "" && rt != "automation" {
+		v.add(prefix+"overseerScript", fmt.Sprintf("requires renderType \"automation\", got %q", rt))
+	}
+
+	for i, m := range p.RequestSettings.ResourceModifier {
+		if m.Regex == "" {
+			continue
+		}
+		if msg := invalidRegex(m.Regex); msg != "" {
+			v.add(fmt.Sprintf("%srequestSettings.resourceModifier[%d].regex", prefix, i), msg)
+		}
+	}
+
+	rs := p.RenderSettings
+	if rs.PdfOptions != nil {
+		if rt != "pdf" {
+			v.add(prefix+"renderSettings.pdfOptions", fmt.Sprintf("only applies to renderType \"pdf\", got %q", rt))
+		} else {
+			v.pdfOptions(prefix+"renderSettings.pdfOptions.", rs.PdfOptions)
+		}
+	}
+	if clip := rs.ClipRectangle; clip != nil {
+		field := prefix + "renderSettings.clipRectangle"
+		switch {
+		case clip.Top < 0 || clip.Left < 0 || clip.Width < 0 || clip.Height < 0:
+			v.add(field, "must not have negative values")
+		case rs.Viewport != nil && (clip.Left+clip.Width > rs.Viewport.Width || clip.Top+clip.Height > rs.Viewport.Height):
+			v.add(field, fmt.Sprintf("%dx%d at (%d,%d) extends beyond the %dx%d viewport",
+				clip.Width, clip.Height, clip.Left, clip.Top, rs.Viewport.Width, rs.Viewport.Height))
+		}
+	}
+
+	v.proxy(prefix+"proxy", p.Proxy)
+}
+
+// proxy accepts the values normalizePageProxyForAPI can send: strings and the
+// Proxy* option types, which must resolve to a proxy string.
+func (v *validator) proxy(field string, value interface{}) {
+	switch p := value.(type) {
+	case nil, string:
+		return
+	case ProxyBuiltin, *ProxyBuiltin, ProxyOptions, *ProxyOptions:
+		if s, ok := normalizePageProxyForAPI(p).(string); !ok || s == "" {
+			v.add(field, "proxy options resolve to no proxy; set a location, geolocation or custom host")
+		}
+	default:
+		v.add(field, fmt.Sprintf("unsupported proxy type %T; use a string, ProxyBuiltin or ProxyOptions", value))
+	}
+}
+
+func isRenderType(rt string) bool {
+	for _, known := range RenderTypes {
+		if rt == known {
+			return true
+		}
+	}
+	return false
+}
+
+// invalidRegex describes why pattern is not a valid regular expression, or
+// returns "". Patterns run as JavaScript regexes in the browser, so syntax Go
+// doesn't support, such as lookarounds and backreferences, is rewritten to a
+// Go equivalent first and the rest of the pattern is still checked.
+func invalidRegex(pattern string) string {
+	if _, err := regexp.Compile(goRegexSyntax(pattern)); err != nil {
+		return "invalid regex: " + err.Error()
+	}
+	return ""
+}
+
+// goRegexSyntax rewrites the JavaScript-only parts of pattern so that it
+// parses in Go exactly when it parses in JavaScript. It is only used to check
+// syntax: lookarounds become plain groups and backreferences empty ones.
+func goRegexSyntax(pattern string) string {
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i += jsEscape(&b, pattern[i+1:], inClass)
+		case c == '[' && !inClass:
+			inClass = true
+			b.WriteByte(c)
+			// A leading ] or ^] is literal in Go but closes the class in JavaScript.
+			if strings.HasPrefix(pattern[i+1:], "]") || strings.HasPrefix(pattern[i+1:], "^]") {
+				b.WriteString(`\x00`)
+			}
+		case c == ']' && inClass:
+			inClass = false
+			b.WriteByte(c)
+		case c == '(' && !inClass:
+			rest := pattern[i:]
+			switch {
+			case strings.HasPrefix(rest, "(?="), strings.HasPrefix(rest, "(?!"):
+				b.WriteString("(?:")
+				i += 2
+			case strings.HasPrefix(rest, "(?<="), strings.HasPrefix(rest, "(?<!"):
+				b.WriteString("(?:")
+				i += 3
+			case strings.HasPrefix(rest, "(?<"):
+				// Named group; older Go versions only read the (?P<name> form.
+				b.WriteString("(?P<")
+				i += 2
+			default:
+				b.WriteByte(c)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// jsEscape writes the Go form of the escape sequence that follows a
+// backslash and returns how many bytes of rest it used.
+func jsEscape(b *strings.Builder, rest string, inClass bool) int {
+	c := rest[0]
+	switch {
+	case c >= '1' && c <= '9':
+		n := 1
+		for n < len(rest) && rest[n] >= '0' && rest[n] <= '9' {
+			n++
+		}
+		if inClass {
+			b.WriteString(`\x01`)
+		} else {
+			b.WriteString("(?:)") // backreference
+		}
+		return n
+	case c == '0':
+		b.WriteString(`\x00`)
+		return 1
+	case c == 'k' && strings.HasPrefix(rest, "k<") && strings.Contains(rest, ">"):
+		b.WriteString("(?:)") // named backreference
+		return strings.Index(rest, ">") + 1
+	case c == 'u' && strings.HasPrefix(rest, "u{") && strings.Contains(rest, "}"):
+		end := strings.Index(rest, "}")
+		b.WriteString(`\x{` + rest[2:end] + "}")
+		return end + 1
+	case c == 'u' && len(rest) >= 5 && isHex(rest[1:5]):
+		b.WriteString(`\x{` + rest[1:5] + "}")
+		return 5
+	case c == 'c' && len(rest) >= 2 && isASCIILetter(rest[1]):
+		b.WriteString(`\x01`) // control character
+		return 2
+	case c == 'b' && inClass:
+		b.WriteString(`\x08`) // backspace
+		return 1
+	case isASCIILetter(c) && !strings.ContainsRune("dDsSwWbBfnrtvxpP", rune(c)):
+		// An identity escape of a letter JavaScript gives no meaning.
+		b.WriteByte(c)
+		return 1
+	}
+	b.WriteByte('\\')
+	b.WriteByte(c)
+	return 1
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
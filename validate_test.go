@@ -0,0 +1,152 @@
+package phantomjscloud
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func fieldsOf(t *testing.T, err error) []string {
+	t.Helper()
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+	fields := make([]string, len(ve.Errors))
+	for i, fe := range ve.Errors {
+		fields[i] = fe.Field
+	}
+	return fields
+}
+
+func TestPageRequestValidate_Valid(t *testing.T) {
+	p := &PageRequest{
+		URL:            "https://example.com",
+		RenderType:     "automation",
+		OverseerScript: "await page.waitForNavigation();",
+		Proxy:          ProxyBuiltin{Location: "us"},
+		RequestSettings: RequestSettings{ResourceModifier: []ResourceModifier{
+			{Regex: `.*\.(png|jpg)$`, IsBlacklisted: true},
+			{Regex: `^(?!https://example\.com).*`, IsBlacklisted: true}, // JavaScript lookahead
+			{Regex: `(?<=/static/)v\d+`, IsBlacklisted: true},           // JavaScript lookbehind
+			{Regex: `(?<!\.min)\.js$`, IsBlacklisted: true},             // JavaScript negative lookbehind
+		}},
+		RenderSettings: RenderSettings{
+			Viewport:      &Viewport{Width: 1280, Height: 800},
+			ClipRectangle: &ClipRectangle{Width: 1280, Height: 800},
+		},
+	}
+	if err := p.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestPageRequestValidate_CollectsEveryProblem(t *testing.T) {
+	p := &PageRequest{
+		URL:            "https://example.com",
+		RenderType:     "gif",
+		OverseerScript: "page.click('a');",
+		Proxy:          42,
+		RequestSettings: RequestSettings{ResourceModifier: []ResourceModifier{
+			{Regex: "ok"},
+			{Regex: "([a-z]"},
+		}},
+		RenderSettings: RenderSettings{
+			Viewport:      &Viewport{Width: 800, Height: 600},
+			ClipRectangle: &ClipRectangle{Left: 100, Width: 800, Height: 600},
+			PdfOptions:    &PdfOptions{Landscape: true},
+		},
+	}
+	err := p.Validate()
+	if !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected ErrInvalidRequest, got %v", err)
+	}
+	want := []string{
+		"renderType",
+		"overseerScript",
+		"requestSettings.resourceModifier[1].regex",
+		"renderSettings.pdfOptions",
+		"renderSettings.clipRectangle",
+		"proxy",
+	}
+	if got := fieldsOf(t, err); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("fields = %v, want %v", got, want)
+	}
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "renderType" {
+		t.Fatalf("expected errors.As to find the first *FieldError, got %v", fe)
+	}
+}
+
+func TestInvalidRegex_JavaScriptSyntax(t *testing.T) {
+	valid := []string{
+		`(a)\1`,
+		`(?<year>\d{4})-\k<year>`,
+		`\u0041\u{1F600}\cJ\0`,
+		`[\b\-]\/`,
+		`(?<=(?!x)a)b`,
+	}
+	for _, pattern := range valid {
+		if msg := invalidRegex(pattern); msg != "" {
+			t.Errorf("invalidRegex(%q) = %q, want valid", pattern, msg)
+		}
+	}
+	// Errors after a lookaround are still found.
+	invalid := []string{
+		`(?<=a)(b`,
+		`(?!x)[z-a]`,
+		`(?=a)b**`,
+		`(?<!\.min)\.js$)`,
+	}
+	for _, pattern := range invalid {
+		if msg := invalidRegex(pattern); msg == "" {
+			t.Errorf("invalidRegex(%q) should fail", pattern)
+		}
+	}
+}
+
+func TestUserRequestValidate_PathsAndPageLimit(t *testing.T) {
+	req := &UserRequest{
+		Proxy: &ProxyOptions{},
+		Pages: []PageRequest{
+			{URL: "https://example.com"},
+			{URL: "https://example.com", RenderType: "png", RenderSettings: RenderSettings{PdfOptions: &PdfOptions{}}},
+		},
+	}
+	want := "proxy,pages[1].renderSettings.pdfOptions"
+	if got := fieldsOf(t, req.Validate()); strings.Join(got, ",") != want {
+		t.Fatalf("fields = %v, want %s", got, want)
+	}
+
+	req = &UserRequest{Pages: make([]PageRequest, MaxPagesPerRequest+1)}
+	for i := range req.Pages {
+		req.Pages[i].URL = "https://example.com"
+	}
+	if got := fieldsOf(t, req.Validate()); len(got) != 1 || got[0] != "pages" {
+		t.Fatalf("expected page limit error, got %v", got)
+	}
+}
+
+func TestWithRequestValidation_FailsBeforeSending(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"pageResponses":[{}]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key", WithEndpoint(server.URL+"/"), WithRequestValidation())
+	if _, err := client.DoPage(&PageRequest{URL: "https://example.com", RenderType: "gif"}); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected ErrInvalidRequest, got %v", err)
+	}
+	if atomic.LoadInt32(&hits) != 0 {
+		t.Fatal("invalid request must not reach the API")
+	}
+	if _, err := client.DoPage(&PageRequest{URL: "https://example.com"}); err != nil {
+		t.Fatalf("valid request: %v", err)
+	}
+}
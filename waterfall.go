@@ -0,0 +1,313 @@
+package phantomjscloud
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultWaterfallSlowest is the number of slowest resources a Waterfall lists
+// when WaterfallOptions.Slowest is zero.
+const DefaultWaterfallSlowest = 5
+
+// WaterfallOptions configures AnalyzeWaterfall.
+type WaterfallOptions struct {
+	// Slowest is how many of the slowest resources to list.
+	Slowest int
+}
+
+// Waterfall summarizes where a render spent its time. Durations are measured
+// from the start of the render and are -1 when the response doesn't say.
+type Waterfall struct {
+	URL      string
+	DOMReady time.Duration
+	Load     time.Duration
+	Total    time.Duration
+	// LateBlocked is how long the render kept waiting on resources that were
+	// still loading after the page had loaded.
+	LateBlocked   time.Duration
+	LateResources []WaterfallResource
+	// DoneWhen is the condition that ended the render, nil when unknown.
+	DoneWhen   *DoneWhen
+	DoneWhenAt time.Duration
+	Slowest    []WaterfallResource
+	Requests   int
+	Failed     int
+	Console    []string
+	Errors     []string
+}
+
+// WaterfallResource is one resource placed on the render's timeline.
+type WaterfallResource struct {
+	URL      string
+	Status   int
+	Start    time.Duration
+	Duration time.Duration
+	Size     int64
+	Failed   bool
+}
+
+// AnalyzeWaterfall builds a Waterfall from the events, metrics and resources
+// of a JSON render.
+func AnalyzeWaterfall(p *PageResponse, opts WaterfallOptions) (*Waterfall, error) {
+	if opts.Slowest <= 0 {
+		opts.Slowest = DefaultWaterfallSlowest
+	}
+	resources, err := p.NetworkResources()
+	if err != nil {
+		return nil, err
+	}
+	events := p.Timeline()
+
+	w := &Waterfall{DOMReady: -1, Load: -1, Total: -1, DoneWhenAt: -1, Requests: len(resources)}
+	if p.FrameData != nil {
+		w.URL = p.FrameData.Url
+	}
+	if p.Metrics.TotalRenderTimeMs > 0 {
+		w.Total = time.Duration(p.Metrics.TotalRenderTimeMs) * time.Millisecond
+	}
+
+	var doneWhen *TimelineEvent
+	for i, e := range events {
+		switch e.Kind {
+		case EventNavigation:
+			if w.URL == "" {
+				w.URL = e.URL
+			}
+		case EventDOMReady:
+			if w.DOMReady < 0 {
+				w.DOMReady = e.Elapsed
+			}
+		case EventLoad:
+			if w.Load < 0 {
+				w.Load = e.Elapsed
+			}
+		case EventConsole:
+			w.Console = append(w.Console, e.Message)
+		case EventError:
+			w.Errors = append(w.Errors, firstNonEmpty(e.Message, e.URL, e.Key))
+		case EventDoneWhen:
+			if doneWhen == nil {
+				doneWhen = &events[i]
+			}
+		}
+	}
+	if m := p.Metrics; w.Load < 0 && m.PageLoadStartTime > 0 && m.PageLoadFinishTime > m.PageLoadStartTime {
+		w.Load = time.Duration(m.PageLoadFinishTime-m.PageLoadStartTime) * time.Millisecond
+	}
+	if doneWhen != nil {
+		w.DoneWhenAt = doneWhen.Elapsed
+		w.DoneWhen = matchDoneWhen(p.DoneWhen, doneWhen)
+	}
+
+	origin := renderOrigin(p, events, resources)
+	placed := make([]WaterfallResource, 0, len(resources))
+	for _, r := range resources {
+		wr := WaterfallResource{URL: r.Request.URL, Duration: r.Timing.Duration, Size: r.Size, Failed: r.Failed()}
+		if r.Response != nil {
+			wr.Status = r.Response.Status
+		}
+		if !r.Timing.Start.IsZero() && !origin.IsZero() {
+			wr.Start = r.Timing.Start.Sub(origin)
+		}
+		if wr.Failed {
+			w.Failed++
+		}
+		placed = append(placed, wr)
+	}
+
+	settled := w.Load
+	if settled < 0 {
+		settled = w.DOMReady
+	}
+	var late [][2]time.Duration
+	for i, wr := range placed {
+		end := wr.Start + wr.Duration
+		if resources[i].State == "late" || (settled >= 0 && wr.Duration > 0 && end > settled) {
+			w.LateResources = append(w.LateResources, wr)
+			start := max(wr.Start, settled)
+			if w.Total > 0 {
+				end = min(end, w.Total)
+			}
+			if end > start {
+				late = append(late, [2]time.Duration{start, end})
+			}
+		}
+	}
+	w.LateBlocked = unionLength(late)
+
+	w.Slowest = append([]WaterfallResource(nil), placed...)
+	sort.SliceStable(w.Slowest, func(i, j int) bool { return w.Slowest[i].Duration > w.Slowest[j].Duration })
+	if len(w.Slowest) > opts.Slowest {
+		w.Slowest = w.Slowest[:opts.Slowest]
+	}
+	return w, nil
+}
+
+// renderOrigin finds the wall-clock start of the render, preferring event
+// timestamps, then an epoch PageLoadStartTime, then the first resource.
+func renderOrigin(p *PageResponse, events []TimelineEvent, resources []Resource) time.Time {
+	for _, e := range events {
+		if !e.Time.IsZero() {
+			return e.Time.Add(-e.Elapsed)
+		}
+	}
+	if p.Metrics.PageLoadStartTime > 1e12 {
+		return time.UnixMilli(int64(p.Metrics.PageLoadStartTime)).UTC()
+	}
+	for _, r := range resources {
+		if !r.Timing.Start.IsZero() {
+			return r.Timing.Start
+		}
+	}
+	return time.Time{}
+}
+
+// matchDoneWhen maps a doneWhen event to the requested condition it reports.
+// The event value may be the condition's index, its event name or selector,
+// or an object with those fields.
+func matchDoneWhen(conditions []DoneWhen, e *TimelineEvent) *DoneWhen {
+	switch v := e.Value.(type) {
+	case float64:
+		if i := int(v); i >= 0 && i < len(conditions) {
+			c := conditions[i]
+			return &c
+		}
+	case map[string]interface{}:
+		if f, ok := v["index"].(float64); ok && int(f) >= 0 && int(f) < len(conditions) {
+			c := conditions[int(f)]
+			return &c
+		}
+		fired := DoneWhen{
+			Event:      valueString(v, "event"),
+			Selector:   valueString(v, "selector"),
+			Text:       valueString(v, "text"),
+			StatusCode: valueInt(v, "statusCode"),
+		}
+		for _, c := range conditions {
+			if (fired.Event != "" && c.Event == fired.Event) || (fired.Selector != "" && c.Selector == fired.Selector) {
+				return &c
+			}
+		}
+		if fired != (DoneWhen{}) {
+			return &fired
+		}
+	}
+	if e.Message == "" {
+		return nil
+	}
+	for _, c := range conditions {
+		if c.Event == e.Message || c.Selector == e.Message || c.Text == e.Message {
+			return &c
+		}
+	}
+	return &DoneWhen{Event: e.Message}
+}
+
+func unionLength(spans [][2]time.Duration) time.Duration {
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	var total, curStart, curEnd time.Duration
+	for i, s := range spans {
+		if i == 0 || s[0] > curEnd {
+			total += curEnd - curStart
+			curStart, curEnd = s[0], s[1]
+			continue
+		}
+		curEnd = max(curEnd, s[1])
+	}
+	return total + curEnd - curStart
+}
+
+// String renders w as a plain-text report.
+func (w *Waterfall) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Waterfall %s\n", firstNonEmpty(w.URL, "(unknown url)"))
+	fmt.Fprintf(&b, "  DOM ready:    %s\n", formatOffset(w.DOMReady))
+	fmt.Fprintf(&b, "  Load:         %s\n", formatOffset(w.Load))
+	fmt.Fprintf(&b, "  Total:        %s\n", formatOffset(w.Total))
+	fmt.Fprintf(&b, "  Late blocked: %s (%d resources)\n", w.LateBlocked, len(w.LateResources))
+	fmt.Fprintf(&b, "  Done when:    %s\n", w.doneWhenText())
+	fmt.Fprintf(&b, "  Requests:     %d (%d failed)\n", w.Requests, w.Failed)
+	if len(w.Slowest) > 0 {
+		b.WriteString("Slowest resources:\n")
+		for _, r := range w.Slowest {
+			fmt.Fprintf(&b, "  %8s  +%-8s %s %s\n", r.Duration, r.Start, statusText(r), r.URL)
+		}
+	}
+	for _, e := range w.Errors {
+		fmt.Fprintf(&b, "Error: %s\n", e)
+	}
+	return b.String()
+}
+
+// Markdown renders w as a Markdown report.
+func (w *Waterfall) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Waterfall: %s\n\n", firstNonEmpty(w.URL, "(unknown url)"))
+	b.WriteString("| Metric | Value |\n|---|---|\n")
+	fmt.Fprintf(&b, "| DOM ready | %s |\n", formatOffset(w.DOMReady))
+	fmt.Fprintf(&b, "| Load | %s |\n", formatOffset(w.Load))
+	fmt.Fprintf(&b, "| Total | %s |\n", formatOffset(w.Total))
+	fmt.Fprintf(&b, "| Late blocked | %s (%d resources) |\n", w.LateBlocked, len(w.LateResources))
+	fmt.Fprintf(&b, "| Done when | %s |\n", markdownCell(w.doneWhenText()))
+	fmt.Fprintf(&b, "| Requests | %d (%d failed) |\n", w.Requests, w.Failed)
+	if len(w.Slowest) > 0 {
+		b.WriteString("\n### Slowest Resources\n\n| Duration | Start | Status | URL |\n|---|---|---|---|\n")
+		for _, r := range w.Slowest {
+			fmt.Fprintf(&b, "| %s | +%s | %s | %s |\n", r.Duration, r.Start, statusText(r), markdownCell(r.URL))
+		}
+	}
+	if len(w.Errors) > 0 {
+		b.WriteString("\n### Errors\n\n")
+		for _, e := range w.Errors {
+			fmt.Fprintf(&b, "- `%s`\n", strings.ReplaceAll(e, "`", "'"))
+		}
+	}
+	return b.String()
+}
+
+func (w *Waterfall) doneWhenText() string {
+	if w.DoneWhen == nil {
+		return "unknown"
+	}
+	var parts []string
+	if w.DoneWhen.Event != "" {
+		parts = append(parts, "event="+w.DoneWhen.Event)
+	}
+	if w.DoneWhen.Selector != "" {
+		parts = append(parts, "selector="+w.DoneWhen.Selector)
+	}
+	if w.DoneWhen.Text != "" {
+		parts = append(parts, fmt.Sprintf("text=%q", w.DoneWhen.Text))
+	}
+	if w.DoneWhen.StatusCode != 0 {
+		parts = append(parts, fmt.Sprintf("statusCode=%d", w.DoneWhen.StatusCode))
+	}
+	if len(parts) == 0 {
+		// The event fired without naming its condition.
+		parts = append(parts, "(unnamed)")
+	}
+	return fmt.Sprintf("%s at %s", strings.Join(parts, " "), formatOffset(w.DoneWhenAt))
+}
+
+func formatOffset(d time.Duration) string {
+	if d < 0 {
+		return "unknown"
+	}
+	return d.String()
+}
+
+func statusText(r WaterfallResource) string {
+	switch {
+	case r.Failed && r.Status == 0:
+		return "failed"
+	case r.Failed:
+		return fmt.Sprintf("%d failed", r.Status)
+	}
+	return fmt.Sprint(r.Status)
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,90 @@
+package phantomjscloud
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+const waterfallJSON = `{
+	"frameData":{"url":"https://example.com/"},
+	"metrics":{"totalRenderTimeMs":1500},
+	"doneWhen":[{"event":"load"},{"selector":"#ready"}],
+	"events":[
+		{"key":"pageNavigationRequested","value":{"url":"https://example.com/"},"elapsedMs":0,"time":"2024-01-02T03:04:05Z"},
+		{"key":"domReady","elapsedMs":400},
+		{"key":"pageLoadFinished","elapsedMs":900},
+		{"key":"consoleMessage","value":"booted","elapsedMs":950},
+		{"key":"doneWhen","value":{"selector":"#ready"},"elapsedMs":1400}
+	],
+	"resources":[
+		{"id":1,"url":"https://example.com/","statusCode":200,"startTime":"2024-01-02T03:04:05Z","duration":300},
+		{"id":2,"url":"https://example.com/app.js","statusCode":200,"startTime":"2024-01-02T03:04:05.300Z","duration":500},
+		{"id":3,"url":"https://ads.example/slow.js","statusCode":200,"startTime":"2024-01-02T03:04:05.700Z","duration":600},
+		{"id":4,"url":"https://ads.example/beacon","statusCode":200,"startTime":"2024-01-02T03:04:05.800Z","duration":400},
+		{"id":5,"url":"https://cdn.example/missing.css","startTime":"2024-01-02T03:04:05.100Z","errorText":"net::ERR_FAILED"}
+	]}`
+
+func TestAnalyzeWaterfall(t *testing.T) {
+	var page PageResponse
+	if err := json.Unmarshal([]byte(waterfallJSON), &page); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := AnalyzeWaterfall(&page, WaterfallOptions{Slowest: 2})
+	if err != nil {
+		t.Fatalf("AnalyzeWaterfall: %v", err)
+	}
+	if w.URL != "https://example.com/" || w.DOMReady != 400*time.Millisecond || w.Load != 900*time.Millisecond || w.Total != 1500*time.Millisecond {
+		t.Fatalf("unexpected timings %+v", w)
+	}
+	if w.Requests != 5 || w.Failed != 1 {
+		t.Fatalf("requests = %d failed = %d", w.Requests, w.Failed)
+	}
+	// slow.js runs 700ms-1300ms and the beacon 800ms-1200ms; both outlive
+	// load at 900ms, so the render waits from 900ms to 1300ms.
+	if len(w.LateResources) != 2 || w.LateBlocked != 400*time.Millisecond {
+		t.Fatalf("late = %d blocked = %s", len(w.LateResources), w.LateBlocked)
+	}
+	if w.DoneWhen == nil || w.DoneWhen.Selector != "#ready" || w.DoneWhenAt != 1400*time.Millisecond {
+		t.Fatalf("unexpected doneWhen %+v at %s", w.DoneWhen, w.DoneWhenAt)
+	}
+	if len(w.Slowest) != 2 || w.Slowest[0].URL != "https://ads.example/slow.js" || w.Slowest[0].Start != 700*time.Millisecond {
+		t.Fatalf("unexpected slowest %+v", w.Slowest)
+	}
+	if len(w.Console) != 1 || w.Console[0] != "booted" {
+		t.Fatalf("unexpected console %v", w.Console)
+	}
+
+	text := w.String()
+	for _, want := range []string{"DOM ready:    400ms", "Late blocked: 400ms (2 resources)", "selector=#ready at 1.4s", "slow.js"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text report missing %q:\n%s", want, text)
+		}
+	}
+	md := w.Markdown()
+	for _, want := range []string{"## Waterfall: https://example.com/", "| Load | 900ms |", "### Slowest Resources"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown report missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestAnalyzeWaterfall_UnknownTimings(t *testing.T) {
+	w, err := AnalyzeWaterfall(&PageResponse{}, WaterfallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.DOMReady != -1 || w.Load != -1 || w.DoneWhen != nil || w.LateBlocked != 0 {
+		t.Fatalf("expected unknown timings, got %+v", w)
+	}
+	if !strings.Contains(w.String(), "Done when:    unknown") {
+		t.Errorf("unexpected report:\n%s", w.String())
+	}
+
+	w.DoneWhen, w.DoneWhenAt = &DoneWhen{}, 1200*time.Millisecond
+	if !strings.Contains(w.String(), "Done when:    (unnamed) at 1.2s") {
+		t.Errorf("unexpected report for an unnamed condition:\n%s", w.String())
+	}
+}